@@ -0,0 +1,124 @@
+// Package avatar turns the pictures uploaded by the users into the square JPEG thumbnails of their profiles. The
+// pictures are decoded and encoded again, the thumbnails keep none of their metadata, e.g. the EXIF location.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	// the decoders of the accepted formats
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// ContentType is the media type of the thumbnails
+const ContentType = "image/jpeg"
+
+// jpegQuality is the quality of the thumbnails
+const jpegQuality = 85
+
+// maxPixels bounds the memory used to decode a picture, a small file can declare huge dimensions
+var maxPixels = 40_000_000
+
+var (
+	// ErrUnsupportedFormat is returned for the files which aren't JPEG, PNG or WebP pictures
+	ErrUnsupportedFormat = errors.New("avatar: unsupported image format")
+	// ErrTooLarge is returned for the pictures with too many pixels
+	ErrTooLarge = errors.New("avatar: image too large")
+)
+
+// Thumbnail is a square JPEG picture of Size pixels
+type Thumbnail struct {
+	Size    int
+	Content []byte
+}
+
+// Process decodes the picture and returns its thumbnails of the given sizes, cropped to the center square. The
+// EXIF orientation of the JPEG pictures is applied, the transparent areas are filled with white.
+func Process(content []byte, sizes []int) ([]Thumbnail, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	picture, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(content)
+	}
+
+	// the center square is the same whatever the orientation, it's rotated once scaled
+	bounds := picture.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	corner := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.Rectangle{Min: corner, Max: corner.Add(image.Pt(side, side))}
+
+	thumbnails := make([]Thumbnail, 0, len(sizes))
+	for _, size := range sizes {
+		scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(scaled, scaled.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), picture, square, xdraw.Over, nil)
+
+		var encoded bytes.Buffer
+		if err := jpeg.Encode(&encoded, orient(scaled, orientation), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		thumbnails = append(thumbnails, Thumbnail{Size: size, Content: encoded.Bytes()})
+	}
+	return thumbnails, nil
+}
+
+// orient returns the square picture shown upright according to the EXIF orientation, 1 to 8
+func orient(picture *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return picture
+	}
+
+	size := picture.Bounds().Dx()
+	last := size - 1
+	oriented := image.NewRGBA(picture.Bounds())
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			// the pixel of the stored picture shown at x, y
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = last-x, y
+			case 3: // rotated 180°
+				sx, sy = last-x, last-y
+			case 4: // mirrored vertically
+				sx, sy = x, last-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // to be rotated 90° clockwise
+				sx, sy = y, last-x
+			case 7: // transversed
+				sx, sy = last-y, last-x
+			case 8: // to be rotated 90° counterclockwise
+				sx, sy = last-y, x
+			}
+			oriented.SetRGBA(x, y, picture.RGBAAt(sx, sy))
+		}
+	}
+	return oriented
+}
@@ -0,0 +1,139 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// halves returns a picture of width by height pixels, red on its left half and blue on its right half
+func halves(width int, height int) *image.RGBA {
+	picture := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			picture.SetRGBA(x, y, c)
+		}
+	}
+	return picture
+}
+
+// withOrientation inserts an EXIF segment with the orientation after the start of the JPEG picture
+func withOrientation(t *testing.T, picture []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{exifOrientationTag, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(picture[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(picture[2:])
+
+	assert.Equal(t, int(orientation), jpegOrientation(out.Bytes()))
+	return out.Bytes()
+}
+
+func decode(t *testing.T, thumbnail Thumbnail) image.Image {
+	picture, err := jpeg.Decode(bytes.NewReader(thumbnail.Content))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return picture
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > 0xC000 && b < 0x4000
+}
+
+func isBlue(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return b > 0xC000 && r < 0x4000
+}
+
+func TestProcess(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, png.Encode(&encoded, halves(300, 200)))
+
+	thumbnails, err := Process(encoded.Bytes(), []int{128, 32})
+	if assert.NoError(t, err) && assert.Len(t, thumbnails, 2) {
+		for i, size := range []int{128, 32} {
+			assert.Equal(t, size, thumbnails[i].Size)
+
+			picture := decode(t, thumbnails[i])
+			assert.Equal(t, image.Rect(0, 0, size, size), picture.Bounds())
+			// the center square is cropped, its halves are still red and blue
+			assert.True(t, isRed(picture.At(2, size/2)))
+			assert.True(t, isBlue(picture.At(size-3, size/2)))
+		}
+	}
+}
+
+func TestProcessOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&encoded, halves(64, 64), &jpeg.Options{Quality: 95}))
+
+	// the picture is shown rotated 90° clockwise, the red half on top
+	thumbnails, err := Process(withOrientation(t, encoded.Bytes(), 6), []int{64})
+	if assert.NoError(t, err) && assert.Len(t, thumbnails, 1) {
+		picture := decode(t, thumbnails[0])
+		assert.True(t, isRed(picture.At(32, 2)))
+		assert.True(t, isBlue(picture.At(32, 61)))
+
+		// the EXIF data is stripped
+		assert.NotContains(t, string(thumbnails[0].Content), "Exif")
+	}
+}
+
+func TestProcessTransparency(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, png.Encode(&encoded, image.NewNRGBA(image.Rect(0, 0, 16, 16))))
+
+	thumbnails, err := Process(encoded.Bytes(), []int{16})
+	if assert.NoError(t, err) {
+		r, g, b, _ := decode(t, thumbnails[0]).At(8, 8).RGBA()
+		assert.True(t, r > 0xF000 && g > 0xF000 && b > 0xF000)
+	}
+}
+
+func TestProcessRejectedPictures(t *testing.T) {
+	_, err := Process([]byte("GIF89a not really"), []int{64})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = Process([]byte("%PDF-1.7"), []int{64})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	var encoded bytes.Buffer
+	assert.NoError(t, png.Encode(&encoded, halves(100, 100)))
+	defer func(previous int) { maxPixels = previous }(maxPixels)
+	maxPixels = 100 * 99
+	_, err = Process(encoded.Bytes(), []int{64})
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestJPEGOrientationWithoutEXIF(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&encoded, halves(8, 8), nil))
+
+	assert.Equal(t, 1, jpegOrientation(encoded.Bytes()))
+	assert.Equal(t, 1, jpegOrientation([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00}))
+	assert.Equal(t, 1, jpegOrientation([]byte("not a jpeg")))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/handler"
+)
+
+const importProfilesUsage = "usage: main import-profiles [--dry-run] [--format csv|jsonl] FILE"
+
+// runImportProfiles is the import-profiles subcommand, it imports a CSV or JSON Lines file, or stdin with -, with
+// the configuration of the server. The skipped rows are written to stdout as JSON Lines and the summary to stderr.
+func runImportProfiles(args []string) int {
+	flags := flag.NewFlagSet("import-profiles", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "validate the rows and check the registered numbers without creating the profiles")
+	format := flags.String("format", "", "csv or jsonl, guessed from the extension of FILE by default")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, importProfilesUsage)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = handler.ImportFormatCSV
+		case ".jsonl", ".ndjson":
+			*format = handler.ImportFormatJSONL
+		default:
+			fmt.Fprintln(os.Stderr, "the format of", path, "can't be guessed, use --format")
+			return 2
+		}
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	server, err := newServer()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report := json.NewEncoder(os.Stdout)
+	summary, err := server.ImportProfiles(bufio.NewReader(input), handler.ImportOptions{
+		Format: *format,
+		DryRun: *dryRun,
+		OnRowError: func(rowError generated.ProfileImportRowError) {
+			report.Encode(rowError)
+		},
+	})
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would be imported"
+	}
+	fmt.Fprintf(os.Stderr, "%d rows: %d %s, %d skipped\n", summary.Total, summary.Imported, verb, summary.Failed)
+	if err != nil {
+		// the batches copied before the error are kept, the summary counts them
+		fmt.Fprintf(os.Stderr, "the import stopped after %d rows, the rows of the file after them weren't imported: %v\n", summary.Total, err)
+		return 1
+	}
+	return 0
+}
@@ -1,26 +1,93 @@
 package main
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	// the time zones of the profiles are validated against the embedded database, the runtime image has none
+	_ "time/tzdata"
 
+	"github.com/hasbiasshidiq/simple-profile/email"
 	"github.com/hasbiasshidiq/simple-profile/generated"
 	"github.com/hasbiasshidiq/simple-profile/handler"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
 	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/hasbiasshidiq/simple-profile/sms"
+	"github.com/hasbiasshidiq/simple-profile/storage"
+	"github.com/hasbiasshidiq/simple-profile/totp"
 
 	"github.com/labstack/echo/v4"
 )
 
+// legacyRoutesDeprecatedAt is the release of /v1, which deprecated the unversioned routes. LEGACY_ROUTES_DEPRECATED_AT
+// overrides it for the deployments which released /v1 later.
+var legacyRoutesDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// legacyRoutesSunsetAfter is how long the unversioned routes are kept after their deprecation unless
+// LEGACY_ROUTES_SUNSET sets their removal date
+const legacyRoutesSunsetAfter = 180 * 24 * time.Hour
+
 func main() {
+	// the subcommands run with the configuration of the server and exit
+	if len(os.Args) > 1 && os.Args[1] == "import-profiles" {
+		os.Exit(runImportProfiles(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	e := echo.New()
 
+	extractor, err := ipExtractor()
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	e.IPExtractor = extractor
+
 	// var server generated.ServerInterface = newServer()
 
-	generated.RegisterHandlers(e, newServer())
+	server, err := newServer()
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	// Every API version is mounted under its own prefix with the handlers generated from its spec,
+	// a future v2 is registered next to v1 the same way.
+	v1 := e.Group("/v1", server.RequestValidatorWithBaseURL("/v1"))
+	generated.RegisterHandlers(v1, server)
+
+	// The unversioned routes are kept as deprecated aliases of v1
+	deprecations, err := routeDeprecations()
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	e.Use(handler.DeprecationHeaders(deprecations))
+
+	legacy := e.Group("", server.RequestValidator())
+	generated.RegisterHandlers(legacy, server)
+
+	// The blobs of the local store, e.g. the avatars, are served by the service itself
+	if store, ok := server.BlobStore.(*storage.LocalStore); ok {
+		e.Static(handler.DefaultBlobBaseURL, store.Dir)
+	}
+
+	// The API explorer is meant for development and partner environments, keep it disabled in production
+	if os.Getenv("API_DOCS_ENABLED") == "true" {
+		if err := handler.RegisterDocs(e); err != nil {
+			e.Logger.Fatal(err)
+		}
+	}
 
 	e.Logger.Fatal(e.Start(":1323"))
 }
 
-func newServer() *handler.Server {
+func newServer() (*handler.Server, error) {
 	dbDsn := os.Getenv("DATABASE_URL")
 	var repo repository.RepositoryInterface = repository.NewRepository(repository.NewRepositoryOptions{
 		Dsn: dbDsn,
@@ -29,5 +96,274 @@ func newServer() *handler.Server {
 	opts := handler.NewServerOptions{
 		Repository: repo,
 	}
-	return handler.NewServer(opts)
+	// PHONE_ALLOWED_COUNTRIES is a comma separated list of ISO country codes, e.g. ID,MY,SG
+	if countries := os.Getenv("PHONE_ALLOWED_COUNTRIES"); countries != "" {
+		opts.AllowedCountries = strings.Split(countries, ",")
+	}
+
+	policy, err := passwordPolicy()
+	if err != nil {
+		return nil, err
+	}
+	opts.PasswordPolicy = policy
+
+	opts.PasswordRules, err = passwordRules()
+	if err != nil {
+		return nil, err
+	}
+
+	// PASSWORD_HISTORY_SIZE is the number of recent passwords which can't be reused, 5 by default
+	opts.PasswordHistorySize = 5
+	if historySize := os.Getenv("PASSWORD_HISTORY_SIZE"); historySize != "" {
+		opts.PasswordHistorySize, err = strconv.Atoi(historySize)
+		if err != nil {
+			return nil, fmt.Errorf("PASSWORD_HISTORY_SIZE: %w", err)
+		}
+	}
+
+	opts.TOTPIssuer = os.Getenv("TOTP_ISSUER")
+
+	// MFA_SECRET_KEY encrypts the secrets of the authenticators, 32 bytes in base64 e.g. from `openssl rand -base64 32`
+	opts.MFASecretKey, err = base64.StdEncoding.DecodeString(os.Getenv("MFA_SECRET_KEY"))
+	if err != nil || len(opts.MFASecretKey) != totp.SecretKeyLength {
+		return nil, fmt.Errorf("MFA_SECRET_KEY: %d bytes in base64 are required", totp.SecretKeyLength)
+	}
+
+	// RBAC_POLICY maps the roles to their permissions in JSON, e.g. {"support": ["profiles:read"]}
+	if policy := os.Getenv("RBAC_POLICY"); policy != "" {
+		opts.RolePolicy, err = rbac.ParsePolicy(policy)
+		if err != nil {
+			return nil, fmt.Errorf("RBAC_POLICY: %w", err)
+		}
+	}
+
+	// IDEMPOTENCY_KEY_TTL is how long the responses of the requests with an Idempotency-Key are replayed, e.g. 24h
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		opts.IdempotencyKeyTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("IDEMPOTENCY_KEY_TTL: %w", err)
+		}
+	}
+
+	// SMS_FILE_PATH writes the login codes to a file, until an SMS provider is configured. The codes log in as the
+	// users, they are only logged by the development deployments setting SMS_LOG_ENABLED.
+	switch {
+	case os.Getenv("SMS_FILE_PATH") != "":
+		opts.SMSSender = &sms.FileSender{Path: os.Getenv("SMS_FILE_PATH")}
+	case os.Getenv("SMS_LOG_ENABLED") == "true":
+		opts.SMSSender = sms.LogSender{}
+	default:
+		return nil, fmt.Errorf("SMS_FILE_PATH: a sender of the login codes is required, or SMS_LOG_ENABLED=true in development")
+	}
+
+	// EMAIL_FILE_PATH writes the verification links to a mailbox file, until an email provider is configured. The
+	// links verify the addresses, they are only logged by the development deployments setting EMAIL_LOG_ENABLED.
+	switch {
+	case os.Getenv("EMAIL_FILE_PATH") != "":
+		opts.EmailSender = &email.FileSender{Path: os.Getenv("EMAIL_FILE_PATH")}
+	case os.Getenv("EMAIL_LOG_ENABLED") == "true":
+		opts.EmailSender = email.LogSender{}
+	default:
+		return nil, fmt.Errorf("EMAIL_FILE_PATH: a sender of the verification links is required, or EMAIL_LOG_ENABLED=true in development")
+	}
+
+	// PUBLIC_URL is the URL of the service in the links sent by email, e.g. https://api.example.com. It's required,
+	// the Host header of the requests is chosen by the clients and can't be trusted in the links.
+	opts.PublicURL = os.Getenv("PUBLIC_URL")
+	if publicURL, err := url.Parse(opts.PublicURL); err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+		return nil, fmt.Errorf("PUBLIC_URL: an http or https URL is required")
+	}
+
+	opts.BlobStore, err = blobStore()
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.NewServer(opts), nil
+}
+
+// blobStore reads where the uploaded files are kept, BLOB_STORE is local (default) or s3. The local store writes to
+// BLOB_LOCAL_DIR, the s3 one to the bucket S3_BUCKET of S3_ENDPOINT in S3_REGION with S3_ACCESS_KEY_ID and
+// S3_SECRET_ACCESS_KEY. BLOB_PUBLIC_URL overrides the URL the clients download the files from, e.g. a CDN.
+func blobStore() (storage.BlobStore, error) {
+	publicURL := os.Getenv("BLOB_PUBLIC_URL")
+
+	switch kind := os.Getenv("BLOB_STORE"); kind {
+	case "", "local":
+		store := &storage.LocalStore{Dir: handler.DefaultBlobDir, BaseURL: handler.DefaultBlobBaseURL}
+		if dir := os.Getenv("BLOB_LOCAL_DIR"); dir != "" {
+			store.Dir = dir
+		}
+		if publicURL != "" {
+			store.BaseURL = publicURL
+		}
+		return store, nil
+
+	case "s3":
+		store := &storage.S3Store{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       publicURL,
+		}
+		if store.Endpoint == "" || store.Region == "" || store.Bucket == "" {
+			return nil, fmt.Errorf("BLOB_STORE: s3 requires S3_ENDPOINT, S3_REGION and S3_BUCKET")
+		}
+		return store, nil
+
+	default:
+		return nil, fmt.Errorf("BLOB_STORE: unknown store %q", kind)
+	}
+}
+
+// ipExtractor reads the IP addresses of the clients from the connections, the X-Forwarded-For headers can be set by
+// anyone. TRUSTED_PROXIES is a comma separated list of the CIDR ranges of the proxies in front of the service, e.g.
+// 10.0.0.0/8, whose X-Forwarded-For headers are read instead.
+func ipExtractor() (echo.IPExtractor, error) {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range strings.Split(proxies, ",") {
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(proxy))
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// passwordRules reads the requirements of new passwords, every variable is optional:
+// PASSWORD_MIN_LENGTH (6 by default), PASSWORD_MAX_LENGTH (64 by default), PASSWORD_CHARACTER_CLASSES (comma
+// separated list of uppercase, lowercase, number and special, uppercase,number,special by default, none to disable),
+// PASSWORD_BREACHED_LIST (file with one breached password per line) and PASSWORD_PERSONAL_INFO_CHECK (false to allow
+// the name or phone number)
+func passwordRules() ([]password.Rule, error) {
+	minLength := 6
+	if raw := os.Getenv("PASSWORD_MIN_LENGTH"); raw != "" {
+		var err error
+		minLength, err = strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("PASSWORD_MIN_LENGTH: %w", err)
+		}
+	}
+	maxLength := 64
+	if raw := os.Getenv("PASSWORD_MAX_LENGTH"); raw != "" {
+		var err error
+		maxLength, err = strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("PASSWORD_MAX_LENGTH: %w", err)
+		}
+	}
+	if maxLength < minLength {
+		return nil, fmt.Errorf("PASSWORD_MAX_LENGTH: %d is below PASSWORD_MIN_LENGTH %d", maxLength, minLength)
+	}
+	rules := []password.Rule{password.MinLength(minLength), password.MaxLength(maxLength)}
+
+	classes := []password.CharacterClass{password.ClassUppercase, password.ClassNumber, password.ClassSpecial}
+	if raw := os.Getenv("PASSWORD_CHARACTER_CLASSES"); raw == "none" {
+		classes = nil
+	} else if raw != "" {
+		classes = nil
+		for _, name := range strings.Split(raw, ",") {
+			class, ok := password.ParseCharacterClass(name)
+			if !ok {
+				return nil, fmt.Errorf("PASSWORD_CHARACTER_CLASSES: unknown class %q", name)
+			}
+			classes = append(classes, class)
+		}
+	}
+	rules = append(rules, password.RequireClasses(classes...)...)
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		breached, err := password.LoadBreachedList(path, 0.001)
+		if err != nil {
+			return nil, fmt.Errorf("PASSWORD_BREACHED_LIST: %w", err)
+		}
+		rules = append(rules, password.NotBreached(breached))
+	}
+
+	if os.Getenv("PASSWORD_PERSONAL_INFO_CHECK") != "false" {
+		rules = append(rules, password.NoPersonalInfo())
+	}
+
+	return rules, nil
+}
+
+// passwordPolicy reads the password hashing policy, every variable is optional and defaults to password.DefaultPolicy:
+// PASSWORD_HASH_ALGORITHM (argon2id or bcrypt), PASSWORD_ARGON2_MEMORY (KiB), PASSWORD_ARGON2_ITERATIONS,
+// PASSWORD_ARGON2_PARALLELISM and PASSWORD_BCRYPT_COST
+func passwordPolicy() (password.Policy, error) {
+	policy := password.DefaultPolicy
+
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		policy.Algorithm = algorithm
+	}
+
+	uintVariables := []struct {
+		name    string
+		bitSize int
+		set     func(value uint64)
+	}{
+		{"PASSWORD_ARGON2_MEMORY", 32, func(value uint64) { policy.Argon2id.Memory = uint32(value) }},
+		{"PASSWORD_ARGON2_ITERATIONS", 32, func(value uint64) { policy.Argon2id.Iterations = uint32(value) }},
+		{"PASSWORD_ARGON2_PARALLELISM", 8, func(value uint64) { policy.Argon2id.Parallelism = uint8(value) }},
+		{"PASSWORD_BCRYPT_COST", 8, func(value uint64) { policy.BcryptCost = int(value) }},
+	}
+	for _, variable := range uintVariables {
+		raw := os.Getenv(variable.name)
+		if raw == "" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(raw, 10, variable.bitSize)
+		if err != nil {
+			return policy, fmt.Errorf("%s: %w", variable.name, err)
+		}
+		variable.set(value)
+	}
+
+	return policy, nil
+}
+
+// routeDeprecations deprecates the unversioned routes since LEGACY_ROUTES_DEPRECATED_AT (RFC 3339), until
+// LEGACY_ROUTES_SUNSET (RFC 3339) which announces when they are removed, and ROUTE_DEPRECATIONS (JSON, see
+// handler.ParseRouteDeprecations) overrides the deprecation of single routes
+func routeDeprecations() (handler.RouteDeprecations, error) {
+	deprecatedAt := legacyRoutesDeprecatedAt
+	if value := os.Getenv("LEGACY_ROUTES_DEPRECATED_AT"); value != "" {
+		var err error
+		deprecatedAt, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("LEGACY_ROUTES_DEPRECATED_AT: %w", err)
+		}
+	}
+
+	sunsetAt := deprecatedAt.Add(legacyRoutesSunsetAfter)
+	if value := os.Getenv("LEGACY_ROUTES_SUNSET"); value != "" {
+		var err error
+		sunsetAt, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("LEGACY_ROUTES_SUNSET: %w", err)
+		}
+	}
+
+	legacyDeprecation := handler.RouteDeprecation{DeprecatedAt: deprecatedAt, Sunset: &sunsetAt}
+
+	deprecations, err := handler.DeprecateUnversionedRoutes("/v1", legacyDeprecation)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := handler.ParseRouteDeprecations(os.Getenv("ROUTE_DEPRECATIONS"))
+	if err != nil {
+		return nil, err
+	}
+
+	return deprecations.Merge(overrides), nil
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hasbiasshidiq/simple-profile/repository"
+)
+
+// runMigrate is the migrate subcommand, it applies the schema in database.sql to the database of DATABASE_URL. The
+// schema only creates what is missing, it brings the databases created by its previous versions up to date and
+// runs before every deploy of the server.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	schemaPath := flags.String("schema", "database.sql", "the SQL schema to apply")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	schema, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	repo := repository.NewRepository(repository.NewRepositoryOptions{Dsn: os.Getenv("DATABASE_URL")})
+	defer repo.Db.Close()
+
+	if err := repo.ApplySchema(context.Background(), string(schema)); err != nil {
+		fmt.Fprintln(os.Stderr, "the schema can't be applied:", err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "the schema of", *schemaPath, "is applied")
+	return 0
+}
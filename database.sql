@@ -1,5 +1,7 @@
 /**
   This is the SQL script that will be used to initialize the database schema.
+  It's applied by `main migrate` on every deploy, so every statement must be re-runnable: the columns added to an
+  existing table are added by ALTER TABLE ... ADD COLUMN IF NOT EXISTS, not in its CREATE TABLE.
   We will evaluate you based on how well you design your database.
   1. How you design the tables.
   2. How you choose the data types and keys.
@@ -13,13 +15,79 @@ CREATE TABLE IF NOT EXISTS profiles (
     id BIGSERIAL PRIMARY KEY,
     full_name VARCHAR(60) NOT NULL,
     country_code VARCHAR(5) NOT NULL DEFAULT '+62',
-    phone_number VARCHAR(20) NOT NULL UNIQUE,
-    password VARCHAR(128) NOT NULL,
+    phone_number VARCHAR(20) NOT NULL,
+    password VARCHAR(255) NOT NULL,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ,
     deleted_at TIMESTAMPTZ
 );
 
+-- The phone numbers are unique per country among the profiles not deleted, the number of a deleted profile can be
+-- registered again. The databases created before the E.164 numbers have them unique across countries, the ones
+-- created before the deletions have them unique among the deleted profiles too.
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_phone_number_key;
+DROP INDEX IF EXISTS profiles_country_code_phone_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_active_phone_number_key ON profiles (country_code, phone_number)
+    WHERE deleted_at IS NULL;
+-- The national numbers never start with the trunk prefix 0, the numbers registered before it was dropped after the
+-- calling code are stored without it. The ones registered twice that way keep it and are left to be merged by hand.
+UPDATE profiles p SET phone_number = substring(p.phone_number from 2)
+    WHERE p.phone_number LIKE '0%' AND NOT EXISTS (
+        SELECT 1 FROM profiles o
+        WHERE o.country_code = p.country_code AND o.phone_number = substring(p.phone_number from 2)
+            AND o.deleted_at IS NULL);
+
+-- The PHC strings of argon2id are longer than the bcrypt hashes the databases created before were sized for
+ALTER TABLE profiles ALTER COLUMN password TYPE VARCHAR(255);
+
+-- Suspended profiles can't log in, status_reason and status_changed_by record the last change of an operator. The
+-- deleted profiles are the ones with a deleted_at, the databases created before allowed a 'deleted' status too.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS status_reason VARCHAR(255);
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS status_changed_by INT8;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+-- the constraints are created again rather than added with the column, which would add them on every run
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_status_changed_by_fkey;
+ALTER TABLE profiles ADD CONSTRAINT profiles_status_changed_by_fkey
+    FOREIGN KEY (status_changed_by) REFERENCES profiles(id) ON DELETE SET NULL;
+UPDATE profiles SET status = 'active', deleted_at = COALESCE(deleted_at, status_changed_at, NOW())
+    WHERE status = 'deleted';
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_status_check;
+ALTER TABLE profiles ADD CONSTRAINT profiles_status_check CHECK (status IN ('active', 'suspended'));
+
+-- Incremented by every update of the profile, it is the ETag of the profile API
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS version INT8 NOT NULL DEFAULT 1;
+
+-- Optional attributes, email_verified_at is cleared by every change of the email
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS email VARCHAR(254);
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS date_of_birth DATE;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS gender VARCHAR(16);
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS locale VARCHAR(35);
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+-- when the last verification link of the email was sent, to limit the resends
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS email_verification_sent_at TIMESTAMPTZ;
+-- An address belongs to the profile which verified it first, the unverified ones can be set by several profiles so
+-- nobody can hold an address without owning it. The databases created before have the emails unique whether verified
+-- or not.
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_email_key;
+DROP INDEX IF EXISTS profiles_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_verified_email_key ON profiles (email)
+    WHERE email_verified_at IS NOT NULL AND deleted_at IS NULL;
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS profiles_gender_check;
+ALTER TABLE profiles ADD CONSTRAINT profiles_gender_check CHECK (gender IN ('female', 'male', 'other'));
+
+-- Custom attributes, validated against the latest profile_attribute_schemas
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+-- Prefix of the keys of the avatar thumbnails in the blob store, e.g. avatars/12/9f3c2a71
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(255);
+
+-- Indexes of the admin search, see ListProfiles. The ID breaks the ties of the keyset pagination.
+CREATE INDEX IF NOT EXISTS profiles_created_at_idx ON profiles (created_at, id);
+CREATE INDEX IF NOT EXISTS profiles_full_name_idx ON profiles (lower(full_name), id);
+CREATE INDEX IF NOT EXISTS profiles_full_name_prefix_idx ON profiles (lower(full_name) text_pattern_ops);
+
 
 CREATE TABLE IF NOT EXISTS profile_metadata (
     id BIGSERIAL PRIMARY KEY,
@@ -27,4 +95,165 @@ CREATE TABLE IF NOT EXISTS profile_metadata (
     login_attempt INT8 NOT NULL DEFAULT 0,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ
-);
\ No newline at end of file
+);
+
+-- Replaced password hashes, used to prevent reusing recent passwords
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS password_history_profile_id_idx ON password_history (profile_id, created_at DESC);
+
+-- TOTP authenticators, the secret is only used for the login once confirmed
+CREATE TABLE IF NOT EXISTS profile_mfa (
+    profile_id INT8 PRIMARY KEY REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    last_used_step INT8 NOT NULL DEFAULT 0,
+    confirmed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ
+);
+
+-- The secrets are encrypted with MFA_SECRET_KEY, the ones stored before are plain base32
+ALTER TABLE profile_mfa ALTER COLUMN secret TYPE VARCHAR(255);
+-- Wrong codes in a row, the second factor is locked until locked_until once there are too many
+ALTER TABLE profile_mfa ADD COLUMN IF NOT EXISTS failed_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE profile_mfa ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+-- Logins waiting for their second factor, the challenge tokens carry the ID. The wrong codes entered for each
+-- are counted in attempts.
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (profile_id, code_hash)
+);
+
+-- One-time codes sent by SMS for the passwordless login, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS login_otps (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS login_otps_profile_id_idx ON login_otps (profile_id, created_at DESC);
+
+-- The codes requested for a phone number in E.164 format, registered or not, so that the throttling doesn't tell
+-- which numbers are registered. window_count counts the requests since window_started_at.
+CREATE TABLE IF NOT EXISTS login_otp_requests (
+    phone_number VARCHAR(20) PRIMARY KEY,
+    last_requested_at TIMESTAMPTZ NOT NULL,
+    window_started_at TIMESTAMPTZ NOT NULL,
+    window_count INT NOT NULL
+);
+
+-- Logins on a device, the access tokens carry the session ID and are refused once it's revoked
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    device_name VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sessions_profile_id_idx ON sessions (profile_id, last_seen_at DESC);
+
+-- Audit trail of the security events of a profile, e.g. logins and phone number changes. Rows are never updated
+-- or deleted, so the profiles are referenced without cascading.
+CREATE TABLE IF NOT EXISTS security_events (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent TEXT NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS security_events_profile_id_idx ON security_events (profile_id, id DESC);
+
+-- The events outlive their profile, even when its row is deleted, so profile_id isn't a foreign key. The databases
+-- created before have one, which would refuse deleting the profiles.
+ALTER TABLE security_events DROP CONSTRAINT IF EXISTS security_events_profile_id_fkey;
+
+CREATE OR REPLACE FUNCTION security_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS security_events_append_only ON security_events;
+CREATE TRIGGER security_events_append_only
+    BEFORE UPDATE OR DELETE ON security_events
+    FOR EACH ROW EXECUTE FUNCTION security_events_append_only();
+
+-- Roles of the operators, e.g. support or admin. Every profile is a user, which is not stored.
+-- The permissions of each role are configured with RBAC_POLICY.
+CREATE TABLE IF NOT EXISTS profile_roles (
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    role VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (profile_id, role)
+);
+
+-- Copies of the personal data of the profiles requested with /profile/export. The archive is generated in the
+-- background and can be downloaded until expires_at, only the latest export of a profile is kept.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    profile_id INT8 NOT NULL REFERENCES profiles(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    archive BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS data_exports_profile_id_idx ON data_exports (profile_id, id DESC);
+
+-- Requests made with an Idempotency-Key header and their responses, replayed to the retries until expires_at.
+-- status_code is null while the request is in progress. The expired keys can be purged at any time.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(64) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status_code INT,
+    header JSONB,
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- Schemas of the custom attributes of the profiles set by the operators, the latest one applies and the previous
+-- ones are kept. visibility maps the attributes to read_write, read_only or hidden, read_write when absent.
+CREATE TABLE IF NOT EXISTS profile_attribute_schemas (
+    id BIGSERIAL PRIMARY KEY,
+    schema JSONB NOT NULL,
+    visibility JSONB NOT NULL DEFAULT '{}',
+    created_by INT8 REFERENCES profiles(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
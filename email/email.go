@@ -0,0 +1,78 @@
+// Package email sends emails to the users. The providers implement Sender,
+// LogSender and FileSender are meant for development and tests.
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender delivers a plain text email to an address
+type Sender interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// LogSender writes the emails to the standard logger instead of sending them
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, to string, subject string, body string) error {
+	log.Printf("email to %s : %s : %s", to, subject, body)
+	return nil
+}
+
+// Message is an email written by FileSender
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// FileSender appends the emails to a mailbox file, one JSON object per line
+type FileSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileSender) Send(ctx context.Context, to string, subject string, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(Message{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+		SentAt:  time.Now(),
+	})
+}
+
+// ReadMessages returns the emails written by a FileSender to path
+func ReadMessages(path string) ([]Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := []Message{}
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var message Message
+		if err := decoder.Decode(&message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
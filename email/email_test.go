@@ -0,0 +1,36 @@
+package email
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mailbox.jsonl")
+	sender := &FileSender{Path: path}
+
+	assert.NoError(t, sender.Send(context.Background(), "bakri@example.com", "Verify", "first"))
+	assert.NoError(t, sender.Send(context.Background(), "siti@example.com", "Verify", "second"))
+
+	messages, err := ReadMessages(path)
+	if assert.NoError(t, err) && assert.Len(t, messages, 2) {
+		assert.Equal(t, "bakri@example.com", messages[0].To)
+		assert.Equal(t, "Verify", messages[0].Subject)
+		assert.Equal(t, "first", messages[0].Body)
+		assert.Equal(t, "siti@example.com", messages[1].To)
+		assert.False(t, messages[1].SentAt.IsZero())
+	}
+}
+
+func TestFileSenderUnwritablePath(t *testing.T) {
+	sender := &FileSender{Path: filepath.Join(t.TempDir(), "missing", "mailbox.jsonl")}
+	assert.Error(t, sender.Send(context.Background(), "bakri@example.com", "Verify", "body"))
+}
+
+func TestLogSender(t *testing.T) {
+	var sender Sender = LogSender{}
+	assert.NoError(t, sender.Send(context.Background(), "bakri@example.com", "Verify", "body"))
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultActivityPageSize is the number of events of a page when the limit is not given
+const defaultActivityPageSize = 20
+
+// Login methods, recorded in the details of the login events
+const (
+	loginMethodPassword     = "password"
+	loginMethodOTP          = "otp"
+	loginMethodTOTP         = "totp"
+	loginMethodRecoveryCode = "recovery_code"
+)
+
+// operatorEventTypes are the events of the actions of the operators on a profile. The users see them without anything
+// about the operator, whose identity and reason are only kept in the status columns of the profile for the admins.
+var operatorEventTypes = map[string]bool{
+	repository.SecurityEventProfileSuspended:   true,
+	repository.SecurityEventProfileUnsuspended: true,
+}
+
+func (s *Server) GetProfileActivity(ctx echo.Context, params generated.GetProfileActivityParams) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var beforeID uint64
+	if params.Cursor != nil {
+		beforeID, err = decodeActivityCursor(*params.Cursor)
+		if err != nil {
+			responsePayload := errorResponse(ctx, msgInvalidCursor)
+			return ctx.JSON(http.StatusBadRequest, responsePayload)
+		}
+	}
+
+	limit := defaultActivityPageSize
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	// one more event than the page is fetched to know whether there is a next page
+	events, err := s.Repository.GetSecurityEvents(uint64(userID), beforeID, limit+1)
+	if err != nil {
+		log.Println("error fetch security events : ", err)
+		return err
+	}
+
+	resp := generated.ActivityResponse{Events: []generated.SecurityEvent{}}
+	if len(events) > limit {
+		events = events[:limit]
+		nextCursor := encodeActivityCursor(events[limit-1].ID)
+		resp.NextCursor = &nextCursor
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, userSecurityEvent(event))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// recordSecurityEvent appends an event to the audit trail of the profile with the IP address and User-Agent of
+// the request. A failure is only logged, the action has already happened and must not be reported as failed.
+func (s *Server) recordSecurityEvent(ctx echo.Context, profileID uint64, eventType string, details map[string]string) {
+	event := repository.SecurityEvent{
+		ProfileID: profileID,
+		Type:      eventType,
+		IPAddress: clientIP(ctx),
+		UserAgent: ctx.Request().UserAgent(),
+		Details:   details,
+	}
+
+	err := s.Repository.CreateSecurityEvent(event)
+	if err != nil {
+		log.Printf("error record security event %s of profile %d : %v", eventType, profileID, err)
+	}
+}
+
+// userSecurityEvent returns the event as the user sees it, without the operator of the operatorEventTypes
+func userSecurityEvent(event repository.SecurityEvent) generated.SecurityEvent {
+	details := event.Details
+	if details == nil || operatorEventTypes[event.Type] {
+		// the events recorded before carry the reason and the operator, the trail can't be rewritten
+		details = map[string]string{}
+	}
+	if operatorEventTypes[event.Type] {
+		event.IPAddress, event.UserAgent = "", ""
+	}
+	return generated.SecurityEvent{
+		Id:        int(event.ID),
+		Type:      generated.SecurityEventType(event.Type),
+		IpAddress: event.IPAddress,
+		UserAgent: event.UserAgent,
+		Details:   details,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// recordOperatorSecurityEvent appends an action of an operator to the audit trail of the profile. Unlike
+// recordSecurityEvent it records nothing of the operator, e.g. the IP address of their request, see operatorEventTypes.
+func (s *Server) recordOperatorSecurityEvent(profileID uint64, eventType string) {
+	event := repository.SecurityEvent{
+		ProfileID: profileID,
+		Type:      eventType,
+	}
+
+	err := s.Repository.CreateSecurityEvent(event)
+	if err != nil {
+		log.Printf("error record security event %s of profile %d : %v", eventType, profileID, err)
+	}
+}
+
+// encodeActivityCursor returns the opaque cursor of the page following the event with the given ID
+func encodeActivityCursor(lastID uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(lastID, 10)))
+}
+
+// decodeActivityCursor returns the ID of the last event of the previous page
+func decodeActivityCursor(cursor string) (lastID uint64, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err = strconv.ParseUint(string(decoded), 10, 64)
+	if err == nil && lastID == 0 {
+		err = errors.New("cursor of an unknown event")
+	}
+	return lastID, err
+}
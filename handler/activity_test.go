@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProfileActivity(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	now := time.Now()
+	events := []repository.SecurityEvent{
+		{ID: 9, ProfileID: 1, Type: repository.SecurityEventPhoneNumberChanged, IPAddress: "192.0.2.1", Details: map[string]string{"previous_phone_number": "+6281234567", "phone_number": "+6289627117"}, CreatedAt: now},
+		{ID: 7, ProfileID: 1, Type: repository.SecurityEventLoginSucceeded, IPAddress: "192.0.2.1", UserAgent: "okhttp/4.12.0", Details: map[string]string{"method": "password"}, CreatedAt: now.Add(-time.Minute)},
+		{ID: 4, ProfileID: 1, Type: repository.SecurityEventLoginFailed, IPAddress: "192.0.2.2", CreatedAt: now.Add(-time.Hour)},
+	}
+
+	t.Run("First Page", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/activity?limit=2", token)
+
+		limit := 2
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetSecurityEvents(uint64(1), uint64(0), 3).Return(events, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileActivity(ctx, generated.GetProfileActivityParams{Limit: &limit})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ActivityResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Events, 2) {
+				assert.Equal(t, generated.SecurityEventType("phone_number_changed"), resp.Events[0].Type)
+				assert.Equal(t, "+6281234567", resp.Events[0].Details["previous_phone_number"])
+				assert.Equal(t, "password", resp.Events[1].Details["method"])
+			}
+			if assert.NotNil(t, resp.NextCursor) {
+				lastID, err := decodeActivityCursor(*resp.NextCursor)
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(7), lastID)
+			}
+		}
+	})
+
+	t.Run("Operator Actions", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/activity", token)
+
+		suspended := []repository.SecurityEvent{
+			{ID: 11, ProfileID: 1, Type: repository.SecurityEventProfileSuspended, IPAddress: "10.0.0.8", UserAgent: "Mozilla/5.0", Details: map[string]string{"reason": "internal note", "operator_id": "3"}, CreatedAt: now},
+		}
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetSecurityEvents(uint64(1), uint64(0), defaultActivityPageSize+1).Return(suspended, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileActivity(ctx, generated.GetProfileActivityParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "internal note")
+			assert.NotContains(t, rec.Body.String(), "10.0.0.8")
+
+			var resp generated.ActivityResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Events, 1) {
+				assert.Equal(t, generated.SecurityEventType("profile_suspended"), resp.Events[0].Type)
+				assert.Empty(t, resp.Events[0].Details)
+				assert.Empty(t, resp.Events[0].IpAddress)
+				assert.Empty(t, resp.Events[0].UserAgent)
+			}
+		}
+	})
+
+	t.Run("Last Page", func(t *testing.T) {
+		cursor := encodeActivityCursor(7)
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/activity?cursor="+cursor, token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetSecurityEvents(uint64(1), uint64(7), defaultActivityPageSize+1).Return(events[2:], nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileActivity(ctx, generated.GetProfileActivityParams{Cursor: &cursor})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ActivityResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Len(t, resp.Events, 1)
+			assert.Nil(t, resp.NextCursor)
+			assert.Equal(t, map[string]string{}, resp.Events[0].Details)
+		}
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		cursor := "not-a-cursor"
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/activity?cursor="+cursor, token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileActivity(context, generated.GetProfileActivityParams{Cursor: &cursor})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Limit Out Of Range", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/activity?limit=500", token)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileActivity(ctx, generated.GetProfileActivityParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+}
+
+func TestRecordSecurityEventIgnoresForgedAddresses(t *testing.T) {
+	e := echo.New()
+	e.IPExtractor = echo.ExtractIPDirect()
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, "+strings.Repeat("x", 60))
+	req.Header.Set("User-Agent", "okhttp/4.12.0")
+	context := e.NewContext(req, httptest.NewRecorder())
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(mockCtrl)
+	mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+		ProfileID: 1,
+		Type:      repository.SecurityEventLoginFailed,
+		IPAddress: "192.0.2.1",
+		UserAgent: "okhttp/4.12.0",
+	}).Return(nil).Times(1)
+	mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+	mockServer.recordSecurityEvent(context, 1, repository.SecurityEventLoginFailed, nil)
+}
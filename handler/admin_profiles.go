@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultAdminProfilesPageSize is the number of profiles of a page when the limit is not given
+const defaultAdminProfilesPageSize = 20
+
+// adminProfilesCursor is the position of the last profile of a page, it is only valid with the sort it was
+// created with
+type adminProfilesCursor struct {
+	Sort string `json:"sort"`
+	repository.ProfileCursor
+}
+
+func (s *Server) GetAdminProfiles(ctx echo.Context, params generated.GetAdminProfilesParams) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	_, err = s.extractOperatorIDFromToken(token, rbac.PermissionProfilesRead)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	filter := repository.ProfileFilter{
+		Sort:        repository.ProfileSortCreatedAtDesc,
+		Deleted:     repository.DeletedExclude,
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+		Limit:       defaultAdminProfilesPageSize,
+	}
+	if params.Sort != nil {
+		filter.Sort = string(*params.Sort)
+	}
+	if params.Deleted != nil {
+		filter.Deleted = string(*params.Deleted)
+	}
+	if params.Status != nil {
+		filter.Status = string(*params.Status)
+	}
+	if params.Limit != nil {
+		filter.Limit = *params.Limit
+	}
+	if params.Name != nil {
+		filter.NamePrefix = *params.Name
+	}
+	if params.PhoneNumber != nil {
+		phoneNumber, err := s.PhoneParser.Parse(*params.PhoneNumber)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, phoneNumberError("phone_number", err)))
+		}
+		filter.CountryCode = phoneNumber.CountryCode
+		filter.PhoneNumber = phoneNumber.NationalNumber
+	}
+	if params.Cursor != nil {
+		after, err := decodeAdminProfilesCursor(*params.Cursor, filter.Sort)
+		if err != nil {
+			responsePayload := errorResponse(ctx, msgInvalidCursor)
+			return ctx.JSON(http.StatusBadRequest, responsePayload)
+		}
+		filter.After = &after
+	}
+
+	// one more profile than the page is fetched to know whether there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+	profiles, err := s.Repository.ListProfiles(filter)
+	if err != nil {
+		log.Println("error list profiles : ", err)
+		return err
+	}
+
+	resp := generated.AdminProfileListResponse{Profiles: []generated.AdminProfile{}}
+	if len(profiles) > pageSize {
+		profiles = profiles[:pageSize]
+		last := profiles[pageSize-1]
+		nextCursor := encodeAdminProfilesCursor(filter.Sort, repository.ProfileCursor{
+			ID:        last.ID,
+			CreatedAt: last.CreatedAt,
+			FullName:  last.FullName,
+		})
+		resp.NextCursor = &nextCursor
+	}
+	for _, profile := range profiles {
+		resp.Profiles = append(resp.Profiles, adminProfile(profile))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) GetAdminProfilesId(ctx echo.Context, id int) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	_, err = s.extractOperatorIDFromToken(token, rbac.PermissionProfilesRead)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	if id < 1 {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	profile, err := s.Repository.GetProfileByIDIncludingDeleted(uint64(id))
+	if errors.Is(err, sql.ErrNoRows) {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch profile : ", err)
+		return err
+	}
+
+	roles, err := s.Repository.GetProfileRoles(profile.ID)
+	if err != nil {
+		log.Println("error fetch profile roles : ", err)
+		return err
+	}
+
+	resp := generated.AdminProfileDetailResponse{
+		Profile:    adminProfile(profile),
+		Roles:      []string{},
+		Attributes: profile.Attributes,
+	}
+	if resp.Attributes == nil {
+		resp.Attributes = map[string]interface{}{}
+	}
+	resp.Roles = append(resp.Roles, roles...)
+
+	// the metadata is created by the first login
+	metadata, err := s.Repository.GetProfileMetaData(profile.ID)
+	switch {
+	case err == nil:
+		lastLoginAt := metadata.CreatedAt
+		if metadata.UpdatedAt != nil {
+			lastLoginAt = *metadata.UpdatedAt
+		}
+		resp.Metadata = &generated.AdminProfileMetadata{
+			LoginAttempt: int(metadata.LoginAttempt),
+			FirstLoginAt: metadata.CreatedAt,
+			LastLoginAt:  &lastLoginAt,
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Println("error fetch profile metadata : ", err)
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func adminProfile(profile repository.Profile) generated.AdminProfile {
+	resp := generated.AdminProfile{
+		Id:              int(profile.ID),
+		FullName:        profile.FullName,
+		PhoneNumber:     profile.CountryCode + profile.PhoneNumber,
+		CreatedAt:       profile.CreatedAt,
+		UpdatedAt:       profile.UpdatedAt,
+		DeletedAt:       profile.DeletedAt,
+		Status:          generated.ProfileStatus(profile.Status),
+		StatusReason:    profile.StatusReason,
+		StatusChangedAt: profile.StatusChangedAt,
+	}
+	if profile.StatusChangedBy != nil {
+		changedBy := int(*profile.StatusChangedBy)
+		resp.StatusChangedBy = &changedBy
+	}
+	return resp
+}
+
+// encodeAdminProfilesCursor returns the opaque cursor of the page following the given profile
+func encodeAdminProfilesCursor(sort string, last repository.ProfileCursor) string {
+	encoded, _ := json.Marshal(adminProfilesCursor{Sort: sort, ProfileCursor: last})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeAdminProfilesCursor returns the last profile of the previous page, the cursor must have been created with
+// the same sort
+func decodeAdminProfilesCursor(cursor string, sort string) (last repository.ProfileCursor, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return last, err
+	}
+
+	var position adminProfilesCursor
+	err = json.Unmarshal(decoded, &position)
+	if err != nil {
+		return last, err
+	}
+	if position.Sort != sort || position.ID == 0 {
+		return last, errors.New("cursor of another listing")
+	}
+	return position.ProfileCursor, nil
+}
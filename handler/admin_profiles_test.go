@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAdminProfiles(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deletedAt := now.Add(-time.Minute)
+	profiles := []repository.Profile{
+		{ID: 12, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117", CreatedAt: now, Status: repository.ProfileStatusActive},
+		{ID: 9, FullName: "Bakti", CountryCode: "+62", PhoneNumber: "81234567", CreatedAt: now.Add(-time.Hour), DeletedAt: &deletedAt, Status: repository.ProfileStatusActive},
+		{ID: 4, FullName: "Bakso", CountryCode: "+62", PhoneNumber: "87654321", CreatedAt: now.Add(-2 * time.Hour), Status: repository.ProfileStatusActive},
+	}
+
+	t.Run("First Page", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles?limit=2&name=bak&deleted=include", token)
+
+		limit := 2
+		name := "bak"
+		deleted := generated.Include
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().ListProfiles(repository.ProfileFilter{
+			NamePrefix: "bak",
+			Deleted:    repository.DeletedInclude,
+			Sort:       repository.ProfileSortCreatedAtDesc,
+			Limit:      3,
+		}).Return(profiles, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetAdminProfiles(ctx, generated.GetAdminProfilesParams{Limit: &limit, Name: &name, Deleted: &deleted})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.AdminProfileListResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Profiles, 2) {
+				assert.Equal(t, "+6289627117", resp.Profiles[0].PhoneNumber)
+				assert.Nil(t, resp.Profiles[0].DeletedAt)
+				assert.NotNil(t, resp.Profiles[1].DeletedAt)
+			}
+			if assert.NotNil(t, resp.NextCursor) {
+				last, err := decodeAdminProfilesCursor(*resp.NextCursor, repository.ProfileSortCreatedAtDesc)
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(9), last.ID)
+				assert.True(t, last.CreatedAt.Equal(now.Add(-time.Hour)))
+			}
+		}
+	})
+
+	t.Run("Next Page By Phone Number", func(t *testing.T) {
+		after := repository.ProfileCursor{ID: 9, FullName: "Bakti"}
+		cursor := encodeAdminProfilesCursor(repository.ProfileSortFullNameAsc, after)
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles", token)
+
+		phoneNumber := "0876-5432-1"
+		sort := generated.FullNameAsc
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().ListProfiles(repository.ProfileFilter{
+			CountryCode: "+62",
+			PhoneNumber: "87654321",
+			Deleted:     repository.DeletedExclude,
+			Sort:        repository.ProfileSortFullNameAsc,
+			After:       &after,
+			Limit:       defaultAdminProfilesPageSize + 1,
+		}).Return(profiles[2:], nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetAdminProfiles(context, generated.GetAdminProfilesParams{Cursor: &cursor, PhoneNumber: &phoneNumber, Sort: &sort})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.AdminProfileListResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Len(t, resp.Profiles, 1)
+			assert.Nil(t, resp.NextCursor)
+		}
+	})
+
+	t.Run("Cursor Of Another Sort", func(t *testing.T) {
+		cursor := encodeAdminProfilesCursor(repository.ProfileSortCreatedAtDesc, repository.ProfileCursor{ID: 9})
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles", token)
+
+		sort := generated.FullNameDesc
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetAdminProfiles(context, generated.GetAdminProfilesParams{Cursor: &cursor, Sort: &sort})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "invalid_cursor", resp.Code)
+		}
+	})
+
+	t.Run("Permission Missing", func(t *testing.T) {
+		userToken, _ := createToken(operator, 1, []string{rbac.RoleUser}, nil)
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles", userToken)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetAdminProfiles(ctx, generated.GetAdminProfilesParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "permission_denied", resp.Code)
+		}
+	})
+}
+
+func TestGetAdminProfilesId(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	updatedAt := now.Add(-time.Minute)
+	profile := repository.Profile{ID: 12, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117", CreatedAt: now.Add(-time.Hour), Status: repository.ProfileStatusActive}
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles/12", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByIDIncludingDeleted(uint64(12)).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(12)).Return([]string{rbac.RoleSupport}, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMetaData(uint64(12)).Return(repository.ProfileMetaData{ProfileID: 12, LoginAttempt: 3, CreatedAt: now.Add(-time.Hour), UpdatedAt: &updatedAt}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetAdminProfilesId(ctx, 12)
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.AdminProfileDetailResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, 12, resp.Profile.Id)
+			assert.Equal(t, []string{"support"}, resp.Roles)
+			if assert.NotNil(t, resp.Metadata) {
+				assert.Equal(t, 3, resp.Metadata.LoginAttempt)
+				assert.True(t, resp.Metadata.LastLoginAt.Equal(updatedAt))
+			}
+		}
+	})
+
+	t.Run("Never Logged In", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles/12", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByIDIncludingDeleted(uint64(12)).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(12)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMetaData(uint64(12)).Return(repository.ProfileMetaData{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetAdminProfilesId(context, 12)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.AdminProfileDetailResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, []string{}, resp.Roles)
+			assert.Nil(t, resp.Metadata)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/admin/profiles/404", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByIDIncludingDeleted(uint64(404)).Return(repository.Profile{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetAdminProfilesId(context, 404)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/labstack/echo/v4"
+)
+
+// bearerAuthScheme is the name of the security scheme of the access tokens in api.yml
+const bearerAuthScheme = "bearerAuth"
+
+// requiredPermissions returns the scopes of the bearerAuth requirement of the route, e.g. for
+//
+//	security:
+//	  - bearerAuth: [profiles:read]
+//
+// it returns [profiles:read]. The routes without scopes are open to every authenticated user.
+func requiredPermissions(route *routers.Route) []string {
+	if route.Operation == nil {
+		return nil
+	}
+
+	security := route.Operation.Security
+	if security == nil && route.Spec != nil {
+		security = &route.Spec.Security
+	}
+	if security == nil {
+		return nil
+	}
+
+	for _, requirement := range *security {
+		if scopes, ok := requirement[bearerAuthScheme]; ok {
+			return scopes
+		}
+	}
+	return nil
+}
+
+// authorize checks that the access token grants the permissions required by the route, it returns the message
+// of the 403 response when it doesn't. The token itself is authenticated again by the handler, which also checks
+// its session.
+func authorize(ctx echo.Context, route *routers.Route) (denied messageCode, ok bool) {
+	required := requiredPermissions(route)
+	if len(required) == 0 {
+		return "", true
+	}
+
+	token, err := extractToken(ctx)
+	if err != nil {
+		return msgInvalidToken, false
+	}
+
+	scopes, err := parseAccessTokenScopes(token)
+	if err != nil {
+		return msgInvalidToken, false
+	}
+
+	if missing := rbac.Missing(scopes, required); len(missing) > 0 {
+		return msgPermissionDenied, false
+	}
+
+	return "", true
+}
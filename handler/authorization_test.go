@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServerRequiring returns a server whose spec requires the given permissions on GET /profile
+func newServerRequiring(t *testing.T, mockRepository *repository.MockRepositoryInterface, permissions ...string) *Server {
+	swagger, err := generated.GetSwagger()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	swagger.Paths.Find("/profile").Get.Security = &openapi3.SecurityRequirements{
+		{bearerAuthScheme: permissions},
+	}
+
+	validator, err := NewCustomValidator(swagger)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	server := NewServer(NewServerOptions{Repository: mockRepository})
+	server.Validator = validator
+	return server
+}
+
+func TestAuthorize(t *testing.T) {
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117"}
+
+	t.Run("Permission Granted", func(t *testing.T) {
+		token, _ := createToken(profile, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+		context, rec, mockRepository := setupTestGetProfile(t, token)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockServer := newServerRequiring(t, mockRepository, rbac.PermissionProfilesRead)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetProfile)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Permission Missing", func(t *testing.T) {
+		token, _ := createToken(profile, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+		context, rec, mockRepository := setupTestGetProfile(t, token)
+
+		mockServer := newServerRequiring(t, mockRepository, rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetProfile)(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "permission_denied", resp.Code)
+		}
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		context, rec, mockRepository := setupTestGetProfile(t, "INVALIDTOKEN")
+
+		mockServer := newServerRequiring(t, mockRepository, rbac.PermissionProfilesRead)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetProfile)(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "invalid_token", resp.Code)
+		}
+	})
+}
+
+func TestAdminHandlersCheckPermissions(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	userToken, _ := createToken(operator, 1, []string{rbac.RoleUser}, nil)
+	supportToken, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+
+	// the handlers are called without the request middleware, they refuse the tokens missing the permission on
+	// their own
+	tests := []struct {
+		name    string
+		token   string
+		handler func(s *Server, ctx echo.Context) error
+	}{
+		{"List Profiles", userToken, func(s *Server, ctx echo.Context) error {
+			return s.GetAdminProfiles(ctx, generated.GetAdminProfilesParams{})
+		}},
+		{"Show Profile", userToken, func(s *Server, ctx echo.Context) error {
+			return s.GetAdminProfilesId(ctx, 12)
+		}},
+		{"Suspend", supportToken, func(s *Server, ctx echo.Context) error {
+			return s.PostAdminProfilesIdSuspend(ctx, 12)
+		}},
+		{"Unsuspend", supportToken, func(s *Server, ctx echo.Context) error {
+			return s.PostAdminProfilesIdUnsuspend(ctx, 12)
+		}},
+		{"Import", supportToken, func(s *Server, ctx echo.Context) error {
+			return s.PostAdminProfilesImport(ctx, generated.PostAdminProfilesImportParams{})
+		}},
+		{"Show Attribute Schema", userToken, func(s *Server, ctx echo.Context) error {
+			return s.GetAdminProfileAttributeSchema(ctx)
+		}},
+		{"Change Attribute Schema", supportToken, func(s *Server, ctx echo.Context) error {
+			return s.PutAdminProfileAttributeSchema(ctx)
+		}},
+		{"Change Attributes", supportToken, func(s *Server, ctx echo.Context) error {
+			return s.PutAdminProfilesIdAttributes(ctx, 12)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context, rec, mockRepository := setupTestSessions(t, http.MethodPost, "/", test.token)
+
+			mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+			mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+			if assert.NoError(t, test.handler(mockServer, context)) {
+				assert.Equal(t, http.StatusForbidden, rec.Code)
+
+				var resp generated.GeneralErrorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.Equal(t, "permission_denied", resp.Code)
+			}
+		})
+	}
+}
+
+func TestLoginGrantsRolePermissions(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	context, rec, mockRepository := setupTestLogin(t, `{}`)
+
+	mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+	mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return([]string{"auditor"}, nil).Times(1)
+	mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+	mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+	mockServer := NewServer(NewServerOptions{
+		Repository: mockRepository,
+		RolePolicy: rbac.Policy{"user": {"activity:read"}, "auditor": {"profiles:read", "activity:read"}},
+	})
+
+	if assert.NoError(t, mockServer.completeLogin(context, profile, loginMethodPassword)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp generated.LoginResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		scopes, err := parseAccessTokenScopes(resp.JwtToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"activity:read", "profiles:read"}, scopes)
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/hasbiasshidiq/simple-profile/avatar"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// avatarFormField is the field of the multipart form holding the picture
+const avatarFormField = "avatar"
+
+// avatarMaxUploadSize is the size of the largest picture accepted
+const avatarMaxUploadSize = 5 << 20
+
+// avatarFormOverhead leaves room for the boundaries and the other fields of the form around the picture
+const avatarFormOverhead = 64 << 10
+
+// avatarSizes are the sizes of the thumbnails of an avatar, the largest first
+var avatarSizes = []int{512, 256, 128}
+
+// avatarContentTypes are the media types of the accepted pictures, sniffed from their content
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// errAvatarTooLarge is returned by readAvatarUpload for a picture or a form exceeding the upload limit
+var errAvatarTooLarge = errors.New("avatar too large")
+
+func (s *Server) PutProfileAvatar(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	picture, err := readAvatarUpload(ctx)
+	switch {
+	case errors.Is(err, errAvatarTooLarge):
+		return ctx.JSON(http.StatusRequestEntityTooLarge, errorResponse(ctx, msgAvatarTooLarge))
+	case err != nil:
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	case picture == nil:
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, &ValidationError{Fields: []FieldValidationError{{
+			Field:  avatarFormField,
+			Rule:   "required",
+			Params: []string{},
+		}}}))
+	}
+
+	// the declared content type can't be trusted, the picture is identified by its first bytes
+	if !avatarContentTypes[http.DetectContentType(picture)] {
+		return ctx.JSON(http.StatusUnsupportedMediaType, errorResponse(ctx, msgAvatarUnsupported))
+	}
+
+	thumbnails, err := avatar.Process(picture, avatarSizes)
+	switch {
+	case errors.Is(err, avatar.ErrUnsupportedFormat):
+		return ctx.JSON(http.StatusUnsupportedMediaType, errorResponse(ctx, msgAvatarUnsupported))
+	case errors.Is(err, avatar.ErrTooLarge):
+		return ctx.JSON(http.StatusRequestEntityTooLarge, errorResponse(ctx, msgAvatarTooLarge))
+	case err != nil:
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, &ValidationError{Fields: []FieldValidationError{{
+			Field:  avatarFormField,
+			Rule:   "image",
+			Params: []string{},
+		}}}))
+	}
+
+	profile, err := s.Repository.GetProfileByID(userID)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	// every upload gets new keys, the clients and caches never see the previous picture under the new URLs
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	avatarKey := fmt.Sprintf("avatars/%d/%s", profile.ID, hex.EncodeToString(suffix))
+
+	for _, thumbnail := range thumbnails {
+		err := s.BlobStore.Put(ctx.Request().Context(), avatarThumbnailKey(avatarKey, thumbnail.Size), avatar.ContentType, thumbnail.Content)
+		if err != nil {
+			log.Printf("error store avatar %s : %v", avatarKey, err)
+			s.deleteAvatar(ctx, avatarKey)
+			return err
+		}
+	}
+
+	// conditional on the version read, an upload made meanwhile keeps its picture and the previous one isn't deleted
+	// while it's still referenced
+	updated, err := s.Repository.UpdateProfileByID(repository.Profile{ID: profile.ID, AvatarKey: &avatarKey}, []string{repository.ProfileFieldAvatar}, profile.Version)
+	if err != nil || !updated {
+		s.deleteAvatar(ctx, avatarKey)
+		if err != nil {
+			log.Println("error update avatar : ", err)
+			return err
+		}
+		responsePayload := errorResponse(ctx, msgProfileModified)
+		return ctx.JSON(http.StatusConflict, responsePayload)
+	}
+
+	if profile.AvatarKey != nil {
+		s.deleteAvatar(ctx, *profile.AvatarKey)
+	}
+
+	return ctx.JSON(http.StatusOK, s.avatarResponse(avatarKey))
+}
+
+// readAvatarUpload returns the content of the avatar field of the multipart form of the request, nil when the form
+// has no such field
+func readAvatarUpload(ctx echo.Context) ([]byte, error) {
+	req := ctx.Request()
+	req.Body = http.MaxBytesReader(ctx.Response(), req.Body, avatarMaxUploadSize+avatarFormOverhead)
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if errors.As(err, &maxBytesErr) {
+			return nil, errAvatarTooLarge
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != avatarFormField {
+			continue
+		}
+
+		picture, err := io.ReadAll(io.LimitReader(part, avatarMaxUploadSize+1))
+		if errors.As(err, &maxBytesErr) || len(picture) > avatarMaxUploadSize {
+			return nil, errAvatarTooLarge
+		}
+		return picture, err
+	}
+}
+
+// avatarThumbnailKey is the key of the thumbnail of the given size of the avatar
+func avatarThumbnailKey(avatarKey string, size int) string {
+	return fmt.Sprintf("%s-%d.jpg", avatarKey, size)
+}
+
+// avatarResponse lists the URLs of the thumbnails of the avatar
+func (s *Server) avatarResponse(avatarKey string) generated.Avatar {
+	resp := generated.Avatar{Thumbnails: []generated.AvatarThumbnail{}}
+	for _, size := range avatarSizes {
+		resp.Thumbnails = append(resp.Thumbnails, generated.AvatarThumbnail{
+			Size: size,
+			Url:  s.BlobStore.URL(avatarThumbnailKey(avatarKey, size)),
+		})
+	}
+	resp.Url = resp.Thumbnails[0].Url
+	return resp
+}
+
+// deleteAvatar removes the thumbnails of the avatar, a failure only leaves unused blobs behind
+func (s *Server) deleteAvatar(ctx echo.Context, avatarKey string) {
+	for _, size := range avatarSizes {
+		key := avatarThumbnailKey(avatarKey, size)
+		if err := s.BlobStore.Delete(ctx.Request().Context(), key); err != nil {
+			log.Printf("error delete avatar thumbnail %s : %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/hasbiasshidiq/simple-profile/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestPutAvatar(t *testing.T, token string, field string, content []byte) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile(field, "picture")
+	part.Write(content)
+	form.Close()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/profile/avatar", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func testPicture(t *testing.T, width int, height int) []byte {
+	var encoded bytes.Buffer
+	assert.NoError(t, png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return encoded.Bytes()
+}
+
+func TestPutProfileAvatar(t *testing.T) {
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "81234567"}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		dir := t.TempDir()
+		store := &storage.LocalStore{Dir: dir, BaseURL: "/blobs"}
+		previous := "avatars/1/0011223344556677"
+		for _, size := range avatarSizes {
+			assert.NoError(t, store.Put(context.Background(), avatarThumbnailKey(previous, size), "image/jpeg", []byte("previous")))
+		}
+
+		context, rec, mockRepository := setupTestPutAvatar(t, token, "avatar", testPicture(t, 600, 400))
+
+		var avatarKey string
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, AvatarKey: &previous, Version: 4}, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(gomock.Any(), []string{repository.ProfileFieldAvatar}, uint64(4)).DoAndReturn(func(input repository.Profile, fields []string, expectedVersion uint64) (bool, error) {
+			assert.Equal(t, uint64(1), input.ID)
+			if assert.NotNil(t, input.AvatarKey) {
+				avatarKey = *input.AvatarKey
+				assert.Regexp(t, `^avatars/1/[0-9a-f]{16}$`, avatarKey)
+			}
+			return true, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: store, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PutProfileAvatar)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.Avatar
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "/blobs/"+avatarKey+"-512.jpg", resp.Url)
+			if assert.Len(t, resp.Thumbnails, len(avatarSizes)) {
+				assert.Equal(t, 128, resp.Thumbnails[2].Size)
+				assert.Equal(t, "/blobs/"+avatarKey+"-128.jpg", resp.Thumbnails[2].Url)
+			}
+
+			for _, size := range avatarSizes {
+				assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(avatarThumbnailKey(avatarKey, size))))
+				assert.NoFileExists(t, filepath.Join(dir, filepath.FromSlash(avatarThumbnailKey(previous, size))))
+			}
+		}
+	})
+
+	t.Run("Changed During The Upload", func(t *testing.T) {
+		dir := t.TempDir()
+		store := &storage.LocalStore{Dir: dir}
+		previous := "avatars/1/0011223344556677"
+		for _, size := range avatarSizes {
+			assert.NoError(t, store.Put(context.Background(), avatarThumbnailKey(previous, size), "image/jpeg", []byte("previous")))
+		}
+
+		context, rec, mockRepository := setupTestPutAvatar(t, token, "avatar", testPicture(t, 20, 20))
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, AvatarKey: &previous, Version: 4}, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(gomock.Any(), []string{repository.ProfileFieldAvatar}, uint64(4)).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: store, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PutProfileAvatar)(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "profile_modified", resp.Code)
+
+			// only the thumbnails of the previous picture are left
+			entries, _ := os.ReadDir(filepath.Join(dir, "avatars", "1"))
+			assert.Len(t, entries, len(avatarSizes))
+			for _, size := range avatarSizes {
+				assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(avatarThumbnailKey(previous, size))))
+			}
+		}
+	})
+
+	t.Run("Missing Picture", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPutAvatar(t, token, "picture", testPicture(t, 10, 10))
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: &storage.LocalStore{Dir: t.TempDir()}})
+
+		if assert.NoError(t, mockServer.PutProfileAvatar(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "avatar", resp.Errors[0].Field)
+				assert.Equal(t, "required", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Unsupported Type", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPutAvatar(t, token, "avatar", []byte("%PDF-1.7 a document"))
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: &storage.LocalStore{Dir: t.TempDir()}})
+
+		if assert.NoError(t, mockServer.PutProfileAvatar(context)) {
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		}
+	})
+
+	t.Run("Unreadable Picture", func(t *testing.T) {
+		picture := testPicture(t, 10, 10)
+		context, rec, mockRepository := setupTestPutAvatar(t, token, "avatar", picture[:len(picture)/2])
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: &storage.LocalStore{Dir: t.TempDir()}})
+
+		if assert.NoError(t, mockServer.PutProfileAvatar(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "image", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Too Large", func(t *testing.T) {
+		dir := t.TempDir()
+		context, rec, mockRepository := setupTestPutAvatar(t, token, "avatar", bytes.Repeat([]byte{0xFF}, avatarMaxUploadSize+1))
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: &storage.LocalStore{Dir: dir}})
+
+		if assert.NoError(t, mockServer.PutProfileAvatar(context)) {
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+			entries, _ := os.ReadDir(dir)
+			assert.Empty(t, entries)
+		}
+	})
+
+	t.Run("Shown With The Profile", func(t *testing.T) {
+		avatarKey := "avatars/1/0011223344556677"
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/profile", strings.NewReader(""))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		context := e.NewContext(req, rec)
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockRepository := repository.NewMockRepositoryInterface(mockCtrl)
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, FullName: "Bakri", AvatarKey: &avatarKey}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, BlobStore: &storage.LocalStore{BaseURL: "https://cdn.example.com"}, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetProfile)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.GetProfileResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.NotNil(t, resp.Avatar) {
+				assert.Equal(t, "https://cdn.example.com/avatars/1/0011223344556677-512.jpg", resp.Avatar.Url)
+			}
+		}
+	})
+}
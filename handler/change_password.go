@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) PutProfilePassword(ctx echo.Context) error {
+	// Extract the token from the Authorization header
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var request generated.ChangePasswordRequest
+
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	profile, err := s.Repository.GetProfileByID(userID)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	isPasswordValid, err := s.PasswordHasher.Verify(request.CurrentPassword, profile.Password)
+	if err != nil {
+		log.Println("error verify password : ", err)
+	}
+	if !isPasswordValid {
+		mismatch := &ValidationError{Fields: []FieldValidationError{{Field: "current_password", Rule: "passwordMismatch", Params: []string{}}}}
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, mismatch))
+	}
+
+	// the current password counts as the first of the remembered passwords
+	previousHashes := []string{profile.Password}
+	if s.PasswordHistorySize > 1 {
+		history, err := s.Repository.GetPasswordHistory(profile.ID, s.PasswordHistorySize-1)
+		if err != nil {
+			log.Println("error fetch password history : ", err)
+			return err
+		}
+		previousHashes = append(previousHashes, history...)
+	}
+
+	violations := s.PasswordChecker.Check(password.Candidate{
+		Password:       request.NewPassword,
+		FullName:       profile.FullName,
+		PhoneNumbers:   []string{profile.CountryCode + profile.PhoneNumber, profile.PhoneNumber},
+		PreviousHashes: previousHashes,
+	})
+	if len(violations) > 0 {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, passwordPolicyError("new_password", violations)))
+	}
+
+	hashedPassword, err := s.PasswordHasher.Hash(request.NewPassword)
+	if err != nil {
+		log.Println("error hash password : ", err)
+		return err
+	}
+
+	err = s.Repository.ChangePasswordByID(profile.ID, hashedPassword)
+	if err != nil {
+		log.Println("error change password : ", err)
+		return err
+	}
+
+	s.recordSecurityEvent(ctx, profile.ID, repository.SecurityEventPasswordChanged, nil)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
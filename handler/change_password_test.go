@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestChangePassword(t *testing.T, token string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/profile/password", strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestChangePassword(t *testing.T) {
+	var (
+		changePasswordSuccess = `{
+			"current_password" : "1n19s9H88@",
+			"new_password" : "N3w-Passw0rd"
+		}`
+		changePasswordWrongCurrent = `{
+			"current_password" : "1n19s9H88!",
+			"new_password" : "N3w-Passw0rd"
+		}`
+		changePasswordPersonalInfo = `{
+			"current_password" : "1n19s9H88@",
+			"new_password" : "Bakri#89627117"
+		}`
+		changePasswordReused = `{
+			"current_password" : "1n19s9H88@",
+			"new_password" : "0ld-Passw0rd"
+		}`
+	)
+
+	hasher, _ := password.NewHasher(testPasswordPolicy)
+	hashedPassword, _ := hasher.Hash("1n19s9H88@")
+	previousHashedPassword, _ := hasher.Hash("0ld-Passw0rd")
+
+	profile := repository.Profile{
+		ID:          1,
+		FullName:    "Bakri",
+		CountryCode: "+62",
+		PhoneNumber: "89627117",
+		Password:    hashedPassword,
+	}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	validationRules := func(t *testing.T, rec *httptest.ResponseRecorder) []string {
+		var resp generated.ValidationErrorResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		rules := []string{}
+		for _, fieldError := range resp.Errors {
+			rules = append(rules, fieldError.Field+" "+fieldError.Rule)
+		}
+		return rules
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestChangePassword(t, token, changePasswordSuccess)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetPasswordHistory(uint64(1), 4).Return([]string{previousHashedPassword}, nil).Times(1)
+		mockRepository.EXPECT().ChangePasswordByID(uint64(1), gomock.Any()).Return(nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventPasswordChanged,
+			IPAddress: "192.0.2.1",
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, PasswordHistorySize: 5})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PutProfilePassword)(context)) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("Wrong Current Password", func(t *testing.T) {
+		context, rec, mockRepository := setupTestChangePassword(t, token, changePasswordWrongCurrent)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, PasswordHistorySize: 5})
+
+		if assert.NoError(t, mockServer.PutProfilePassword(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, []string{"current_password passwordMismatch"}, validationRules(t, rec))
+		}
+	})
+
+	t.Run("Contains Personal Info", func(t *testing.T) {
+		context, rec, mockRepository := setupTestChangePassword(t, token, changePasswordPersonalInfo)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PutProfilePassword(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, []string{"new_password passwordPersonalInfo"}, validationRules(t, rec))
+		}
+	})
+
+	t.Run("Reused Password", func(t *testing.T) {
+		context, rec, mockRepository := setupTestChangePassword(t, token, changePasswordReused)
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		context.Request().Header.Set("Accept-Language", "id")
+
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetPasswordHistory(uint64(1), 4).Return([]string{previousHashedPassword}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, PasswordHistorySize: 5})
+
+		if assert.NoError(t, mockServer.PutProfilePassword(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "passwordReused", resp.Errors[0].Rule)
+				assert.Equal(t, "new_password harus berbeda dari 5 kata sandi terakhir Anda", resp.Errors[0].Message)
+			}
+		}
+	})
+
+	t.Run("Custom Rules", func(t *testing.T) {
+		context, rec, mockRepository := setupTestChangePassword(t, token, changePasswordSuccess)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{
+			Repository:     mockRepository,
+			PasswordPolicy: testPasswordPolicy,
+			PasswordRules:  []password.Rule{password.MinLength(16)},
+		})
+
+		if assert.NoError(t, mockServer.PutProfilePassword(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, []string{"new_password minLength"}, validationRules(t, rec))
+		}
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		context, rec, mockRepository := setupTestChangePassword(t, "INVALIDTOKEN", changePasswordSuccess)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PutProfilePassword(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+}
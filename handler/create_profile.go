@@ -1,64 +1,57 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 
-	"github.com/go-playground/validator"
 	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
 )
 
-type (
-	CreateProfileValidator struct {
-		FullName    string `json:"full_name" validate:"required,min=3,max=60"`
-		PhoneNumber string `json:"phone_number" validate:"required,min=10,max=13,indonesiaCountryCodePrefix"`
-		Password    string `json:"password" validate:"required,min=6,max=64,strongPassword"`
-	}
-)
-
 func (s *Server) PostProfile(ctx echo.Context) error {
 
 	var request generated.CreateProfileRequest
 
-	customValidator := validator.New()
-	customValidator.RegisterValidation("indonesiaCountryCodePrefix", validatePhoneWithPrefix)
-	customValidator.RegisterValidation("strongPassword", validateStrongPassword)
-
-	ctx.Echo().Validator = &CustomValidator{validator: customValidator}
-
 	err := ctx.Bind(&request)
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, err.Error())
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
 	}
 
-	CreateProfileValidator := CreateProfileValidator{
-		FullName:    request.FullName,
-		PhoneNumber: request.PhoneNumber,
-		Password:    request.Password,
-	}
-	if err = ctx.Validate(CreateProfileValidator); err != nil {
-		return ctx.JSON(http.StatusBadRequest, generated.GeneralErrorResponse{Message: err.Error()})
+	phoneNumber, err := s.PhoneParser.Parse(request.PhoneNumber)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, phoneNumberError("phone_number", err)))
 	}
 
-	countryCode := request.PhoneNumber[:3]
-	localPhoneNumber := request.PhoneNumber[3:]
+	violations := s.PasswordChecker.Check(password.Candidate{
+		Password:     request.Password,
+		FullName:     request.FullName,
+		PhoneNumbers: []string{phoneNumber.E164(), phoneNumber.NationalNumber},
+	})
+	if len(violations) > 0 {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, passwordPolicyError("password", violations)))
+	}
 
-	isExist, err := s.Repository.GetPhoneNumberExistence(localPhoneNumber)
+	isExist, err := s.Repository.GetPhoneNumberExistence(phoneNumber.CountryCode, phoneNumber.NationalNumber)
 	if err != nil {
 		return err
 	}
 	if isExist {
-		responsePayload := generated.GeneralErrorResponse{Message: "Phone Number Already Exist"}
+		responsePayload := errorResponse(ctx, msgPhoneNumberExist)
 		return ctx.JSON(http.StatusConflict, responsePayload)
 	}
 
-	hashedPassword := hashAndSalt([]byte(request.Password))
+	hashedPassword, err := s.PasswordHasher.Hash(request.Password)
+	if err != nil {
+		log.Println("error hash password : ", err)
+		return err
+	}
 
 	profileCreate := repository.Profile{
 		FullName:    request.FullName,
-		CountryCode: countryCode,
-		PhoneNumber: localPhoneNumber,
+		CountryCode: phoneNumber.CountryCode,
+		PhoneNumber: phoneNumber.NationalNumber,
 		Password:    hashedPassword,
 	}
 
@@ -68,7 +61,7 @@ func (s *Server) PostProfile(ctx echo.Context) error {
 		return err
 	}
 
-	resp := generated.CreateProfileResponse{CreatedId: &createdID, Message: "Profile is successfully created"}
+	resp := generated.CreateProfileResponse{CreatedId: &createdID, Message: localize(ctx, msgProfileCreated)}
 
 	return ctx.JSON(http.StatusCreated, resp)
 }
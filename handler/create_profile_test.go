@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,6 +10,8 @@ import (
 	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
 
 	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -41,6 +44,21 @@ func TestCreateProfile(t *testing.T) {
 			"full_name" : "Hasbi Asshidiq",
 			"password" : "1n19s9H88@"
 		}`
+		createProfileLocalFormat = `{
+			"phone_number" : "62 896-2711-7",
+			"full_name" : "Hasbi Asshidiq",
+			"password" : "1n19s9H88@"
+		}`
+		createProfileMalaysia = `{
+			"phone_number" : "+60123456789",
+			"full_name" : "Hasbi Asshidiq",
+			"password" : "1n19s9H88@"
+		}`
+		createProfileLongPassphrase = `{
+			"phone_number" : "+6289627117",
+			"full_name" : "Hasbi Asshidiq",
+			"password" : "correct horse battery staple, correct horse battery staple, correct horse"
+		}`
 		createProfileInvalidPasswordPattern = `{
 			"phone_number" : "+6289627117",
 			"full_name" : "Hasbi Asshidiq",
@@ -52,8 +70,8 @@ func TestCreateProfile(t *testing.T) {
 		context, rec, mockRepository := setupTestCreateProfile(t, createProfileSuccess)
 
 		mockRepository.EXPECT().CreateProfile(gomock.Any()).Return(0, nil).Times(1)
-		mockRepository.EXPECT().GetPhoneNumberExistence(gomock.Any()).Return(false, nil).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockRepository.EXPECT().GetPhoneNumberExistence(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
 		if assert.NoError(t, mockServer.PostProfile(context)) {
 			assert.Equal(t, http.StatusCreated, rec.Code)
@@ -64,31 +82,126 @@ func TestCreateProfile(t *testing.T) {
 
 		context, rec, mockRepository := setupTestCreateProfile(t, createProfileSuccess)
 
-		mockRepository.EXPECT().GetPhoneNumberExistence(gomock.Any()).Return(true, nil).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockRepository.EXPECT().GetPhoneNumberExistence(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
 		if assert.NoError(t, mockServer.PostProfile(context)) {
 			assert.Equal(t, http.StatusConflict, rec.Code)
 		}
 	})
 
+	t.Run("Phone Number Exists In Indonesian", func(t *testing.T) {
+
+		context, rec, mockRepository := setupTestCreateProfile(t, createProfileSuccess)
+		context.Request().Header.Set("Accept-Language", "id-ID,id;q=0.9,en;q=0.8")
+
+		mockRepository.EXPECT().GetPhoneNumberExistence(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostProfile(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "phone_number_already_exist", resp.Code)
+			assert.Equal(t, "Nomor telepon sudah terdaftar", resp.Message)
+		}
+	})
+
 	t.Run("Invalid Country Code", func(t *testing.T) {
 
 		context, rec, mockRepository := setupTestCreateProfile(t, createProfileInvalidCountryCode)
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
-		if assert.NoError(t, mockServer.PostProfile(context)) {
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
 			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "phone_number", resp.Errors[0].Field)
+				assert.Equal(t, "phoneCountry", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Local Phone Number Format", func(t *testing.T) {
+
+		context, rec, mockRepository := setupTestCreateProfile(t, createProfileLocalFormat)
+
+		mockRepository.EXPECT().GetPhoneNumberExistence("+62", "89627117").Return(false, nil).Times(1)
+		mockRepository.EXPECT().CreateProfile(gomock.Any()).DoAndReturn(func(profile repository.Profile) (int, error) {
+			assert.Equal(t, "+62", profile.CountryCode)
+			assert.Equal(t, "89627117", profile.PhoneNumber)
+			return 1, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+		}
+	})
+
+	t.Run("Allowed Country", func(t *testing.T) {
+
+		context, rec, mockRepository := setupTestCreateProfile(t, createProfileMalaysia)
+
+		mockRepository.EXPECT().GetPhoneNumberExistence("+60", "123456789").Return(false, nil).Times(1)
+		mockRepository.EXPECT().CreateProfile(gomock.Any()).Return(1, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, AllowedCountries: []string{"ID", "MY", "SG"}})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
 		}
 	})
 
 	t.Run("Invalid Password Pattern", func(t *testing.T) {
 
 		context, rec, mockRepository := setupTestCreateProfile(t, createProfileInvalidPasswordPattern)
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
-		if assert.NoError(t, mockServer.PostProfile(context)) {
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "password", resp.Errors[0].Field)
+				assert.Equal(t, "passwordSpecial", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Password Length Follows The Policy", func(t *testing.T) {
+		context, rec, mockRepository := setupTestCreateProfile(t, createProfileLongPassphrase)
+
+		mockRepository.EXPECT().GetPhoneNumberExistence("+62", "89627117").Return(false, nil).Times(1)
+		mockRepository.EXPECT().CreateProfile(gomock.Any()).Return(1, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{
+			Repository:     mockRepository,
+			PasswordPolicy: testPasswordPolicy,
+			PasswordRules:  []password.Rule{password.MinLength(12), password.MaxLength(128)},
+		})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+		}
+	})
+
+	t.Run("Password Too Long", func(t *testing.T) {
+		context, rec, mockRepository := setupTestCreateProfile(t, createProfileLongPassphrase)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, PasswordRules: []password.Rule{password.MaxLength(64)}})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
 			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "password", resp.Errors[0].Field)
+				assert.Equal(t, "maxLength", resp.Errors[0].Rule)
+				assert.Equal(t, []string{"64"}, resp.Errors[0].Params)
+			}
 		}
 	})
 }
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// dataExportLifetime is how long the archive of an export can be downloaded once ready
+const dataExportLifetime = 24 * time.Hour
+
+// dataExportStaleAfter restarts the exports left pending, e.g. by a restart of the service while generating them
+const dataExportStaleAfter = 15 * time.Minute
+
+// dataExportRetryAfter is the number of seconds the clients wait before checking a pending export again
+const dataExportRetryAfter = 5
+
+// dataExportEventsBatchSize is the number of security events read at once while generating an archive
+const dataExportEventsBatchSize = 500
+
+func (s *Server) PostProfileExport(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	export, err := s.Repository.GetLatestDataExport(uint64(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("error fetch data export : ", err)
+		return err
+	}
+	if err == nil && isCurrentDataExport(export, time.Now()) {
+		// the export already requested is returned rather than generated again
+		return s.dataExportResponse(ctx, export)
+	}
+
+	// no export yet, or the latest one failed, expired or was abandoned
+	exportID, err := s.Repository.CreateDataExport(uint64(userID))
+	if err != nil {
+		log.Println("error create data export : ", err)
+		return err
+	}
+	export = repository.DataExport{ID: exportID, ProfileID: uint64(userID), Status: repository.DataExportPending, CreatedAt: time.Now()}
+
+	s.runInBackground(func() {
+		s.generateDataExport(export.ID, export.ProfileID)
+	})
+	return s.dataExportResponse(ctx, export)
+}
+
+func (s *Server) GetProfileExport(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	export, err := s.Repository.GetLatestDataExport(uint64(userID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("error fetch data export : ", err)
+		return err
+	}
+	if err != nil || !isCurrentDataExport(export, time.Now()) {
+		responsePayload := errorResponse(ctx, msgExportNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	return s.dataExportResponse(ctx, export)
+}
+
+// isCurrentDataExport tells whether the export is still being generated or can be downloaded, the others failed,
+// expired or were abandoned
+func isCurrentDataExport(export repository.DataExport, now time.Time) bool {
+	switch export.Status {
+	case repository.DataExportReady:
+		return export.ExpiresAt != nil && export.ExpiresAt.After(now)
+	case repository.DataExportPending:
+		return now.Sub(export.CreatedAt) < dataExportStaleAfter
+	}
+	return false
+}
+
+// dataExportResponse writes the status of a current export, with the download link once it is ready
+func (s *Server) dataExportResponse(ctx echo.Context, export repository.DataExport) error {
+	if export.Status != repository.DataExportReady {
+		ctx.Response().Header().Set("Retry-After", strconv.Itoa(dataExportRetryAfter))
+		return ctx.JSON(http.StatusAccepted, generated.DataExportResponse{
+			Status:      generated.DataExportResponseStatus(repository.DataExportPending),
+			RequestedAt: export.CreatedAt,
+		})
+	}
+
+	downloadToken, err := createDataExportToken(export.ProfileID, export.ID, *export.ExpiresAt)
+	if err != nil {
+		log.Println("error create data export token : ", err)
+		return err
+	}
+
+	// the download endpoint is a sibling of this one, under /v1 or the deprecated alias alike
+	downloadURL := ctx.Request().URL.Path + "/download?token=" + url.QueryEscape(downloadToken)
+	return ctx.JSON(http.StatusOK, generated.DataExportResponse{
+		Status:      generated.DataExportResponseStatus(repository.DataExportReady),
+		RequestedAt: export.CreatedAt,
+		DownloadUrl: &downloadURL,
+		ExpiresAt:   export.ExpiresAt,
+	})
+}
+
+func (s *Server) GetProfileExportDownload(ctx echo.Context, params generated.GetProfileExportDownloadParams) error {
+	claims, profileID, err := parseTokenOfType(params.Token, tokenTypeDataExport)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	exportID, ok := claims["eid"].(float64)
+	if !ok {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	archive, err := s.Repository.GetDataExportArchive(uint64(profileID), uint64(exportID))
+	if errors.Is(err, sql.ErrNoRows) {
+		responsePayload := errorResponse(ctx, msgExportNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch data export archive : ", err)
+		return err
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="profile-%d-export.json"`, profileID))
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, archive)
+}
+
+// generateDataExport builds the archive of the export and stores it, a failure is recorded so the next request
+// starts over
+func (s *Server) generateDataExport(exportID uint64, profileID uint64) {
+	archive, err := s.buildDataExportArchive(profileID)
+	if err == nil {
+		err = s.Repository.CompleteDataExport(exportID, archive, time.Now().Add(dataExportLifetime))
+	}
+	if err == nil {
+		return
+	}
+
+	log.Printf("error generate data export %d of profile %d : %v", exportID, profileID, err)
+	if err := s.Repository.FailDataExport(exportID); err != nil {
+		log.Printf("error fail data export %d : %v", exportID, err)
+	}
+}
+
+// buildDataExportArchive returns the JSON document of the personal data of the profile, the secrets such as the
+// password hash and the TOTP secret are deliberately left out
+func (s *Server) buildDataExportArchive(profileID uint64) (archive []byte, err error) {
+	profile, err := s.Repository.GetProfileByID(int(profileID))
+	if err != nil {
+		return nil, err
+	}
+
+	document := generated.DataExportArchive{
+		ExportedAt: time.Now().UTC(),
+		Profile: generated.DataExportProfile{
+			Id:              int(profile.ID),
+			FullName:        profile.FullName,
+			PhoneNumber:     profile.CountryCode + profile.PhoneNumber,
+			Email:           profile.Email,
+			EmailVerifiedAt: profile.EmailVerifiedAt,
+			Gender:          (*generated.Gender)(profile.Gender),
+			Locale:          profile.Locale,
+			Timezone:        profile.Timezone,
+			Status:          generated.ProfileStatus(profile.Status),
+			CreatedAt:       profile.CreatedAt,
+			UpdatedAt:       profile.UpdatedAt,
+		},
+		Sessions:       []generated.DataExportSession{},
+		SecurityEvents: []generated.SecurityEvent{},
+	}
+
+	if profile.DateOfBirth != nil {
+		document.Profile.DateOfBirth = &openapi_types.Date{Time: *profile.DateOfBirth}
+	}
+	if profile.AvatarKey != nil {
+		avatarURL := s.avatarResponse(*profile.AvatarKey).Url
+		document.Profile.AvatarUrl = &avatarURL
+	}
+
+	// the archive holds every attribute, the hidden ones included, they are personal data of the user all the same
+	attributes := profile.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	document.Profile.Attributes = (*generated.ProfileAttributes)(&attributes)
+
+	// the metadata is created by the first login
+	metadata, err := s.Repository.GetProfileMetaData(profileID)
+	switch {
+	case err == nil:
+		lastLoginAt := metadata.CreatedAt
+		if metadata.UpdatedAt != nil {
+			lastLoginAt = *metadata.UpdatedAt
+		}
+		document.Metadata = &generated.AdminProfileMetadata{
+			LoginAttempt: int(metadata.LoginAttempt),
+			FirstLoginAt: metadata.CreatedAt,
+			LastLoginAt:  &lastLoginAt,
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	sessions, err := s.Repository.GetSessions(profileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		document.Sessions = append(document.Sessions, generated.DataExportSession{
+			Id:         int(session.ID),
+			DeviceName: session.DeviceName,
+			IpAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			RevokedAt:  session.RevokedAt,
+		})
+	}
+
+	// the history can be long, it is read in batches from the latest event
+	var beforeID uint64
+	for {
+		events, err := s.Repository.GetSecurityEvents(profileID, beforeID, dataExportEventsBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			document.SecurityEvents = append(document.SecurityEvents, userSecurityEvent(event))
+		}
+		if len(events) < dataExportEventsBatchSize {
+			break
+		}
+		beforeID = events[len(events)-1].ID
+	}
+
+	return json.MarshalIndent(document, "", "  ")
+}
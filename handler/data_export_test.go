@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProfileExport(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("Start Export", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodPost, "/v1/profile/export", token)
+
+		exported := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117", Password: "$2a$10$secret-hash", Status: repository.ProfileStatusActive, Attributes: map[string]interface{}{"loyalty_tier": "gold", "risk_score": float64(7)}, CreatedAt: now}
+		events := make([]repository.SecurityEvent, dataExportEventsBatchSize)
+		for i := range events {
+			events[i] = repository.SecurityEvent{ID: uint64(dataExportEventsBatchSize + 1 - i), Type: repository.SecurityEventLoginSucceeded, CreatedAt: now}
+		}
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateDataExport(uint64(1)).Return(uint64(7), nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(exported, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMetaData(uint64(1)).Return(repository.ProfileMetaData{LoginAttempt: 2, CreatedAt: now}, nil).Times(1)
+		mockRepository.EXPECT().GetSessions(uint64(1)).Return([]repository.Session{{ID: 1, DeviceName: "Chrome on Android", IPAddress: "192.0.2.1", CreatedAt: now, LastSeenAt: now, ExpiresAt: now}}, nil).Times(1)
+		mockRepository.EXPECT().GetSecurityEvents(uint64(1), uint64(0), dataExportEventsBatchSize).Return(events, nil).Times(1)
+		mockRepository.EXPECT().GetSecurityEvents(uint64(1), uint64(2), dataExportEventsBatchSize).Return([]repository.SecurityEvent{{ID: 1, Type: repository.SecurityEventProfileSuspended, IPAddress: "198.51.100.7", Details: map[string]string{"reason": "fraud review", "operator_id": "3"}, CreatedAt: now}}, nil).Times(1)
+		mockRepository.EXPECT().CompleteDataExport(uint64(7), gomock.Any(), gomock.Any()).DoAndReturn(func(id uint64, archive []byte, expiresAt time.Time) error {
+			assert.NotContains(t, string(archive), exported.Password)
+			assert.WithinDuration(t, time.Now().Add(dataExportLifetime), expiresAt, time.Minute)
+
+			var document generated.DataExportArchive
+			if assert.NoError(t, json.Unmarshal(archive, &document)) {
+				assert.Equal(t, "+6289627117", document.Profile.PhoneNumber)
+				assert.Len(t, document.Sessions, 1)
+				assert.Len(t, document.SecurityEvents, dataExportEventsBatchSize+1)
+				if assert.NotNil(t, document.Profile.Attributes) {
+					assert.Equal(t, generated.ProfileAttributes{"loyalty_tier": "gold", "risk_score": float64(7)}, *document.Profile.Attributes)
+				}
+				suspended := document.SecurityEvents[dataExportEventsBatchSize]
+				assert.Empty(t, suspended.Details)
+				assert.Empty(t, suspended.IpAddress)
+				if assert.NotNil(t, document.Metadata) {
+					assert.Equal(t, 2, document.Metadata.LoginAttempt)
+				}
+			}
+			return nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+		mockServer.runInBackground = func(task func()) { task() }
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PostProfileExport(ctx)
+		}
+		if assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+
+			var resp generated.DataExportResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "pending", string(resp.Status))
+			assert.Nil(t, resp.DownloadUrl)
+		}
+	})
+
+	t.Run("Still Pending", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{ID: 7, ProfileID: 1, Status: repository.DataExportPending, CreatedAt: time.Now()}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileExport(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export", token)
+
+		expiresAt := now.Add(dataExportLifetime)
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{ID: 7, ProfileID: 1, Status: repository.DataExportReady, CreatedAt: now, CompletedAt: &now, ExpiresAt: &expiresAt}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileExport(ctx)
+		}
+		if assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.DataExportResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "ready", string(resp.Status))
+			if assert.NotNil(t, resp.DownloadUrl) {
+				assert.True(t, strings.HasPrefix(*resp.DownloadUrl, "/v1/profile/export/download?token="))
+			}
+		}
+	})
+
+	t.Run("Still Pending When Requested Again", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodPost, "/v1/profile/export", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{ID: 7, ProfileID: 1, Status: repository.DataExportPending, CreatedAt: time.Now()}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+		mockServer.runInBackground = func(task func()) { t.Error("no new export is started") }
+
+		if assert.NoError(t, mockServer.PostProfileExport(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export", token)
+
+		expiresAt := now.Add(-time.Minute)
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{ID: 7, ProfileID: 1, Status: repository.DataExportReady, CreatedAt: now.Add(-dataExportLifetime), ExpiresAt: &expiresAt}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+		mockServer.runInBackground = func(task func()) { t.Error("checking the status doesn't start an export") }
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileExport(ctx)
+		}
+		if assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Not Requested", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileExport(context)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Requested Again After Expiry", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodPost, "/v1/profile/export", token)
+
+		expiresAt := now.Add(-time.Minute)
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestDataExport(uint64(1)).Return(repository.DataExport{ID: 7, ProfileID: 1, Status: repository.DataExportReady, CreatedAt: now.Add(-dataExportLifetime), ExpiresAt: &expiresAt}, nil).Times(1)
+		mockRepository.EXPECT().CreateDataExport(uint64(1)).Return(uint64(8), nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+		started := false
+		mockServer.runInBackground = func(task func()) { started = true }
+
+		if assert.NoError(t, mockServer.PostProfileExport(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.True(t, started)
+		}
+	})
+}
+
+func TestGenerateDataExportFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(mockCtrl)
+
+	mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1}, nil).Times(1)
+	mockRepository.EXPECT().GetProfileMetaData(uint64(1)).Return(repository.ProfileMetaData{}, sql.ErrNoRows).Times(1)
+	mockRepository.EXPECT().GetSessions(uint64(1)).Return(nil, errors.New("connection refused")).Times(1)
+	mockRepository.EXPECT().FailDataExport(uint64(7)).Return(nil).Times(1)
+	mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+	mockServer.generateDataExport(7, 1)
+}
+
+func TestGetProfileExportDownload(t *testing.T) {
+	downloadToken, _ := createDataExportToken(1, 7, time.Now().Add(time.Hour))
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export/download?token="+url.QueryEscape(downloadToken), "")
+
+		archive := `{"exported_at": "2026-10-19T00:00:00Z", "profile": {"id": 1, "full_name": "Bakri", "phone_number": "+6289627117", "status": "active", "created_at": "2026-10-19T00:00:00Z"}, "sessions": [], "security_events": []}`
+		mockRepository.EXPECT().GetDataExportArchive(uint64(1), uint64(7)).Return([]byte(archive), nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileExportDownload(ctx, generated.GetProfileExportDownloadParams{Token: downloadToken})
+		}
+		if assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, `attachment; filename="profile-1-export.json"`, rec.Header().Get(echo.HeaderContentDisposition))
+			assert.JSONEq(t, archive, rec.Body.String())
+		}
+	})
+
+	t.Run("Expired Export", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export/download", "")
+
+		mockRepository.EXPECT().GetDataExportArchive(uint64(1), uint64(7)).Return(nil, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileExportDownload(context, generated.GetProfileExportDownloadParams{Token: downloadToken})) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Access Token", func(t *testing.T) {
+		accessToken, _ := createToken(repository.Profile{ID: 1}, 1, nil, nil)
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/v1/profile/export/download", "")
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileExportDownload(context, generated.GetProfileExportDownloadParams{Token: accessToken})) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/labstack/echo/v4"
+)
+
+// RouteDeprecation describes the deprecation of a single route, announced to the clients
+// with the Deprecation (RFC 9745), Sunset (RFC 8594) and Link response headers
+type RouteDeprecation struct {
+	DeprecatedAt time.Time  `json:"deprecated_at"`
+	Sunset       *time.Time `json:"sunset,omitempty"`
+	Successor    string     `json:"successor,omitempty"`
+}
+
+// RouteDeprecations maps a route, written as "METHOD /path" like "GET /profile", to its deprecation
+type RouteDeprecations map[string]RouteDeprecation
+
+// pathParamPattern matches the {param} path parameters of the spec, written :param by echo
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// DeprecateUnversionedRoutes marks every operation of the spec served without a version prefix
+// as a deprecated alias of the same operation under successorPrefix, e.g. /v1
+func DeprecateUnversionedRoutes(successorPrefix string, deprecation RouteDeprecation) (RouteDeprecations, error) {
+	swagger, err := generated.GetSwagger()
+	if err != nil {
+		return nil, err
+	}
+
+	deprecations := RouteDeprecations{}
+	for path, pathItem := range swagger.Paths {
+		echoPath := pathParamPattern.ReplaceAllString(path, ":$1")
+
+		for method := range pathItem.Operations() {
+			routeDeprecation := deprecation
+			routeDeprecation.Successor = successorPrefix + path
+			deprecations[method+" "+echoPath] = routeDeprecation
+		}
+	}
+	return deprecations, nil
+}
+
+// ParseRouteDeprecations reads per route deprecations from JSON, e.g.
+// {"GET /profile": {"deprecated_at": "2024-01-01T00:00:00Z", "sunset": "2024-07-01T00:00:00Z"}}
+func ParseRouteDeprecations(raw string) (RouteDeprecations, error) {
+	deprecations := RouteDeprecations{}
+	if raw == "" {
+		return deprecations, nil
+	}
+
+	err := json.Unmarshal([]byte(raw), &deprecations)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route deprecations : %w", err)
+	}
+	return deprecations, nil
+}
+
+// Merge returns the deprecations of d overridden by the ones of overrides
+func (d RouteDeprecations) Merge(overrides RouteDeprecations) RouteDeprecations {
+	merged := RouteDeprecations{}
+	for route, deprecation := range d {
+		merged[route] = deprecation
+	}
+	for route, deprecation := range overrides {
+		merged[route] = deprecation
+	}
+	return merged
+}
+
+// DeprecationHeaders sets the deprecation headers on the responses of the deprecated routes
+func DeprecationHeaders(deprecations RouteDeprecations) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			deprecation, ok := deprecations[ctx.Request().Method+" "+ctx.Path()]
+			if !ok {
+				return next(ctx)
+			}
+
+			header := ctx.Response().Header()
+			header.Set("Deprecation", fmt.Sprintf("@%d", deprecation.DeprecatedAt.Unix()))
+			if deprecation.Sunset != nil {
+				header.Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if deprecation.Successor != "" {
+				header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, deprecation.Successor))
+			}
+
+			return next(ctx)
+		}
+	}
+}
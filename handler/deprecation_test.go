@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestDeprecationHeaders(t *testing.T, deprecations RouteDeprecations, path string) (rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Use(DeprecationHeaders(deprecations))
+
+	ok := func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	}
+	e.GET("/profile", ok)
+	e.GET("/v1/profile", ok)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	deprecatedAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+	deprecations, err := DeprecateUnversionedRoutes("/v1", RouteDeprecation{DeprecatedAt: deprecatedAt, Sunset: &sunset})
+	assert.NoError(t, err)
+
+	t.Run("Unversioned Routes Are Deprecated", func(t *testing.T) {
+		assert.Contains(t, deprecations, "GET /profile")
+		assert.Contains(t, deprecations, "PUT /profile")
+		assert.Contains(t, deprecations, "POST /login")
+		assert.Equal(t, "/v1/profile", deprecations["GET /profile"].Successor)
+	})
+
+	t.Run("Deprecated Route", func(t *testing.T) {
+		rec := setupTestDeprecationHeaders(t, deprecations, "/profile")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "@1704067200", rec.Header().Get("Deprecation"))
+		assert.Equal(t, "Mon, 01 Jul 2024 00:00:00 GMT", rec.Header().Get("Sunset"))
+		assert.Equal(t, `</v1/profile>; rel="successor-version"`, rec.Header().Get("Link"))
+	})
+
+	t.Run("Current Route", func(t *testing.T) {
+		rec := setupTestDeprecationHeaders(t, deprecations, "/v1/profile")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+		assert.Empty(t, rec.Header().Get("Sunset"))
+	})
+
+	t.Run("Per Route Override", func(t *testing.T) {
+		overrides, err := ParseRouteDeprecations(`{"GET /profile": {"deprecated_at": "2024-03-01T00:00:00Z"}}`)
+		assert.NoError(t, err)
+
+		rec := setupTestDeprecationHeaders(t, deprecations.Merge(overrides), "/profile")
+
+		assert.Equal(t, "@1709251200", rec.Header().Get("Deprecation"))
+		assert.Empty(t, rec.Header().Get("Sunset"))
+	})
+
+	t.Run("Invalid Override", func(t *testing.T) {
+		_, err := ParseRouteDeprecations(`{"GET /profile": "tomorrow"}`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/invopop/yaml"
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed docs/index.html
+var docsPage []byte
+
+// RegisterDocs serves the embedded OpenAPI spec at /openapi.json and /openapi.yaml
+// and the API explorer at /docs
+func RegisterDocs(router generated.EchoRouter) error {
+	swagger, err := generated.GetSwagger()
+	if err != nil {
+		return err
+	}
+
+	specYAML, err := yaml.Marshal(swagger)
+	if err != nil {
+		return err
+	}
+
+	router.GET("/openapi.json", getOpenAPIJSON(swagger))
+	router.GET("/openapi.yaml", getOpenAPIYAML(specYAML))
+	router.GET("/docs", getDocs)
+
+	return nil
+}
+
+func getOpenAPIJSON(swagger *openapi3.T) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, swagger)
+	}
+}
+
+func getOpenAPIYAML(specYAML []byte) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.Blob(http.StatusOK, "application/yaml", specYAML)
+	}
+}
+
+func getDocs(ctx echo.Context) error {
+	return ctx.HTMLBlob(http.StatusOK, docsPage)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestDocs(t *testing.T, path string) (rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	assert.NoError(t, RegisterDocs(e))
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestDocs(t *testing.T) {
+
+	t.Run("OpenAPI JSON", func(t *testing.T) {
+		rec := setupTestDocs(t, "/openapi.json")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var spec map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &spec))
+		assert.Contains(t, spec["paths"], "/profile")
+	})
+
+	t.Run("OpenAPI YAML", func(t *testing.T) {
+		rec := setupTestDocs(t, "/openapi.yaml")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/yaml", rec.Header().Get(echo.HeaderContentType))
+		assert.True(t, strings.Contains(rec.Body.String(), "openapi: 3.0.0"))
+	})
+
+	t.Run("Explorer", func(t *testing.T) {
+		rec := setupTestDocs(t, "/docs")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "/openapi.json")
+	})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// emailVerificationLifetime is how long the verification link of an email is valid
+const emailVerificationLifetime = 24 * time.Hour
+
+// emailVerificationResendAfter is how long the users wait before another link is sent to the same email
+const emailVerificationResendAfter = time.Minute
+
+func (s *Server) PostProfileEmailVerification(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	profile, err := s.Repository.GetProfileByID(userID)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	if profile.Email == nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgEmailNotSet))
+	}
+	if profile.EmailVerifiedAt != nil {
+		return ctx.JSON(http.StatusConflict, errorResponse(ctx, msgEmailAlreadyVerified))
+	}
+
+	sent, err := s.sendEmailVerification(ctx, profile.ID, *profile.Email)
+	if err != nil {
+		log.Println("error send email verification : ", err)
+		return err
+	}
+	if !sent {
+		return ctx.JSON(http.StatusTooManyRequests, errorResponse(ctx, msgEmailSentTooSoon))
+	}
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+func (s *Server) GetProfileEmailVerify(ctx echo.Context, params generated.GetProfileEmailVerifyParams) error {
+	claims, profileID, err := parseTokenOfType(params.Token, tokenTypeEmailVerification)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidEmailLink))
+	}
+
+	address, ok := claims["email"].(string)
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidEmailLink))
+	}
+
+	verified, err := s.Repository.VerifyEmail(uint64(profileID), address)
+	if errors.Is(err, repository.ErrEmailTaken) {
+		return ctx.JSON(http.StatusConflict, errorResponse(ctx, msgEmailExist))
+	}
+	if err != nil {
+		log.Println("error verify email : ", err)
+		return err
+	}
+
+	if !verified {
+		// the link may be opened again once the email is verified, it's refused once the email has been changed
+		profile, err := s.Repository.GetProfileByID(profileID)
+		if err != nil || profile.Email == nil || *profile.Email != address || profile.EmailVerifiedAt == nil {
+			return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidEmailLink))
+		}
+	} else {
+		s.recordSecurityEvent(ctx, uint64(profileID), repository.SecurityEventEmailVerified, map[string]string{"email": address})
+	}
+
+	return ctx.JSON(http.StatusOK, generated.VerifyEmailResponse{Message: localize(ctx, msgEmailVerified)})
+}
+
+// sendEmailVerification sends a verification link of the email of the profile to the address, unless another link
+// has been sent to it less than emailVerificationResendAfter ago or the profile has another email now
+func (s *Server) sendEmailVerification(ctx echo.Context, profileID uint64, address string) (sent bool, err error) {
+	marked, err := s.Repository.MarkEmailVerificationSent(profileID, address, time.Now().Add(-emailVerificationResendAfter))
+	if err != nil || !marked {
+		return false, err
+	}
+
+	token, err := createEmailVerificationToken(profileID, address, time.Now().Add(emailVerificationLifetime))
+	if err != nil {
+		return false, err
+	}
+
+	link := s.emailVerificationLink(ctx, token)
+	subject := localize(ctx, msgEmailVerifySubject)
+	body := localize(ctx, msgEmailVerifyBody, link, int(emailVerificationLifetime.Hours()))
+	if err := s.EmailSender.Send(ctx.Request().Context(), address, subject, body); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// emailVerificationLink is the URL of the verification endpoint with the token, under the version of the API of the
+// request, e.g. /v1, or the deprecated alias alike. The host is the configured PublicURL, never the Host header of the
+// request, which would let anyone send the links of another address to their own server.
+func (s *Server) emailVerificationLink(ctx echo.Context, token string) string {
+	// every operation sending a link is under /profile
+	prefix, _, _ := strings.Cut(ctx.Request().URL.Path, "/profile")
+
+	return s.PublicURL + prefix + "/profile/email/verify?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/email"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestEmailVerification(t *testing.T, method string, target string, token string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestPostProfileEmailVerification(t *testing.T) {
+	address := "bakri@example.com"
+	profile := repository.Profile{ID: 1, FullName: "Bakri", Email: &address}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodPost, "/v1/profile/email/verification", token)
+		context.Request().Header.Set("Accept-Language", "id")
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().MarkEmailVerificationSent(uint64(1), address, gomock.Any()).DoAndReturn(func(profileID uint64, address string, sentBefore time.Time) (bool, error) {
+			assert.WithinDuration(t, time.Now().Add(-emailVerificationResendAfter), sentBefore, time.Second)
+			return true, nil
+		}).Times(1)
+		mailbox := filepath.Join(t.TempDir(), "mailbox.jsonl")
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, EmailSender: &email.FileSender{Path: mailbox}, PublicURL: "https://api.example.com/"})
+
+		if assert.NoError(t, mockServer.PostProfileEmailVerification(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+
+			messages, err := email.ReadMessages(mailbox)
+			if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+				assert.Equal(t, address, messages[0].To)
+				assert.Equal(t, "Verifikasi alamat email Anda", messages[0].Subject)
+
+				// the link is under the version of the API of the request
+				link := messages[0].Body[strings.Index(messages[0].Body, "https://"):]
+				link = link[:strings.IndexAny(link, "\n")]
+				parsed, err := url.Parse(link)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "/v1/profile/email/verify", parsed.Path)
+
+					claims, profileID, err := parseTokenOfType(parsed.Query().Get("token"), tokenTypeEmailVerification)
+					if assert.NoError(t, err) {
+						assert.Equal(t, 1, profileID)
+						assert.Equal(t, address, claims["email"])
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Sent Too Soon", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodPost, "/profile/email/verification", token)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().MarkEmailVerificationSent(uint64(1), address, gomock.Any()).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostProfileEmailVerification(context)) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		}
+	})
+
+	t.Run("No Email", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodPost, "/profile/email/verification", token)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostProfileEmailVerification(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"email_not_set"`)
+		}
+	})
+
+	t.Run("Already Verified", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodPost, "/profile/email/verification", token)
+
+		verifiedAt := time.Now()
+		verified := profile
+		verified.EmailVerifiedAt = &verifiedAt
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(verified, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostProfileEmailVerification(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
+}
+
+func TestGetProfileEmailVerify(t *testing.T) {
+	address := "bakri@example.com"
+	linkToken, _ := createEmailVerificationToken(1, address, time.Now().Add(time.Hour))
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodGet, "/profile/email/verify?token="+url.QueryEscape(linkToken), "")
+
+		mockRepository.EXPECT().VerifyEmail(uint64(1), address).Return(true, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).DoAndReturn(func(event repository.SecurityEvent) error {
+			assert.Equal(t, repository.SecurityEventEmailVerified, event.Type)
+			assert.Equal(t, map[string]string{"email": address}, event.Details)
+			return nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileEmailVerify(ctx, generated.GetProfileEmailVerifyParams{Token: linkToken})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.VerifyEmailResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "Your email is verified", resp.Message)
+		}
+	})
+
+	t.Run("Opened Again", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodGet, "/profile/email/verify", "")
+
+		verifiedAt := time.Now()
+		mockRepository.EXPECT().VerifyEmail(uint64(1), address).Return(false, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, Email: &address, EmailVerifiedAt: &verifiedAt}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileEmailVerify(context, generated.GetProfileEmailVerifyParams{Token: linkToken})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Email Changed", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodGet, "/profile/email/verify", "")
+
+		changed := "siti@example.com"
+		mockRepository.EXPECT().VerifyEmail(uint64(1), address).Return(false, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, Email: &changed}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileEmailVerify(context, generated.GetProfileEmailVerifyParams{Token: linkToken})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"invalid_email_link"`)
+		}
+	})
+
+	t.Run("Verified By Another Profile", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodGet, "/profile/email/verify?token="+url.QueryEscape(linkToken), "")
+
+		mockRepository.EXPECT().VerifyEmail(uint64(1), address).Return(false, repository.ErrEmailTaken).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.GetProfileEmailVerify(ctx, generated.GetProfileEmailVerifyParams{Token: linkToken})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"email_already_exist"`)
+		}
+	})
+
+	t.Run("Profile Deleted", func(t *testing.T) {
+		context, rec, mockRepository := setupTestEmailVerification(t, http.MethodGet, "/profile/email/verify", "")
+
+		mockRepository.EXPECT().VerifyEmail(uint64(1), address).Return(false, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileEmailVerify(context, generated.GetProfileEmailVerifyParams{Token: linkToken})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Expired Or Other Tokens", func(t *testing.T) {
+		expired, _ := createEmailVerificationToken(1, address, time.Now().Add(-time.Minute))
+		accessToken, _ := createToken(repository.Profile{ID: 1}, 1, nil, nil)
+
+		for _, token := range []string{expired, accessToken, "INVALIDTOKEN"} {
+			context, rec, mockRepository := setupTestEmailVerification(t, http.MethodGet, "/profile/email/verify", "")
+			mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+			if assert.NoError(t, mockServer.GetProfileEmailVerify(context, generated.GetProfileEmailVerifyParams{Token: token})) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			}
+		}
+	})
+}
@@ -1,36 +1,63 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
+	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
 func (s *Server) GetProfile(ctx echo.Context) error {
 	// Extract the token from the Authorization header
 	token, err := extractToken(ctx)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Invalid Token"}
+		responsePayload := errorResponse(ctx, msgInvalidToken)
 		return ctx.JSON(http.StatusForbidden, responsePayload)
 	}
 
-	userID, err := extractUserIDFromToken(token)
+	userID, err := s.extractUserIDFromToken(token)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Invalid Token"}
-		return ctx.JSON(http.StatusForbidden, responsePayload)
+		return tokenErrorResponse(ctx, err)
 	}
 
 	profile, err := s.Repository.GetProfileByID(userID)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Profile not found"}
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
 		return ctx.JSON(http.StatusNotFound, responsePayload)
 	}
 
-	resp := generated.GetProfileResponse{
-		FullName:    profile.FullName,
-		PhoneNumber: profile.CountryCode + profile.PhoneNumber,
+	attributes, err := s.readableProfileAttributes(profile.Attributes)
+	if err != nil {
+		log.Println("error load profile attribute schema : ", err)
+		return err
 	}
 
-	return ctx.JSON(http.StatusOK, resp)
+	ctx.Response().Header().Set("ETag", profileETag(profile))
+	return ctx.JSON(http.StatusOK, s.profileResponse(profile, attributes))
+}
+
+// profileResponse is the representation of the profile returned by GET, PUT and PATCH /profile, with the custom
+// attributes its user sees
+func (s *Server) profileResponse(profile repository.Profile, attributes map[string]interface{}) generated.GetProfileResponse {
+	resp := generated.GetProfileResponse{
+		FullName:      profile.FullName,
+		PhoneNumber:   profile.CountryCode + profile.PhoneNumber,
+		Email:         profile.Email,
+		EmailVerified: profile.EmailVerifiedAt != nil,
+		Gender:        (*generated.Gender)(profile.Gender),
+		Locale:        profile.Locale,
+		Timezone:      profile.Timezone,
+		Attributes:    attributes,
+	}
+	if profile.DateOfBirth != nil {
+		resp.DateOfBirth = &openapi_types.Date{Time: *profile.DateOfBirth}
+	}
+	if profile.AvatarKey != nil {
+		avatar := s.avatarResponse(*profile.AvatarKey)
+		resp.Avatar = &avatar
+	}
+	return resp
 }
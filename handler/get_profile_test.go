@@ -34,16 +34,18 @@ func setupTestGetProfile(t *testing.T, token string) (context echo.Context, rec
 func TestGetProfile(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
-		profile := repository.Profile{ID: 1}
+		profile := repository.Profile{ID: 1, Version: 2}
 
-		token, _ := createToken(profile)
+		token, _ := createToken(profile, 1, nil, nil)
 		context, rec, mockRepository := setupTestGetProfile(t, token)
 
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
 		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, nil).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
 		if assert.NoError(t, mockServer.GetProfile(context)) {
 			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
 		}
 	})
 	t.Run("Forbidden", func(t *testing.T) {
@@ -51,7 +53,7 @@ func TestGetProfile(t *testing.T) {
 		token := "INVALIDTOKEN"
 		context, rec, mockRepository := setupTestGetProfile(t, token)
 
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
 		if assert.NoError(t, mockServer.GetProfile(context)) {
 			assert.Equal(t, http.StatusForbidden, rec.Code)
@@ -60,11 +62,12 @@ func TestGetProfile(t *testing.T) {
 	t.Run("Profile not found", func(t *testing.T) {
 		profile := repository.Profile{}
 
-		token, _ := createToken(profile)
+		token, _ := createToken(profile, 1, nil, nil)
 		context, rec, mockRepository := setupTestGetProfile(t, token)
 
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
 		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, sql.ErrNoRows).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
 		if assert.NoError(t, mockServer.GetProfile(context)) {
 			assert.Equal(t, http.StatusNotFound, rec.Code)
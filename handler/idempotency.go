@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// idempotencyKeyHeader lets the clients retry the requests of the operations marked with x-idempotent in api.yml
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader marks the responses replayed from the first request of a key
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength is the length of the longest key accepted
+const maxIdempotencyKeyLength = 255
+
+// defaultIdempotencyKeyTTL is how long the response of a key is replayed when not configured
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyKeyAbandonedAfter frees the keys of the requests still in progress after this long, e.g. because the
+// service restarted while handling them
+const idempotencyKeyAbandonedAfter = time.Minute
+
+// anonymousIdempotencyScope is the scope of the keys of the requests without a valid access token. The addresses of
+// the mobile clients change between a request and its retry, so these keys are shared by all the anonymous clients:
+// a key chosen at random doesn't collide, and the fingerprint keeps a response from being replayed to another request.
+const anonymousIdempotencyScope = "anonymous"
+
+// acceptsIdempotencyKey reports whether the operation is marked with x-idempotent in api.yml
+func acceptsIdempotencyKey(route *routers.Route) bool {
+	idempotent, _ := route.Operation.Extensions["x-idempotent"].(bool)
+	return idempotent
+}
+
+// withIdempotencyKey runs next once per key: its response is stored and replayed to the retries of the request.
+// A retry while the request is in progress is refused, and so is the reuse of the key for another request. The
+// server errors and the rate limited responses aren't stored, the request can be retried with the same key.
+// operation is the method and the path of the request, without the version prefix of the API.
+func (s *Server) withIdempotencyKey(ctx echo.Context, key string, operation string, next echo.HandlerFunc) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, &ValidationError{Fields: []FieldValidationError{{
+			Field:  idempotencyKeyHeader,
+			Rule:   "maxLength",
+			Params: []string{strconv.Itoa(maxIdempotencyKeyLength)},
+		}}}))
+	}
+
+	req := ctx.Request()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// the same key and body sent to another resource, with other query parameters or another precondition is
+	// another request. The query is encoded with its parameters sorted.
+	fingerprint := sha256.New()
+	fingerprint.Write([]byte(operation + "\n"))
+	fingerprint.Write([]byte(req.URL.Query().Encode() + "\n"))
+	fingerprint.Write([]byte(req.Header.Get("If-Match") + "\n"))
+	fingerprint.Write(body)
+
+	now := time.Now()
+	record := repository.IdempotencyKey{
+		Scope:       idempotencyScope(ctx),
+		Key:         key,
+		Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+		ExpiresAt:   now.Add(s.IdempotencyKeyTTL),
+	}
+	reserved, stored, err := s.Repository.ReserveIdempotencyKey(record, now.Add(-idempotencyKeyAbandonedAfter))
+	if err != nil {
+		log.Println("error reserve idempotency key : ", err)
+		return err
+	}
+
+	if !reserved {
+		switch {
+		case stored.Fingerprint != record.Fingerprint:
+			return ctx.JSON(http.StatusUnprocessableEntity, errorResponse(ctx, msgIdempotencyKeyReused))
+		case stored.StatusCode == 0:
+			return ctx.JSON(http.StatusConflict, errorResponse(ctx, msgIdempotencyInProgress))
+		}
+
+		header := ctx.Response().Header()
+		for name, values := range stored.Header {
+			header[name] = values
+		}
+		header.Set(idempotentReplayedHeader, "true")
+		ctx.Response().WriteHeader(stored.StatusCode)
+		_, err = ctx.Response().Write(stored.Body)
+		return err
+	}
+
+	writer := &teeResponseWriter{ResponseWriter: ctx.Response().Writer}
+	ctx.Response().Writer = writer
+	err = next(ctx)
+	ctx.Response().Writer = writer.ResponseWriter
+
+	status := ctx.Response().Status
+	if err != nil || !ctx.Response().Committed || status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		if err := s.Repository.DeleteIdempotencyKey(record.Scope, record.Key); err != nil {
+			log.Printf("error release idempotency key %s : %v", record.Key, err)
+		}
+		return err
+	}
+
+	// the response has been sent, a failure only makes the retries wait until the key is abandoned
+	err = s.Repository.CompleteIdempotencyKey(record.Scope, record.Key, status, ctx.Response().Header().Clone(), writer.body.Bytes())
+	if err != nil {
+		log.Printf("error store response of idempotency key %s : %v", record.Key, err)
+	}
+	return nil
+}
+
+// idempotencyScope is the owner of the keys of the request, the profile of the access token, so the keys of
+// different users don't collide. The keys of the anonymous requests are shared, see anonymousIdempotencyScope.
+func idempotencyScope(ctx echo.Context) string {
+	token, err := extractToken(ctx)
+	if err != nil {
+		return anonymousIdempotencyScope
+	}
+
+	// the access tokens are the ones without a type
+	_, profileID, err := parseTokenOfType(token, "")
+	if err != nil {
+		return anonymousIdempotencyScope
+	}
+	return "profile:" + strconv.Itoa(profileID)
+}
+
+// teeResponseWriter keeps a copy of the body of the response it writes
+type teeResponseWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *teeResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
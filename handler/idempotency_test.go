@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestIdempotency(t *testing.T, method string, path string, key string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(method, path, strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(idempotencyKeyHeader, key)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	registration := `{"full_name": "Bakri", "phone_number": "+6289627117", "password": "1n19s9H88@"}`
+
+	t.Run("First Request", func(t *testing.T) {
+		context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", "a3a1c6e2", registration)
+
+		mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(input repository.IdempotencyKey, abandonedBefore time.Time) (bool, repository.IdempotencyKey, error) {
+			assert.Equal(t, anonymousIdempotencyScope, input.Scope)
+			assert.Equal(t, "a3a1c6e2", input.Key)
+			assert.Len(t, input.Fingerprint, 64)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), input.ExpiresAt, time.Minute)
+			return true, repository.IdempotencyKey{}, nil
+		}).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistence("+62", "89627117").Return(false, nil).Times(1)
+		mockRepository.EXPECT().CreateProfile(gomock.Any()).Return(12, nil).Times(1)
+		mockRepository.EXPECT().CompleteIdempotencyKey(anonymousIdempotencyScope, "a3a1c6e2", http.StatusCreated, gomock.Any(), gomock.Any()).DoAndReturn(func(scope string, key string, statusCode int, header map[string][]string, body []byte) error {
+			assert.Equal(t, []string{echo.MIMEApplicationJSONCharsetUTF8}, header[echo.HeaderContentType])
+			assert.Contains(t, string(body), `"created_id":12`)
+			return nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, IdempotencyKeyTTL: time.Hour, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Empty(t, rec.Header().Get(idempotentReplayedHeader))
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", "a3a1c6e2", registration)
+
+		mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(input repository.IdempotencyKey, abandonedBefore time.Time) (bool, repository.IdempotencyKey, error) {
+			return false, repository.IdempotencyKey{
+				Scope:       input.Scope,
+				Key:         input.Key,
+				Fingerprint: input.Fingerprint,
+				StatusCode:  http.StatusCreated,
+				Header:      map[string][]string{echo.HeaderContentType: {echo.MIMEApplicationJSONCharsetUTF8}},
+				Body:        []byte(`{"created_id":12,"message":"Profile is successfully created"}`),
+			}, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "true", rec.Header().Get(idempotentReplayedHeader))
+
+			var resp generated.CreateProfileResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.NotNil(t, resp.CreatedId) {
+				assert.Equal(t, 12, *resp.CreatedId)
+			}
+		}
+	})
+
+	t.Run("Reused For Another Request", func(t *testing.T) {
+		context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", "a3a1c6e2", registration)
+
+		mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, repository.IdempotencyKey{Fingerprint: "another request", StatusCode: http.StatusCreated}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "idempotency_key_reused", resp.Code)
+		}
+	})
+
+	t.Run("In Progress", func(t *testing.T) {
+		context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", "a3a1c6e2", registration)
+
+		mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(input repository.IdempotencyKey, abandonedBefore time.Time) (bool, repository.IdempotencyKey, error) {
+			return false, repository.IdempotencyKey{Fingerprint: input.Fingerprint}, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("Server Error Releases The Key", func(t *testing.T) {
+		context, _, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", "a3a1c6e2", registration)
+
+		mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, repository.IdempotencyKey{}, nil).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistence("+62", "89627117").Return(false, errors.New("connection refused")).Times(1)
+		mockRepository.EXPECT().DeleteIdempotencyKey(anonymousIdempotencyScope, "a3a1c6e2").Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		assert.Error(t, mockServer.RequestValidator()(mockServer.PostProfile)(context))
+	})
+
+	t.Run("Key Too Long", func(t *testing.T) {
+		context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", strings.Repeat("k", maxIdempotencyKeyLength+1), registration)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Same Key For Another Profile", func(t *testing.T) {
+		operator := repository.Profile{ID: 1}
+		token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleAdmin}, []string{rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite})
+
+		fingerprints := []string{}
+		for _, id := range []int{12, 13} {
+			context, _, mockRepository := setupTestIdempotency(t, http.MethodPost, "/v1/admin/profiles/"+strconv.Itoa(id)+"/suspend", "a3a1c6e2", `{"reason": "spam"}`)
+			context.Request().Header.Set("Authorization", "Bearer "+token)
+
+			mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(input repository.IdempotencyKey, abandonedBefore time.Time) (bool, repository.IdempotencyKey, error) {
+				assert.Equal(t, "profile:1", input.Scope)
+				fingerprints = append(fingerprints, input.Fingerprint)
+				return false, repository.IdempotencyKey{Fingerprint: "the suspension of profile 12"}, nil
+			}).Times(1)
+			mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+			handler := func(ctx echo.Context) error {
+				return mockServer.PostAdminProfilesIdSuspend(ctx, id)
+			}
+			assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context))
+		}
+
+		// the suspension of profile 13 isn't answered with the response of profile 12
+		if assert.Len(t, fingerprints, 2) {
+			assert.NotEqual(t, fingerprints[0], fingerprints[1])
+		}
+	})
+
+	t.Run("Same Key For Another Precondition", func(t *testing.T) {
+		fingerprints := []string{}
+		for _, etag := range []string{`"3"`, `"4"`} {
+			context, _, mockRepository := setupTestIdempotency(t, http.MethodPatch, "/profile", "a3a1c6e2", `{"full_name": "Bakri"}`)
+			context.Request().Header.Set(echo.HeaderContentType, mergePatchContentType)
+			context.Request().Header.Set("If-Match", etag)
+
+			mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(input repository.IdempotencyKey, abandonedBefore time.Time) (bool, repository.IdempotencyKey, error) {
+				fingerprints = append(fingerprints, input.Fingerprint)
+				return false, repository.IdempotencyKey{Fingerprint: input.Fingerprint}, nil
+			}).Times(1)
+			mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+			handler := func(ctx echo.Context) error {
+				return mockServer.PatchProfile(ctx, generated.PatchProfileParams{})
+			}
+			assert.NoError(t, mockServer.RequestValidator()(handler)(context))
+		}
+
+		if assert.Len(t, fingerprints, 2) {
+			assert.NotEqual(t, fingerprints[0], fingerprints[1])
+		}
+	})
+
+	t.Run("Retry From Another Address", func(t *testing.T) {
+		stored := map[string]repository.IdempotencyKey{}
+		for i, remoteAddr := range []string{"192.0.2.1:1234", "198.51.100.7:52100"} {
+			context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/profile", "a3a1c6e2", registration)
+			context.Request().RemoteAddr = remoteAddr
+
+			mockRepository.EXPECT().ReserveIdempotencyKey(gomock.Any(), gomock.Any()).DoAndReturn(func(input repository.IdempotencyKey, abandonedBefore time.Time) (bool, repository.IdempotencyKey, error) {
+				existing, ok := stored[input.Scope+"/"+input.Key]
+				if ok {
+					return false, existing, nil
+				}
+				stored[input.Scope+"/"+input.Key] = input
+				return true, repository.IdempotencyKey{}, nil
+			}).Times(1)
+			mockRepository.EXPECT().GetPhoneNumberExistence("+62", "89627117").Return(false, nil).MaxTimes(1)
+			mockRepository.EXPECT().CreateProfile(gomock.Any()).Return(12, nil).MaxTimes(1)
+			mockRepository.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(scope string, key string, statusCode int, header map[string][]string, body []byte) error {
+				record := stored[scope+"/"+key]
+				record.StatusCode, record.Header, record.Body = statusCode, header, body
+				stored[scope+"/"+key] = record
+				return nil
+			}).MaxTimes(1)
+			mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+			if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+				assert.Equal(t, http.StatusCreated, rec.Code)
+				// the client changed networks, e.g. from wifi to mobile data, before retrying
+				assert.Equal(t, i == 1, rec.Header().Get(idempotentReplayedHeader) == "true")
+			}
+		}
+	})
+
+	t.Run("Operation Without Idempotency Keys", func(t *testing.T) {
+		context, rec, mockRepository := setupTestIdempotency(t, http.MethodPost, "/login", "a3a1c6e2", `{"phone_number": "+6289627117", "password": "1n19s9H88@"}`)
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(repository.Profile{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLogin)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,490 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// Formats of the files read by ImportProfiles
+const (
+	ImportFormatCSV   = "csv"
+	ImportFormatJSONL = "jsonl"
+)
+
+// importBatchSize is the number of profiles copied to the database at once
+const importBatchSize = 1000
+
+// importMaxLineLength bounds the memory used by a line of a JSON Lines file
+const importMaxLineLength = 64 * 1024
+
+// ImportOptions configures ImportProfiles
+type ImportOptions struct {
+	// Format is ImportFormatCSV or ImportFormatJSONL
+	Format string
+	// DryRun validates the rows and checks the registered numbers without creating the profiles
+	DryRun bool
+	// Language of the messages of the row errors, English by default
+	Language string
+	// OnRowError receives every skipped row, in the order of the file
+	OnRowError func(generated.ProfileImportRowError)
+}
+
+// ImportSummary counts the rows of an import
+type ImportSummary struct {
+	Total int
+	// Imported is the number of profiles created, or which would be created by a dry run
+	Imported int
+	Failed   int
+}
+
+// ImportInputError reports a file which can't be read at all, e.g. a CSV file without the required columns. It's
+// returned before any row is read, the rows which can't be read are reported as malformed.
+type ImportInputError struct {
+	reason string
+}
+
+func (e *ImportInputError) Error() string {
+	return e.reason
+}
+
+// importRow is a row of the file, PasswordHash is a bcrypt hash created by another system
+type importRow struct {
+	FullName     string  `json:"full_name"`
+	PhoneNumber  string  `json:"phone_number"`
+	Password     *string `json:"password"`
+	PasswordHash *string `json:"password_hash"`
+
+	line int
+	// malformed is set when the row can't be decoded
+	malformed error
+}
+
+// pendingImport is a valid row waiting for its batch to be copied
+type pendingImport struct {
+	line int
+	// phoneNumber is the normalized number, givenPhoneNumber the one of the file
+	phoneNumber      string
+	givenPhoneNumber string
+	profile          repository.Profile
+	// password is hashed before the copy when the row isn't pre-hashed
+	password string
+}
+
+func (s *Server) PostAdminProfilesImport(ctx echo.Context, params generated.PostAdminProfilesImportParams) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	_, err = s.extractOperatorIDFromToken(token, rbac.PermissionProfilesWrite)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	formats := map[string]string{
+		"text/csv":             ImportFormatCSV,
+		"application/x-ndjson": ImportFormatJSONL,
+	}
+	format, ok := formats[mediaType]
+	if err != nil || !ok {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	report := generated.ProfileImportReport{
+		DryRun: params.DryRun != nil && *params.DryRun,
+		Errors: []generated.ProfileImportRowError{},
+	}
+	summary, err := s.ImportProfiles(ctx.Request().Body, ImportOptions{
+		Format:   format,
+		DryRun:   report.DryRun,
+		Language: requestLanguage(ctx),
+		OnRowError: func(rowError generated.ProfileImportRowError) {
+			report.Errors = append(report.Errors, rowError)
+		},
+	})
+	var inputError *ImportInputError
+	if errors.As(err, &inputError) {
+		responsePayload := errorResponse(ctx, msgInvalidRequestBody)
+		responsePayload.Message = inputError.Error()
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+	if err != nil {
+		log.Printf("error import profiles after %d rows : %v", summary.Total, err)
+		return err
+	}
+
+	report.Total = summary.Total
+	report.Imported = summary.Imported
+	report.Failed = summary.Failed
+
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// ImportProfiles creates the profiles of a CSV or JSON Lines file, see POST /admin/profiles/import. The file is
+// read as a stream and the valid rows are copied in batches, so an error other than an ImportInputError, e.g. of
+// the database, stops the import after the batches already copied, which summary counts.
+func (s *Server) ImportProfiles(input io.Reader, opts ImportOptions) (summary ImportSummary, err error) {
+	var next func() (importRow, error)
+	switch opts.Format {
+	case ImportFormatCSV:
+		next, err = csvImportRows(input)
+	case ImportFormatJSONL:
+		next = jsonlImportRows(input)
+	default:
+		err = &ImportInputError{reason: fmt.Sprintf("unknown format %q, use csv or jsonl", opts.Format)}
+	}
+	if err != nil {
+		return summary, err
+	}
+
+	lang := opts.Language
+	if lang == "" {
+		lang = supportedLanguages[0]
+	}
+	reject := func(line int, phoneNumber string, fields []FieldValidationError) {
+		summary.Failed++
+		if opts.OnRowError == nil {
+			return
+		}
+
+		rowError := generated.ProfileImportRowError{Line: line, Errors: []generated.ValidationErrorDetail{}}
+		if phoneNumber != "" {
+			rowError.PhoneNumber = &phoneNumber
+		}
+		for _, field := range fields {
+			rowError.Errors = append(rowError.Errors, generated.ValidationErrorDetail{
+				Field:   field.Field,
+				Rule:    field.Rule,
+				Message: validationMessageIn(lang, field),
+				Params:  append([]string{}, field.Params...),
+			})
+		}
+		opts.OnRowError(rowError)
+	}
+
+	// the line of the first valid row of every phone number, the numbers repeated in the file are rejected
+	seen := map[string]int{}
+	batch := make([]pendingImport, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		imported, err := s.copyImportBatch(batch, opts.DryRun, reject)
+		summary.Imported += imported
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.Total++
+
+		if row.malformed != nil {
+			reject(row.line, "", []FieldValidationError{{Field: "row", Rule: "malformed", Params: []string{row.malformed.Error()}}})
+			continue
+		}
+
+		pending, fields := s.validateImportRow(row)
+		if len(fields) == 0 {
+			if firstLine, ok := seen[pending.phoneNumber]; ok {
+				fields = []FieldValidationError{{Field: "phone_number", Rule: "duplicate", Params: []string{strconv.Itoa(firstLine)}}}
+			}
+		}
+		if len(fields) > 0 {
+			reject(row.line, row.PhoneNumber, fields)
+			continue
+		}
+
+		seen[pending.phoneNumber] = row.line
+		batch = append(batch, pending)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	return summary, flush()
+}
+
+// validateImportRow checks a row with the rules of PostProfile, the password may be replaced by a bcrypt hash
+func (s *Server) validateImportRow(row importRow) (pending pendingImport, fields []FieldValidationError) {
+	pending.line = row.line
+	pending.givenPhoneNumber = row.PhoneNumber
+
+	fields = append(fields, s.Validator.validateProperty("CreateProfileRequest", "full_name", row.FullName)...)
+
+	phoneFields := s.Validator.validateProperty("CreateProfileRequest", "phone_number", row.PhoneNumber)
+	if len(phoneFields) == 0 {
+		phoneNumber, err := s.PhoneParser.Parse(row.PhoneNumber)
+		if err != nil {
+			var phoneError *ValidationError
+			if !errors.As(phoneNumberError("phone_number", err), &phoneError) {
+				phoneError = &ValidationError{Fields: []FieldValidationError{{Field: "phone_number", Rule: "e164", Params: []string{}}}}
+			}
+			phoneFields = phoneError.Fields
+		} else {
+			pending.phoneNumber = phoneNumber.E164()
+			pending.profile.CountryCode = phoneNumber.CountryCode
+			pending.profile.PhoneNumber = phoneNumber.NationalNumber
+		}
+	}
+	fields = append(fields, phoneFields...)
+
+	switch {
+	case row.Password != nil && row.PasswordHash != nil:
+		fields = append(fields, FieldValidationError{Field: "password_hash", Rule: "exclusive", Params: []string{"password"}})
+
+	case row.PasswordHash != nil:
+		if !password.IsBcryptHash(*row.PasswordHash) {
+			fields = append(fields, FieldValidationError{Field: "password_hash", Rule: "bcrypt", Params: []string{}})
+		}
+		pending.profile.Password = *row.PasswordHash
+
+	default:
+		var plain string
+		if row.Password != nil {
+			plain = *row.Password
+		}
+		passwordFields := s.Validator.validateProperty("CreateProfileRequest", "password", plain)
+		if len(passwordFields) == 0 {
+			violations := s.PasswordChecker.Check(password.Candidate{
+				Password:     plain,
+				FullName:     row.FullName,
+				PhoneNumbers: []string{pending.phoneNumber, pending.profile.PhoneNumber},
+			})
+			if len(violations) > 0 {
+				passwordFields = passwordPolicyError("password", violations).(*ValidationError).Fields
+			}
+		}
+		fields = append(fields, passwordFields...)
+		pending.password = plain
+	}
+
+	pending.profile.FullName = row.FullName
+	return pending, fields
+}
+
+// copyImportBatch creates the profiles of batch, or only looks for their registered numbers on a dry run, and
+// rejects the rows whose number is already registered
+func (s *Server) copyImportBatch(batch []pendingImport, dryRun bool, reject func(int, string, []FieldValidationError)) (imported int, err error) {
+	// a dry run doesn't need the hashes, which are the slow part of the import
+	if !dryRun {
+		err = s.hashImportPasswords(batch)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	profiles := make([]repository.Profile, 0, len(batch))
+	for _, pending := range batch {
+		profiles = append(profiles, pending.profile)
+	}
+
+	duplicates, err := s.Repository.ImportProfiles(profiles, dryRun)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, position := range duplicates {
+		pending := batch[position]
+		reject(pending.line, pending.givenPhoneNumber, []FieldValidationError{{Field: "phone_number", Rule: "alreadyRegistered", Params: []string{}}})
+	}
+	return len(batch) - len(duplicates), nil
+}
+
+// hashImportPasswords hashes the plain passwords of batch on every CPU
+func (s *Server) hashImportPasswords(batch []pendingImport) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	slots := make(chan struct{}, runtime.NumCPU())
+
+	for i := range batch {
+		if batch[i].password == "" {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(pending *pendingImport) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			hashedPassword, err := s.PasswordHasher.Hash(pending.password)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			pending.profile.Password = hashedPassword
+		}(&batch[i])
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// validateProperty validates value against a property of a schema of api.yml
+func (v *CustomValidator) validateProperty(schemaName string, property string, value string) []FieldValidationError {
+	schema := v.swagger.Components.Schemas[schemaName].Value
+	if value == "" {
+		for _, required := range schema.Required {
+			if required == property {
+				return []FieldValidationError{{Field: property, Rule: "required", Params: []string{}}}
+			}
+		}
+	}
+
+	err := schema.Properties[property].Value.VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return nil
+	}
+	validationError, ok := toValidationError(err)
+	if !ok {
+		return []FieldValidationError{{Field: property, Rule: "default", Params: []string{}}}
+	}
+	for i := range validationError.Fields {
+		validationError.Fields[i].Field = property
+	}
+	return validationError.Fields
+}
+
+// csvImportRows reads the header of a CSV file and returns the reader of its rows
+func csvImportRows(input io.Reader) (func() (importRow, error), error) {
+	reader := csv.NewReader(input)
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &ImportInputError{reason: "the file is empty"}
+	}
+	if err != nil {
+		return nil, &ImportInputError{reason: "the header can't be read: " + err.Error()}
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"full_name", "phone_number"} {
+		if _, ok := columns[required]; !ok {
+			return nil, &ImportInputError{reason: fmt.Sprintf("the header has no %s column", required)}
+		}
+	}
+	_, hasPassword := columns["password"]
+	_, hasPasswordHash := columns["password_hash"]
+	if !hasPassword && !hasPasswordHash {
+		return nil, &ImportInputError{reason: "the header has no password or password_hash column"}
+	}
+	reader.FieldsPerRecord = len(header)
+
+	// an empty cell is a missing value
+	cell := func(record []string, column string) *string {
+		i, ok := columns[column]
+		if !ok || record[i] == "" {
+			return nil
+		}
+		value := record[i]
+		return &value
+	}
+
+	return func() (importRow, error) {
+		record, err := reader.Read()
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			return importRow{line: parseError.StartLine, malformed: parseError.Err}, nil
+		}
+		if err != nil {
+			return importRow{}, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line, Password: cell(record, "password"), PasswordHash: cell(record, "password_hash")}
+		if fullName := cell(record, "full_name"); fullName != nil {
+			row.FullName = *fullName
+		}
+		if phoneNumber := cell(record, "phone_number"); phoneNumber != nil {
+			row.PhoneNumber = *phoneNumber
+		}
+		return row, nil
+	}, nil
+}
+
+// jsonlImportRows returns the reader of the rows of a JSON Lines file, one object per line. A line longer than
+// importMaxLineLength is a malformed row, the rows after it are read as usual.
+func jsonlImportRows(input io.Reader) func() (importRow, error) {
+	reader := bufio.NewReaderSize(input, importMaxLineLength)
+	line := 0
+
+	return func() (importRow, error) {
+		for {
+			data, err := reader.ReadSlice('\n')
+			if len(data) == 0 && err == io.EOF {
+				return importRow{}, io.EOF
+			}
+			line++
+
+			if err == bufio.ErrBufferFull {
+				// the rest of the line is skipped
+				for err == bufio.ErrBufferFull {
+					_, err = reader.ReadSlice('\n')
+				}
+				if err != nil && err != io.EOF {
+					return importRow{}, err
+				}
+				return importRow{line: line, malformed: fmt.Errorf("the line is longer than %d bytes", importMaxLineLength)}, nil
+			}
+			if err != nil && err != io.EOF {
+				return importRow{}, err
+			}
+
+			data = bytes.TrimSpace(data)
+			if len(data) == 0 {
+				continue
+			}
+
+			row := importRow{}
+			if err := json.Unmarshal(data, &row); err != nil {
+				return importRow{line: line, malformed: err}, nil
+			}
+			row.line = line
+			return row, nil
+		}
+	}
+}
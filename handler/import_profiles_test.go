@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestImportProfiles(t *testing.T, path string, token string, contentType string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestPostAdminProfilesImport(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleAdmin}, []string{rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite})
+
+	// a fast policy, the hashes of the imported passwords are only checked for their algorithm
+	fastPolicy := password.Policy{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4}
+	legacyHasher, _ := password.NewHasher(fastPolicy)
+	legacyHash, _ := legacyHasher.Hash("1n19s9H88@")
+
+	t.Run("CSV Dry Run", func(t *testing.T) {
+		file := "full_name,phone_number,password,password_hash\n" +
+			"Bakri,+6289627117,1n19s9H88@,\n" +
+			"Bakti,0812-3456-789,," + legacyHash + "\n" +
+			"Bo,+6281111111,1n19s9H88@,\n" +
+			"Bakri Dua,0896-2711-7,1n19s9H88@,\n" +
+			"Bakso,+6287654321,1n19s9H88@," + legacyHash + "\n" +
+			"Bakar,+6281234999\n" +
+			"Bakwan,+6281234888,1n19s9H88@,\n"
+		context, rec, mockRepository := setupTestImportProfiles(t, "/admin/profiles/import?dry_run=true", token, "text/csv", file)
+
+		dryRun := true
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().ImportProfiles([]repository.Profile{
+			{FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117"},
+			{FullName: "Bakti", CountryCode: "+62", PhoneNumber: "8123456789", Password: legacyHash},
+			{FullName: "Bakwan", CountryCode: "+62", PhoneNumber: "81234888"},
+		}, true).Return([]int{2}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: fastPolicy, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PostAdminProfilesImport(ctx, generated.PostAdminProfilesImportParams{DryRun: &dryRun})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ProfileImportReport
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.True(t, resp.DryRun)
+			assert.Equal(t, 7, resp.Total)
+			assert.Equal(t, 2, resp.Imported)
+			assert.Equal(t, 5, resp.Failed)
+
+			rules := map[int]string{}
+			for _, rowError := range resp.Errors {
+				if assert.NotEmpty(t, rowError.Errors) {
+					rules[rowError.Line] = rowError.Errors[0].Field + ":" + rowError.Errors[0].Rule
+				}
+			}
+			assert.Equal(t, map[int]string{
+				4: "full_name:minLength",
+				5: "phone_number:duplicate",
+				6: "password_hash:exclusive",
+				7: "row:malformed",
+				8: "phone_number:alreadyRegistered",
+			}, rules)
+		}
+	})
+
+	t.Run("JSONL Import", func(t *testing.T) {
+		file := `{"full_name": "Bakri", "phone_number": "+6289627117", "password": "1n19s9H88@"}` + "\n" +
+			"\n" +
+			`{"full_name": "Bakti", "phone_number": "+6281234567", "password_hash": "$2a$10$not-a-bcrypt-hash"}` + "\n" +
+			`{"full_name": "Bakso", "phone_number": "+6287654321", "password": "bakso"}` + "\n" +
+			`{"full_name": "Bakwan"`
+		context, rec, mockRepository := setupTestImportProfiles(t, "/admin/profiles/import", token, "application/x-ndjson", file)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().ImportProfiles(gomock.Any(), false).DoAndReturn(func(profiles []repository.Profile, dryRun bool) ([]int, error) {
+			if assert.Len(t, profiles, 1) {
+				assert.Equal(t, "89627117", profiles[0].PhoneNumber)
+				assert.True(t, strings.HasPrefix(profiles[0].Password, "$2a$04$"))
+			}
+			return nil, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: fastPolicy})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesImport(context, generated.PostAdminProfilesImportParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ProfileImportReport
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.False(t, resp.DryRun)
+			assert.Equal(t, 4, resp.Total)
+			assert.Equal(t, 1, resp.Imported)
+			if assert.Len(t, resp.Errors, 3) {
+				assert.Equal(t, 3, resp.Errors[0].Line)
+				assert.Equal(t, "bcrypt", resp.Errors[0].Errors[0].Rule)
+				assert.Equal(t, "password", resp.Errors[1].Errors[0].Field)
+				assert.Equal(t, "malformed", resp.Errors[2].Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Line Too Long After A Batch", func(t *testing.T) {
+		var file strings.Builder
+		for i := 0; i < importBatchSize; i++ {
+			fmt.Fprintf(&file, `{"full_name": "Bakri", "phone_number": "+62812%07d", "password_hash": %q}`+"\n", i, legacyHash)
+		}
+		fmt.Fprintf(&file, `{"full_name": "%s"}`+"\n", strings.Repeat("x", importMaxLineLength))
+		fmt.Fprintf(&file, `{"full_name": "Bakti", "phone_number": "+6289627117", "password_hash": %q}`+"\n", legacyHash)
+		context, rec, mockRepository := setupTestImportProfiles(t, "/admin/profiles/import", token, "application/x-ndjson", file.String())
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		gomock.InOrder(
+			mockRepository.EXPECT().ImportProfiles(gomock.Len(importBatchSize), false).Return(nil, nil).Times(1),
+			mockRepository.EXPECT().ImportProfiles([]repository.Profile{
+				{FullName: "Bakti", CountryCode: "+62", PhoneNumber: "89627117", Password: legacyHash},
+			}, false).Return(nil, nil).Times(1),
+		)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: fastPolicy, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PostAdminProfilesImport(ctx, generated.PostAdminProfilesImportParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ProfileImportReport
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, importBatchSize+2, resp.Total)
+			assert.Equal(t, importBatchSize+1, resp.Imported)
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, importBatchSize+1, resp.Errors[0].Line)
+				assert.Equal(t, "row", resp.Errors[0].Errors[0].Field)
+				assert.Equal(t, "malformed", resp.Errors[0].Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Missing Column", func(t *testing.T) {
+		context, rec, mockRepository := setupTestImportProfiles(t, "/admin/profiles/import", token, "text/csv", "full_name,password\nBakri,1n19s9H88@\n")
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesImport(context, generated.PostAdminProfilesImportParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "the header has no phone_number column", resp.Message)
+		}
+	})
+
+	t.Run("Unsupported Content Type", func(t *testing.T) {
+		context, rec, mockRepository := setupTestImportProfiles(t, "/admin/profiles/import", token, echo.MIMEApplicationJSON, "[]")
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesImport(context, generated.PostAdminProfilesImportParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+}
@@ -5,8 +5,10 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
 )
@@ -17,33 +19,131 @@ func (s *Server) PostLogin(ctx echo.Context) error {
 
 	err := ctx.Bind(&request)
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, err.Error())
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
 	}
 
-	hasPrefix := strings.HasPrefix(request.PhoneNumber, "+62")
-	if !hasPrefix {
-		responsePayload := generated.GeneralErrorResponse{Message: "Account not found"}
-		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	// the account is identified by exactly one of its phone number and its email
+	if (request.PhoneNumber == nil) == (request.Email == nil) {
+		fieldError := FieldValidationError{Field: "phone_number", Rule: "required", Params: []string{}}
+		if request.PhoneNumber != nil {
+			fieldError = FieldValidationError{Field: "email", Rule: "exclusive", Params: []string{"phone_number"}}
+		}
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, &ValidationError{Fields: []FieldValidationError{fieldError}}))
 	}
 
-	localPhoneNumber := strings.Replace(request.PhoneNumber, "+62", "", -1)
-	existingProfile, err := s.Repository.GetProfileByPhoneNumber(localPhoneNumber)
+	existingProfile, err := s.findLoginProfile(request)
 	if err == sql.ErrNoRows {
-		responsePayload := generated.GeneralErrorResponse{Message: "Account not found"}
+		responsePayload := errorResponse(ctx, msgAccountNotFound)
 		return ctx.JSON(http.StatusBadRequest, responsePayload)
 	}
 	if err != nil {
-		log.Println("error fetch profile by phone number : ", err)
+		log.Println("error fetch profile of login : ", err)
 		return err
 	}
 
-	isPasswordValid := comparePasswords(existingProfile.Password, []byte(request.Password))
+	isPasswordValid, err := s.PasswordHasher.Verify(request.Password, existingProfile.Password)
+	if err != nil {
+		log.Println("error verify password : ", err)
+	}
 	if !isPasswordValid {
-		responsePayload := generated.GeneralErrorResponse{Message: "Password doesn't match"}
+		s.recordSecurityEvent(ctx, existingProfile.ID, repository.SecurityEventLoginFailed, map[string]string{"method": loginMethodPassword})
+		responsePayload := errorResponse(ctx, msgPasswordMismatch)
 		return ctx.JSON(http.StatusBadRequest, responsePayload)
 	}
 
-	token, err := createToken(existingProfile)
+	// Hashes created with an older algorithm or cost are upgraded while the password is known
+	if s.PasswordHasher.NeedsRehash(existingProfile.Password) {
+		s.rehashPassword(existingProfile.ID, request.Password)
+	}
+
+	return s.continueLogin(ctx, existingProfile, loginMethodPassword)
+}
+
+// findLoginProfile returns the profile of the phone number or the email of the login, sql.ErrNoRows when there's
+// none. The emails which haven't been verified don't identify their profile.
+func (s *Server) findLoginProfile(request generated.LoginRequest) (repository.Profile, error) {
+	if request.Email != nil {
+		profile, err := s.Repository.GetProfileByEmail(strings.ToLower(*request.Email))
+		if err == nil && profile.EmailVerifiedAt == nil {
+			return repository.Profile{}, sql.ErrNoRows
+		}
+		return profile, err
+	}
+
+	phoneNumber, err := s.PhoneParser.Parse(*request.PhoneNumber)
+	if err != nil {
+		return repository.Profile{}, sql.ErrNoRows
+	}
+	return s.Repository.GetProfileByPhoneNumber(phoneNumber.CountryCode, phoneNumber.NationalNumber)
+}
+
+// continueLogin asks for the second factor when it's enabled, otherwise it completes the login
+// made with the given method
+func (s *Server) continueLogin(ctx echo.Context, existingProfile repository.Profile, method string) error {
+	if existingProfile.Status == repository.ProfileStatusSuspended {
+		return s.refuseSuspendedLogin(ctx, existingProfile, method)
+	}
+
+	mfa, err := s.Repository.GetProfileMFA(existingProfile.ID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("error fetch mfa : ", err)
+		return err
+	}
+	if err == nil && mfa.ConfirmedAt != nil {
+		challengeID, err := s.Repository.CreateMFAChallenge(repository.MFAChallenge{
+			ProfileID: existingProfile.ID,
+			ExpiresAt: time.Now().Add(mfaChallengeLifetime),
+		})
+		if err != nil {
+			log.Println("error create mfa challenge : ", err)
+			return err
+		}
+
+		challengeToken, err := createMFAChallengeToken(existingProfile, challengeID)
+		if err != nil {
+			log.Println("error create challenge token : ", err)
+			return err
+		}
+
+		resp := generated.MFAChallengeResponse{
+			ChallengeToken: challengeToken,
+			ExpiresIn:      int(mfaChallengeLifetime.Seconds()),
+		}
+		return ctx.JSON(http.StatusAccepted, resp)
+	}
+
+	return s.completeLogin(ctx, existingProfile, method)
+}
+
+// completeLogin issues the access token once every factor of the login has been verified, method is the one of
+// the last factor
+func (s *Server) completeLogin(ctx echo.Context, existingProfile repository.Profile, method string) error {
+	session := repository.Session{
+		ProfileID:  existingProfile.ID,
+		DeviceName: deviceName(ctx.Request().UserAgent()),
+		IPAddress:  clientIP(ctx),
+		ExpiresAt:  time.Now().Add(accessTokenLifetime),
+	}
+	sessionID, err := s.Repository.CreateSession(session)
+	if err == sql.ErrNoRows {
+		// the profile has been suspended since its factors were verified
+		return s.refuseSuspendedLogin(ctx, existingProfile, method)
+	}
+	if err != nil {
+		log.Println("error create session : ", err)
+		responsePayload := errorResponse(ctx, msgInternalServerError)
+		return ctx.JSON(http.StatusInternalServerError, responsePayload)
+	}
+
+	roles, err := s.Repository.GetProfileRoles(existingProfile.ID)
+	if err != nil {
+		log.Println("error fetch roles : ", err)
+		responsePayload := errorResponse(ctx, msgInternalServerError)
+		return ctx.JSON(http.StatusInternalServerError, responsePayload)
+	}
+	roles = append([]string{rbac.RoleUser}, roles...)
+
+	token, err := createToken(existingProfile, sessionID, roles, s.RolePolicy.Permissions(roles))
 	if err != nil {
 		log.Println("error create token : ", err)
 		return err
@@ -52,11 +152,39 @@ func (s *Server) PostLogin(ctx echo.Context) error {
 	_, err = s.Repository.UpsertProfileMetaData(profileMetadata)
 	if err != nil {
 		log.Println("error Upserting MetaData : ", err)
-		responsePayload := generated.GeneralErrorResponse{Message: "Internal Server Error"}
+		responsePayload := errorResponse(ctx, msgInternalServerError)
 		return ctx.JSON(http.StatusInternalServerError, responsePayload)
 	}
 
+	s.recordSecurityEvent(ctx, existingProfile.ID, repository.SecurityEventLoginSucceeded, map[string]string{"method": method})
+
 	resp := generated.LoginResponse{JwtToken: token, UserId: int(existingProfile.ID)}
 
 	return ctx.JSON(http.StatusOK, resp)
 }
+
+// refuseSuspendedLogin answers the login of a suspended profile once its factors are verified, so the status isn't
+// revealed to whoever only knows the phone number
+func (s *Server) refuseSuspendedLogin(ctx echo.Context, existingProfile repository.Profile, method string) error {
+	s.recordSecurityEvent(ctx, existingProfile.ID, repository.SecurityEventLoginFailed, map[string]string{
+		"method": method,
+		"reason": repository.ProfileStatusSuspended,
+	})
+	responsePayload := errorResponse(ctx, msgProfileSuspended)
+	return ctx.JSON(http.StatusForbidden, responsePayload)
+}
+
+// rehashPassword stores the password hashed with the current policy, a failure doesn't fail the login
+// since the outdated hash is still valid
+func (s *Server) rehashPassword(profileID uint64, plainPassword string) {
+	hashedPassword, err := s.PasswordHasher.Hash(plainPassword)
+	if err != nil {
+		log.Println("error rehash password : ", err)
+		return
+	}
+
+	err = s.Repository.UpdatePasswordByID(profileID, hashedPassword)
+	if err != nil {
+		log.Println("error update rehashed password : ", err)
+	}
+}
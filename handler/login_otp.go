@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// loginOTPLifetime is how long a code sent by SMS can be used
+	loginOTPLifetime = 5 * time.Minute
+	// loginOTPResendInterval limits the SMS sent to a phone number
+	loginOTPResendInterval = time.Minute
+	// loginOTPWindowLimit is the number of codes sent to a phone number in loginOTPWindow, it also limits the
+	// wrong codes which can be tried across the codes
+	loginOTPWindowLimit = 5
+	loginOTPWindow      = time.Hour
+	// loginOTPMaxAttempts is the number of wrong codes after which a new code has to be requested
+	loginOTPMaxAttempts = 5
+	loginOTPDigits      = 6
+)
+
+func (s *Server) PostLoginOtpRequest(ctx echo.Context) error {
+
+	var request generated.RequestLoginOTPRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	phoneNumber, err := s.PhoneParser.Parse(request.PhoneNumber)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, phoneNumberError("phone_number", err)))
+	}
+
+	resp := generated.RequestLoginOTPResponse{
+		ExpiresIn: int(loginOTPLifetime.Seconds()),
+		ResendIn:  int(loginOTPResendInterval.Seconds()),
+	}
+
+	// the numbers are throttled whether they are registered or not, so the throttling can't be used to find them
+	now := time.Now()
+	reserved, err := s.Repository.ReserveLoginOTPRequest(phoneNumber.E164(), now.Add(-loginOTPResendInterval), now.Add(-loginOTPWindow), loginOTPWindowLimit)
+	if err != nil {
+		log.Println("error reserve login otp request : ", err)
+		return err
+	}
+	if !reserved {
+		responsePayload := errorResponse(ctx, msgOTPRequestedTooSoon)
+		return ctx.JSON(http.StatusTooManyRequests, responsePayload)
+	}
+
+	existingProfile, err := s.Repository.GetProfileByPhoneNumber(phoneNumber.CountryCode, phoneNumber.NationalNumber)
+	if err == sql.ErrNoRows || (err == nil && existingProfile.Status == repository.ProfileStatusSuspended) {
+		// the same response as for registered numbers, so the endpoint can't be used to find them, no code is
+		// sent to the suspended profiles either
+		return ctx.JSON(http.StatusAccepted, resp)
+	}
+	if err != nil {
+		log.Println("error fetch profile by phone number : ", err)
+		return err
+	}
+
+	code, err := generateLoginOTP()
+	if err != nil {
+		log.Println("error generate login otp : ", err)
+		return err
+	}
+
+	_, err = s.Repository.CreateLoginOTP(repository.LoginOTP{
+		ProfileID: existingProfile.ID,
+		CodeHash:  hashLoginOTP(existingProfile.ID, code),
+		ExpiresAt: time.Now().Add(loginOTPLifetime),
+	})
+	if err != nil {
+		log.Println("error create login otp : ", err)
+		return err
+	}
+
+	message := localize(ctx, msgOTPMessage, code, int(loginOTPLifetime.Minutes()))
+	err = s.SMSSender.Send(ctx.Request().Context(), phoneNumber.E164(), message)
+	if err != nil {
+		log.Println("error send login otp : ", err)
+		responsePayload := errorResponse(ctx, msgInternalServerError)
+		return ctx.JSON(http.StatusInternalServerError, responsePayload)
+	}
+
+	return ctx.JSON(http.StatusAccepted, resp)
+}
+
+func (s *Server) PostLoginOtpVerify(ctx echo.Context) error {
+
+	var request generated.VerifyLoginOTPRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	phoneNumber, err := s.PhoneParser.Parse(request.PhoneNumber)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidOTP)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	existingProfile, err := s.Repository.GetProfileByPhoneNumber(phoneNumber.CountryCode, phoneNumber.NationalNumber)
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgInvalidOTP)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch profile by phone number : ", err)
+		return err
+	}
+
+	otp, err := s.Repository.GetLatestLoginOTP(existingProfile.ID)
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgInvalidOTP)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch login otp : ", err)
+		return err
+	}
+	if otp.UsedAt != nil || time.Now().After(otp.ExpiresAt) {
+		responsePayload := errorResponse(ctx, msgInvalidOTP)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	// the attempt is counted before the comparison, so parallel guesses can't exceed the limit
+	attempts, err := s.Repository.IncrementLoginOTPAttempts(otp.ID)
+	if err != nil {
+		log.Println("error count login otp attempt : ", err)
+		return err
+	}
+	if attempts > loginOTPMaxAttempts {
+		s.recordSecurityEvent(ctx, existingProfile.ID, repository.SecurityEventLoginFailed, map[string]string{"method": loginMethodOTP})
+		responsePayload := errorResponse(ctx, msgOTPAttemptsExceeded)
+		return ctx.JSON(http.StatusTooManyRequests, responsePayload)
+	}
+
+	expectedHash := hashLoginOTP(existingProfile.ID, request.Code)
+	if subtle.ConstantTimeCompare([]byte(expectedHash), []byte(otp.CodeHash)) != 1 {
+		s.recordSecurityEvent(ctx, existingProfile.ID, repository.SecurityEventLoginFailed, map[string]string{"method": loginMethodOTP})
+		responsePayload := errorResponse(ctx, msgInvalidOTP)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	used, err := s.Repository.UseLoginOTP(otp.ID)
+	if err != nil {
+		log.Println("error use login otp : ", err)
+		return err
+	}
+	if !used {
+		responsePayload := errorResponse(ctx, msgInvalidOTP)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	return s.continueLogin(ctx, existingProfile, loginMethodOTP)
+}
+
+// generateLoginOTP returns a random numeric code of loginOTPDigits digits
+func generateLoginOTP() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < loginOTPDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", loginOTPDigits, n), nil
+}
+
+// hashLoginOTP binds the code to its profile so that equal codes of different profiles have different hashes
+func hashLoginOTP(profileID uint64, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", profileID, code)))
+	return hex.EncodeToString(sum[:])
+}
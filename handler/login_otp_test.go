@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/hasbiasshidiq/simple-profile/sms"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLoginOTP(t *testing.T) {
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117"}
+	requestBody := `{"phone_number": "0896-2711-7"}`
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/request", "", requestBody)
+		context.Request().Header.Set("Accept-Language", "id")
+		smsPath := filepath.Join(t.TempDir(), "sms.jsonl")
+
+		var storedOTP repository.LoginOTP
+		mockRepository.EXPECT().ReserveLoginOTPRequest("+6289627117", gomock.Any(), gomock.Any(), loginOTPWindowLimit).DoAndReturn(func(phoneNumber string, requestedBefore time.Time, windowStartedBefore time.Time, maxPerWindow int) (bool, error) {
+			assert.WithinDuration(t, time.Now().Add(-loginOTPResendInterval), requestedBefore, time.Second)
+			assert.WithinDuration(t, time.Now().Add(-loginOTPWindow), windowStartedBefore, time.Second)
+			return true, nil
+		}).Times(1)
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().CreateLoginOTP(gomock.Any()).DoAndReturn(func(otp repository.LoginOTP) (int, error) {
+			storedOTP = otp
+			return 1, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{
+			Repository:        mockRepository,
+			SMSSender:         &sms.FileSender{Path: smsPath},
+			ValidateResponses: true,
+		})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLoginOtpRequest)(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+
+			messages, err := sms.ReadMessages(smsPath)
+			if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+				assert.Equal(t, "+6289627117", messages[0].PhoneNumber)
+
+				code := regexp.MustCompile(`\d{6}`).FindString(messages[0].Message)
+				assert.Regexp(t, `^Kode masuk Anda \d{6}, berlaku 5 menit`, messages[0].Message)
+
+				// only the hash of the code is stored
+				assert.Equal(t, hashLoginOTP(1, code), storedOTP.CodeHash)
+				assert.NotContains(t, storedOTP.CodeHash, code)
+				assert.WithinDuration(t, time.Now().Add(loginOTPLifetime), storedOTP.ExpiresAt, time.Second)
+			}
+		}
+	})
+
+	t.Run("Unknown Phone Number", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/request", "", requestBody)
+		smsPath := filepath.Join(t.TempDir(), "sms.jsonl")
+
+		mockRepository.EXPECT().ReserveLoginOTPRequest("+6289627117", gomock.Any(), gomock.Any(), loginOTPWindowLimit).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(repository.Profile{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, SMSSender: &sms.FileSender{Path: smsPath}})
+
+		if assert.NoError(t, mockServer.PostLoginOtpRequest(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.NoFileExists(t, smsPath)
+		}
+	})
+
+	t.Run("Requested Too Soon", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/request", "", requestBody)
+
+		// refused before the profile is looked up, the same for the numbers which aren't registered
+		mockRepository.EXPECT().ReserveLoginOTPRequest("+6289627117", gomock.Any(), gomock.Any(), loginOTPWindowLimit).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLoginOtpRequest)(context)) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		}
+	})
+}
+
+func TestVerifyLoginOTP(t *testing.T) {
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117"}
+	activeOTP := repository.LoginOTP{
+		ID:        7,
+		ProfileID: 1,
+		CodeHash:  hashLoginOTP(1, "123456"),
+		ExpiresAt: time.Now().Add(time.Minute),
+		CreatedAt: time.Now(),
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/verify", "", `{"phone_number": "+6289627117", "code": "123456"}`)
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestLoginOTP(uint64(1)).Return(activeOTP, nil).Times(1)
+		mockRepository.EXPECT().IncrementLoginOTPAttempts(uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().UseLoginOTP(uint64(7)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(repository.ProfileMetaData{ProfileID: 1}).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLoginOtpVerify)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.LoginResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, 1, resp.UserId)
+		}
+	})
+
+	t.Run("Wrong Code", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/verify", "", `{"phone_number": "+6289627117", "code": "654321"}`)
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestLoginOTP(uint64(1)).Return(activeOTP, nil).Times(1)
+		mockRepository.EXPECT().IncrementLoginOTPAttempts(uint64(7)).Return(2, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventLoginFailed,
+			IPAddress: "192.0.2.1",
+			Details:   map[string]string{"method": "otp"},
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostLoginOtpVerify(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Attempts Exceeded", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/verify", "", `{"phone_number": "+6289627117", "code": "123456"}`)
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestLoginOTP(uint64(1)).Return(activeOTP, nil).Times(1)
+		mockRepository.EXPECT().IncrementLoginOTPAttempts(uint64(7)).Return(loginOTPMaxAttempts+1, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventLoginFailed,
+			IPAddress: "192.0.2.1",
+			Details:   map[string]string{"method": "otp"},
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		// even the right code is refused once the attempts are exhausted
+		if assert.NoError(t, mockServer.PostLoginOtpVerify(context)) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		}
+	})
+
+	t.Run("Expired Code", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/verify", "", `{"phone_number": "+6289627117", "code": "123456"}`)
+
+		expiredOTP := activeOTP
+		expiredOTP.ExpiresAt = time.Now().Add(-time.Second)
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestLoginOTP(uint64(1)).Return(expiredOTP, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostLoginOtpVerify(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Used Code", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/verify", "", `{"phone_number": "+6289627117", "code": "123456"}`)
+
+		usedAt := time.Now()
+		usedOTP := activeOTP
+		usedOTP.UsedAt = &usedAt
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestLoginOTP(uint64(1)).Return(usedOTP, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostLoginOtpVerify(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Second Factor Enabled", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/otp/verify", "", `{"phone_number": "+6289627117", "code": "123456"}`)
+
+		confirmedAt := time.Now()
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestLoginOTP(uint64(1)).Return(activeOTP, nil).Times(1)
+		mockRepository.EXPECT().IncrementLoginOTPAttempts(uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().UseLoginOTP(uint64(7)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{ProfileID: 1, ConfirmedAt: &confirmedAt}, nil).Times(1)
+		mockRepository.EXPECT().CreateMFAChallenge(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostLoginOtpVerify(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+		}
+	})
+}
@@ -11,11 +11,18 @@ import (
 	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
 
 	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/password"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+// testPasswordPolicy keeps the tests hashing passwords fast, production uses password.DefaultPolicy
+var testPasswordPolicy = password.Policy{
+	Algorithm: password.AlgorithmArgon2id,
+	Argon2id:  password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+}
+
 func setupTestLogin(t *testing.T, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(requestBody))
@@ -37,6 +44,10 @@ func TestLogin(t *testing.T) {
 			"phone_number" : "+6289627117",
 			"password" : "1n19s9H88@"
 		}`
+		loginLocalFormat = `{
+			"phone_number" : "0896-2711-7",
+			"password" : "1n19s9H88@"
+		}`
 		loginAccountNotFound = `{
 			"phone_number" : "+6289627117",
 			"password" : "1n19s9H88@"
@@ -48,7 +59,12 @@ func TestLogin(t *testing.T) {
 	)
 
 	//The hashed password that is stored in the repository.
-	hashedPassword := hashAndSalt([]byte("1n19s9H88@"))
+	hasher, _ := password.NewHasher(testPasswordPolicy)
+	hashedPassword, _ := hasher.Hash("1n19s9H88@")
+
+	// A hash created before argon2id was introduced
+	legacyHasher, _ := password.NewHasher(password.Policy{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+	legacyHashedPassword, _ := legacyHasher.Hash("1n19s9H88@")
 
 	var profile repository.Profile = repository.Profile{
 		ID:          1,
@@ -62,23 +78,92 @@ func TestLogin(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		context, rec, mockRepository := setupTestLogin(t, loginSuccess)
 
-		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any()).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any(), gomock.Any()).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
 		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
 		if assert.NoError(t, mockServer.PostLogin(context)) {
 			assert.Equal(t, http.StatusOK, rec.Code)
 		}
 	})
 
+	t.Run("Outdated Hash Is Upgraded", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, loginSuccess)
+
+		legacyProfile := profile
+		legacyProfile.Password = legacyHashedPassword
+
+		var rehashedPassword string
+		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any(), gomock.Any()).Return(legacyProfile, nil).Times(1)
+		mockRepository.EXPECT().UpdatePasswordByID(uint64(1), gomock.Any()).DoAndReturn(func(id uint64, password string) error {
+			rehashedPassword = password
+			return nil
+		}).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostLogin(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.True(t, strings.HasPrefix(rehashedPassword, "$argon2id$"))
+
+			ok, err := mockServer.PasswordHasher.Verify("1n19s9H88@", rehashedPassword)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("Failed Rehash Doesn't Fail The Login", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, loginSuccess)
+
+		legacyProfile := profile
+		legacyProfile.Password = legacyHashedPassword
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any(), gomock.Any()).Return(legacyProfile, nil).Times(1)
+		mockRepository.EXPECT().UpdatePasswordByID(gomock.Any(), gomock.Any()).Return(sql.ErrConnDone).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostLogin(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Local Phone Number Format", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, loginLocalFormat)
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLogin)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
 	t.Run("Phone Number Exists", func(t *testing.T) {
 
 		context, rec, mockRepository := setupTestCreateProfile(t, loginAccountNotFound)
 
 		profile := repository.Profile{}
 
-		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any()).Return(profile, sql.ErrNoRows).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any(), gomock.Any()).Return(profile, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
 		if assert.NoError(t, mockServer.PostLogin(context)) {
 			assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -89,8 +174,101 @@ func TestLogin(t *testing.T) {
 
 		context, rec, mockRepository := setupTestCreateProfile(t, loginInvalidPassword)
 
-		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any()).Return(profile, sql.ErrNoRows).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		mockRepository.EXPECT().GetProfileByPhoneNumber(gomock.Any(), gomock.Any()).Return(profile, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostLogin(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Suspended Profile", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, loginSuccess)
+
+		suspendedProfile := profile
+		suspendedProfile.Status = repository.ProfileStatusSuspended
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(suspendedProfile, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventLoginFailed,
+			IPAddress: "192.0.2.1",
+			Details:   map[string]string{"method": "password", "reason": "suspended"},
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLogin)(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"profile_suspended"`)
+		}
+	})
+
+	t.Run("Email", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, `{"email": "Bakri@Example.com", "password": "1n19s9H88@"}`)
+
+		verifiedAt := time.Now()
+		emailProfile := profile
+		emailProfile.EmailVerifiedAt = &verifiedAt
+		mockRepository.EXPECT().GetProfileByEmail("bakri@example.com").Return(emailProfile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLogin)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Unverified Email", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, `{"email": "bakri@example.com", "password": "1n19s9H88@"}`)
+
+		mockRepository.EXPECT().GetProfileByEmail("bakri@example.com").Return(profile, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostLogin(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"account_not_found"`)
+		}
+	})
+
+	t.Run("Phone Number And Email", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, `{"phone_number": "+6289627117", "email": "bakri@example.com", "password": "1n19s9H88@"}`)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLogin)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"rule":"exclusive"`)
+		}
+	})
+
+	t.Run("No Identifier", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, `{"password": "1n19s9H88@"}`)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostLogin(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"field":"phone_number"`)
+		}
+	})
+
+	t.Run("Wrong Password Is Recorded", func(t *testing.T) {
+		context, rec, mockRepository := setupTestLogin(t, loginInvalidPassword)
+		context.Request().Header.Set("User-Agent", "okhttp/4.12.0")
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventLoginFailed,
+			IPAddress: "192.0.2.1",
+			UserAgent: "okhttp/4.12.0",
+			Details:   map[string]string{"method": "password"},
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
 
 		if assert.NoError(t, mockServer.PostLogin(context)) {
 			assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+)
+
+// messageCode identifies a user-facing message independently of its language
+type messageCode string
+
+const (
+	msgInternalServerError   messageCode = "internal_server_error"
+	msgInvalidRequestBody    messageCode = "invalid_request_body"
+	msgInvalidToken          messageCode = "invalid_token"
+	msgProfileNotFound       messageCode = "profile_not_found"
+	msgProfileCreated        messageCode = "profile_created"
+	msgPhoneNumberExist      messageCode = "phone_number_already_exist"
+	msgEmailExist            messageCode = "email_already_exist"
+	msgEmailNotSet           messageCode = "email_not_set"
+	msgEmailAlreadyVerified  messageCode = "email_already_verified"
+	msgEmailSentTooSoon      messageCode = "email_verification_too_soon"
+	msgInvalidEmailLink      messageCode = "invalid_email_link"
+	msgEmailVerified         messageCode = "email_verified"
+	msgEmailVerifySubject    messageCode = "email_verification_subject"
+	msgEmailVerifyBody       messageCode = "email_verification_body"
+	msgAccountNotFound       messageCode = "account_not_found"
+	msgPasswordMismatch      messageCode = "password_mismatch"
+	msgUpdateProfileFailed   messageCode = "update_profile_failed"
+	msgProfileModified       messageCode = "profile_modified"
+	msgMFAAlreadyEnabled     messageCode = "mfa_already_enabled"
+	msgMFANotEnrolled        messageCode = "mfa_not_enrolled"
+	msgInvalidMFACode        messageCode = "invalid_mfa_code"
+	msgMFAAttemptsExceeded   messageCode = "mfa_attempts_exceeded"
+	msgMFALocked             messageCode = "mfa_locked"
+	msgInvalidOTP            messageCode = "invalid_otp"
+	msgOTPAttemptsExceeded   messageCode = "otp_attempts_exceeded"
+	msgOTPRequestedTooSoon   messageCode = "otp_requested_too_soon"
+	msgOTPMessage            messageCode = "otp_sms"
+	msgSessionNotFound       messageCode = "session_not_found"
+	msgInvalidCursor         messageCode = "invalid_cursor"
+	msgPermissionDenied      messageCode = "permission_denied"
+	msgRouteNotFound         messageCode = "route_not_found"
+	msgMethodNotAllowed      messageCode = "method_not_allowed"
+	msgProfileSuspended      messageCode = "profile_suspended"
+	msgAlreadySuspended      messageCode = "profile_already_suspended"
+	msgNotSuspended          messageCode = "profile_not_suspended"
+	msgCannotSuspendSelf     messageCode = "cannot_suspend_self"
+	msgExportNotFound        messageCode = "data_export_not_found"
+	msgIdempotencyKeyReused  messageCode = "idempotency_key_reused"
+	msgIdempotencyInProgress messageCode = "idempotency_key_in_progress"
+	msgAttrSchemaNotFound    messageCode = "attribute_schema_not_found"
+	msgAvatarTooLarge        messageCode = "avatar_too_large"
+	msgAvatarUnsupported     messageCode = "avatar_unsupported_type"
+	msgValidationFailed      messageCode = "validation_failed"
+	msgResponseInvalid       messageCode = "response_validation_failed"
+	msgValidationRequired    messageCode = "validation_required"
+	msgValidationMinLength   messageCode = "validation_minLength"
+	msgValidationMaxLength   messageCode = "validation_maxLength"
+	msgValidationPattern     messageCode = "validation_pattern"
+	msgValidationMinProps    messageCode = "validation_minProperties"
+	msgValidationType        messageCode = "validation_type"
+	msgValidationUppercase   messageCode = "validation_passwordUppercase"
+	msgValidationLowercase   messageCode = "validation_passwordLowercase"
+	msgValidationNumber      messageCode = "validation_passwordNumber"
+	msgValidationSpecial     messageCode = "validation_passwordSpecial"
+	msgValidationBreached    messageCode = "validation_passwordBreached"
+	msgValidationPersonal    messageCode = "validation_passwordPersonalInfo"
+	msgValidationReused      messageCode = "validation_passwordReused"
+	msgValidationMismatch    messageCode = "validation_passwordMismatch"
+	msgValidationPhoneFormat messageCode = "validation_e164"
+	msgValidationPhoneCode   messageCode = "validation_phoneCountry"
+	msgValidationPhoneLength messageCode = "validation_phoneLength"
+	msgValidationBcrypt      messageCode = "validation_bcrypt"
+	msgValidationExclusive   messageCode = "validation_exclusive"
+	msgValidationDuplicate   messageCode = "validation_duplicate"
+	msgValidationRegistered  messageCode = "validation_alreadyRegistered"
+	msgValidationMalformed   messageCode = "validation_malformed"
+	msgValidationEnum        messageCode = "validation_enum"
+	msgValidationEmail       messageCode = "validation_email"
+	msgValidationDate        messageCode = "validation_date"
+	msgValidationPastDate    messageCode = "validation_pastDate"
+	msgValidationMinDate     messageCode = "validation_minDate"
+	msgValidationLocale      messageCode = "validation_locale"
+	msgValidationTimezone    messageCode = "validation_timezone"
+	msgValidationReadOnly    messageCode = "validation_readOnly"
+	msgValidationUnknownAttr messageCode = "validation_unknownAttribute"
+	msgValidationImage       messageCode = "validation_image"
+	msgValidationDefault     messageCode = "validation_default"
+)
+
+const (
+	languageEnglish    = "en"
+	languageIndonesian = "id"
+)
+
+// supportedLanguages lists the bundles of messageCatalogue, the first one is the fallback
+var supportedLanguages = []string{languageEnglish, languageIndonesian}
+
+var languageMatcher = language.NewMatcher([]language.Tag{
+	language.English,
+	language.Indonesian,
+})
+
+// messageCatalogue maps every message code to its text per language.
+// Validation messages are formatted with the field name and the rule parameter.
+var messageCatalogue = map[string]map[messageCode]string{
+	languageEnglish: {
+		msgInternalServerError:   "Internal Server Error",
+		msgInvalidRequestBody:    "Invalid request body",
+		msgInvalidToken:          "Invalid Token",
+		msgProfileNotFound:       "Profile not found",
+		msgProfileCreated:        "Profile is successfully created",
+		msgPhoneNumberExist:      "Phone Number Already Exist",
+		msgEmailExist:            "The email is already used by another account",
+		msgEmailNotSet:           "The profile has no email",
+		msgEmailAlreadyVerified:  "The email is already verified",
+		msgEmailSentTooSoon:      "A link has been sent less than a minute ago, retry later",
+		msgInvalidEmailLink:      "The link is invalid or has expired, request a new one",
+		msgEmailVerified:         "Your email is verified",
+		msgEmailVerifySubject:    "Verify your email address",
+		msgEmailVerifyBody:       "Open this link to verify your email address, it's valid for %[2]d hours:\n\n%[1]s\n\nIgnore this email if you didn't add this address to your profile.",
+		msgAccountNotFound:       "Account not found",
+		msgPasswordMismatch:      "Password doesn't match",
+		msgUpdateProfileFailed:   "Can't update profile",
+		msgProfileModified:       "The profile was changed on another device, reload it and try again",
+		msgMFAAlreadyEnabled:     "Two-factor authentication is already enabled",
+		msgMFANotEnrolled:        "Start the two-factor authentication enrollment first",
+		msgInvalidMFACode:        "The code is invalid or has expired",
+		msgMFAAttemptsExceeded:   "Too many wrong codes, log in again with your password",
+		msgMFALocked:             "Too many wrong codes, try again later",
+		msgInvalidOTP:            "The code is invalid, has expired or has already been used",
+		msgOTPAttemptsExceeded:   "Too many wrong codes, request a new code",
+		msgOTPRequestedTooSoon:   "Too many codes have been requested for this number, wait before requesting a new one",
+		msgOTPMessage:            "Your login code is %[1]s, valid for %[2]d minutes. Never share it with anyone.",
+		msgSessionNotFound:       "Session not found or already revoked",
+		msgInvalidCursor:         "The cursor is invalid, start again from the first page",
+		msgPermissionDenied:      "You don't have the permission to do this",
+		msgRouteNotFound:         "This route doesn't exist",
+		msgMethodNotAllowed:      "This method isn't allowed on this route",
+		msgProfileSuspended:      "This account is suspended, contact support",
+		msgAlreadySuspended:      "The profile is already suspended",
+		msgNotSuspended:          "The profile is not suspended",
+		msgCannotSuspendSelf:     "You can't suspend your own profile",
+		msgExportNotFound:        "The export has expired or doesn't exist, request a new one",
+		msgIdempotencyKeyReused:  "The Idempotency-Key was already used for another request",
+		msgIdempotencyInProgress: "A request with the same Idempotency-Key is in progress, retry later",
+		msgAttrSchemaNotFound:    "No attribute schema has been set",
+		msgAvatarTooLarge:        "The picture must be at most 5 MiB and 40 megapixels",
+		msgAvatarUnsupported:     "The picture must be a JPEG, PNG or WebP image",
+		msgValidationFailed:      "Validation failed",
+		msgResponseInvalid:       "Response doesn't match the API specification",
+		msgValidationRequired:    "%[1]s is required",
+		msgValidationMinLength:   "%[1]s must be at least %[2]s characters",
+		msgValidationMaxLength:   "%[1]s must be at most %[2]s characters",
+		msgValidationPattern:     "%[1]s has an invalid format",
+		msgValidationMinProps:    "At least %[2]s field should be filled",
+		msgValidationType:        "%[1]s has an invalid type",
+		msgValidationUppercase:   "%[1]s must contain an uppercase letter",
+		msgValidationLowercase:   "%[1]s must contain a lowercase letter",
+		msgValidationNumber:      "%[1]s must contain a number",
+		msgValidationSpecial:     "%[1]s must contain a special character",
+		msgValidationBreached:    "%[1]s has appeared in a data breach, choose another password",
+		msgValidationPersonal:    "%[1]s must not contain your name or phone number",
+		msgValidationReused:      "%[1]s must be different from your last %[2]s passwords",
+		msgValidationMismatch:    "%[1]s doesn't match",
+		msgValidationPhoneFormat: "%[1]s must be a phone number, e.g. +6281234567890 or 081234567890",
+		msgValidationPhoneCode:   "%[1]s country code is not supported, allowed: %[2]s",
+		msgValidationPhoneLength: "%[1]s must have %[2]s to %[3]s digits after the country code",
+		msgValidationBcrypt:      "%[1]s must be a bcrypt hash",
+		msgValidationExclusive:   "%[1]s can't be given together with %[2]s",
+		msgValidationDuplicate:   "%[1]s is already used on line %[2]s",
+		msgValidationRegistered:  "%[1]s is already registered",
+		msgValidationMalformed:   "%[1]s can't be read: %[2]s",
+		msgValidationEnum:        "%[1]s must be one of %[2]s",
+		msgValidationEmail:       "%[1]s must be an email address, e.g. name@example.com",
+		msgValidationDate:        "%[1]s must be a date, e.g. 1990-12-31",
+		msgValidationPastDate:    "%[1]s must be in the past",
+		msgValidationMinDate:     "%[1]s must not be before %[2]s",
+		msgValidationLocale:      "%[1]s must be a language tag, e.g. id-ID",
+		msgValidationTimezone:    "%[1]s must be a time zone, e.g. Asia/Jakarta",
+		msgValidationReadOnly:    "%[1]s can't be changed",
+		msgValidationUnknownAttr: "%[1]s is not an attribute of the schema",
+		msgValidationImage:       "%[1]s must be a picture which can be read",
+		msgValidationDefault:     "%[1]s is not valid",
+	},
+	languageIndonesian: {
+		msgInternalServerError:   "Terjadi kesalahan pada server",
+		msgInvalidRequestBody:    "Isi permintaan tidak valid",
+		msgInvalidToken:          "Token tidak valid",
+		msgProfileNotFound:       "Profil tidak ditemukan",
+		msgProfileCreated:        "Profil berhasil dibuat",
+		msgPhoneNumberExist:      "Nomor telepon sudah terdaftar",
+		msgEmailExist:            "Email sudah digunakan oleh akun lain",
+		msgEmailNotSet:           "Profil belum memiliki email",
+		msgEmailAlreadyVerified:  "Email sudah terverifikasi",
+		msgEmailSentTooSoon:      "Tautan sudah dikirim kurang dari satu menit yang lalu, coba lagi nanti",
+		msgInvalidEmailLink:      "Tautan tidak valid atau sudah kedaluwarsa, minta tautan baru",
+		msgEmailVerified:         "Email Anda berhasil diverifikasi",
+		msgEmailVerifySubject:    "Verifikasi alamat email Anda",
+		msgEmailVerifyBody:       "Buka tautan ini untuk memverifikasi alamat email Anda, berlaku %[2]d jam:\n\n%[1]s\n\nAbaikan email ini jika Anda tidak menambahkan alamat ini ke profil Anda.",
+		msgAccountNotFound:       "Akun tidak ditemukan",
+		msgPasswordMismatch:      "Kata sandi tidak cocok",
+		msgUpdateProfileFailed:   "Tidak dapat memperbarui profil",
+		msgProfileModified:       "Profil telah diubah di perangkat lain, muat ulang lalu coba lagi",
+		msgMFAAlreadyEnabled:     "Autentikasi dua faktor sudah aktif",
+		msgMFANotEnrolled:        "Mulai pendaftaran autentikasi dua faktor terlebih dahulu",
+		msgInvalidMFACode:        "Kode tidak valid atau sudah kedaluwarsa",
+		msgMFAAttemptsExceeded:   "Terlalu banyak kode yang salah, masuk kembali dengan kata sandi Anda",
+		msgMFALocked:             "Terlalu banyak kode yang salah, coba lagi nanti",
+		msgInvalidOTP:            "Kode tidak valid, sudah kedaluwarsa, atau sudah digunakan",
+		msgOTPAttemptsExceeded:   "Terlalu banyak kode yang salah, minta kode baru",
+		msgOTPRequestedTooSoon:   "Terlalu banyak kode yang diminta untuk nomor ini, tunggu sebelum meminta kode baru",
+		msgOTPMessage:            "Kode masuk Anda %[1]s, berlaku %[2]d menit. Jangan berikan kode ini kepada siapa pun.",
+		msgSessionNotFound:       "Sesi tidak ditemukan atau sudah dicabut",
+		msgInvalidCursor:         "Kursor tidak valid, mulai lagi dari halaman pertama",
+		msgPermissionDenied:      "Anda tidak memiliki izin untuk melakukan ini",
+		msgRouteNotFound:         "Rute ini tidak ada",
+		msgMethodNotAllowed:      "Metode ini tidak diizinkan pada rute ini",
+		msgProfileSuspended:      "Akun ini ditangguhkan, hubungi layanan pelanggan",
+		msgAlreadySuspended:      "Profil sudah ditangguhkan",
+		msgNotSuspended:          "Profil tidak sedang ditangguhkan",
+		msgCannotSuspendSelf:     "Anda tidak dapat menangguhkan profil Anda sendiri",
+		msgExportNotFound:        "Ekspor data sudah kedaluwarsa atau tidak ditemukan, minta ekspor baru",
+		msgIdempotencyKeyReused:  "Idempotency-Key sudah digunakan untuk permintaan lain",
+		msgIdempotencyInProgress: "Permintaan dengan Idempotency-Key yang sama sedang diproses, coba lagi nanti",
+		msgAttrSchemaNotFound:    "Skema atribut belum diatur",
+		msgAvatarTooLarge:        "Gambar maksimal berukuran 5 MiB dan 40 megapiksel",
+		msgAvatarUnsupported:     "Gambar harus berformat JPEG, PNG, atau WebP",
+		msgValidationFailed:      "Validasi gagal",
+		msgResponseInvalid:       "Respons tidak sesuai dengan spesifikasi API",
+		msgValidationRequired:    "%[1]s wajib diisi",
+		msgValidationMinLength:   "%[1]s minimal %[2]s karakter",
+		msgValidationMaxLength:   "%[1]s maksimal %[2]s karakter",
+		msgValidationPattern:     "Format %[1]s tidak valid",
+		msgValidationMinProps:    "Minimal %[2]s field harus diisi",
+		msgValidationType:        "Tipe %[1]s tidak valid",
+		msgValidationUppercase:   "%[1]s harus mengandung huruf kapital",
+		msgValidationLowercase:   "%[1]s harus mengandung huruf kecil",
+		msgValidationNumber:      "%[1]s harus mengandung angka",
+		msgValidationSpecial:     "%[1]s harus mengandung karakter khusus",
+		msgValidationBreached:    "%[1]s pernah muncul dalam kebocoran data, gunakan kata sandi lain",
+		msgValidationPersonal:    "%[1]s tidak boleh mengandung nama atau nomor telepon Anda",
+		msgValidationReused:      "%[1]s harus berbeda dari %[2]s kata sandi terakhir Anda",
+		msgValidationMismatch:    "%[1]s tidak cocok",
+		msgValidationPhoneFormat: "%[1]s harus berupa nomor telepon, contoh +6281234567890 atau 081234567890",
+		msgValidationPhoneCode:   "Kode negara %[1]s tidak didukung, yang diizinkan: %[2]s",
+		msgValidationPhoneLength: "%[1]s harus terdiri dari %[2]s sampai %[3]s digit setelah kode negara",
+		msgValidationBcrypt:      "%[1]s harus berupa hash bcrypt",
+		msgValidationExclusive:   "%[1]s tidak boleh diisi bersama %[2]s",
+		msgValidationDuplicate:   "%[1]s sudah digunakan pada baris %[2]s",
+		msgValidationRegistered:  "%[1]s sudah terdaftar",
+		msgValidationMalformed:   "%[1]s tidak dapat dibaca: %[2]s",
+		msgValidationEnum:        "%[1]s harus salah satu dari %[2]s",
+		msgValidationEmail:       "%[1]s harus berupa alamat email, contoh nama@example.com",
+		msgValidationDate:        "%[1]s harus berupa tanggal, contoh 1990-12-31",
+		msgValidationPastDate:    "%[1]s harus tanggal yang sudah lewat",
+		msgValidationMinDate:     "%[1]s tidak boleh sebelum %[2]s",
+		msgValidationLocale:      "%[1]s harus berupa kode bahasa, contoh id-ID",
+		msgValidationTimezone:    "%[1]s harus berupa zona waktu, contoh Asia/Jakarta",
+		msgValidationReadOnly:    "%[1]s tidak dapat diubah",
+		msgValidationUnknownAttr: "%[1]s bukan atribut dari skema",
+		msgValidationImage:       "%[1]s harus berupa gambar yang dapat dibaca",
+		msgValidationDefault:     "%[1]s tidak valid",
+	},
+}
+
+// requestLanguage picks the catalogue bundle that best matches the Accept-Language header
+func requestLanguage(ctx echo.Context) string {
+	tags, _, err := language.ParseAcceptLanguage(ctx.Request().Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return supportedLanguages[0]
+	}
+
+	_, index, confidence := languageMatcher.Match(tags...)
+	if confidence == language.No {
+		return supportedLanguages[0]
+	}
+	return supportedLanguages[index]
+}
+
+// localize returns the message for code in the request language, falling back to the default bundle
+func localize(ctx echo.Context, code messageCode, args ...interface{}) string {
+	return localizeIn(requestLanguage(ctx), code, args...)
+}
+
+// localizeIn returns the message for code in lang, falling back to the default bundle
+func localizeIn(lang string, code messageCode, args ...interface{}) string {
+	text, ok := messageCatalogue[lang][code]
+	if !ok {
+		text, ok = messageCatalogue[supportedLanguages[0]][code]
+	}
+	if !ok {
+		return string(code)
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// errorResponse builds the general error body for code in the request language
+func errorResponse(ctx echo.Context, code messageCode) generated.GeneralErrorResponse {
+	return generated.GeneralErrorResponse{
+		Code:    string(code),
+		Message: localize(ctx, code),
+	}
+}
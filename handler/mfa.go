@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/hasbiasshidiq/simple-profile/totp"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// mfaChallengeMaxAttempts is the number of wrong codes after which the login has to start again with the password
+	mfaChallengeMaxAttempts = 5
+	// mfaMaxFailures is the number of wrong codes in a row, across the logins, which lock the second factor
+	mfaMaxFailures = 10
+	// mfaLockoutDuration is how long the second factor is locked
+	mfaLockoutDuration = 15 * time.Minute
+)
+
+func (s *Server) PostProfileMfaTotp(ctx echo.Context) error {
+	// Extract the token from the Authorization header
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	profile, err := s.Repository.GetProfileByID(userID)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	mfa, err := s.Repository.GetProfileMFA(profile.ID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("error fetch mfa : ", err)
+		return err
+	}
+	if err == nil && mfa.ConfirmedAt != nil {
+		responsePayload := errorResponse(ctx, msgMFAAlreadyEnabled)
+		return ctx.JSON(http.StatusConflict, responsePayload)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Println("error generate totp secret : ", err)
+		return err
+	}
+
+	encryptedSecret, err := s.MFASecrets.Encrypt(secret, mfaSecretOwner(profile.ID))
+	if err != nil {
+		log.Println("error encrypt totp secret : ", err)
+		return err
+	}
+
+	stored, err := s.Repository.UpsertProfileMFASecret(profile.ID, encryptedSecret)
+	if err != nil {
+		log.Println("error store totp secret : ", err)
+		return err
+	}
+	if !stored {
+		// the authenticator has been confirmed by another request in the meantime
+		responsePayload := errorResponse(ctx, msgMFAAlreadyEnabled)
+		return ctx.JSON(http.StatusConflict, responsePayload)
+	}
+
+	resp := generated.TOTPEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningUri: totp.ProvisioningURI(s.TOTPIssuer, profile.CountryCode+profile.PhoneNumber, secret),
+	}
+
+	return ctx.JSON(http.StatusCreated, resp)
+}
+
+func (s *Server) PostProfileMfaTotpConfirm(ctx echo.Context) error {
+	// Extract the token from the Authorization header
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var request generated.ConfirmTOTPRequest
+
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	mfa, err := s.Repository.GetProfileMFA(uint64(userID))
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgMFANotEnrolled)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch mfa : ", err)
+		return err
+	}
+	if mfa.ConfirmedAt != nil {
+		responsePayload := errorResponse(ctx, msgMFAAlreadyEnabled)
+		return ctx.JSON(http.StatusConflict, responsePayload)
+	}
+
+	secret, err := s.MFASecrets.Decrypt(mfa.Secret, mfaSecretOwner(mfa.ProfileID))
+	if err != nil {
+		log.Println("error decrypt totp secret : ", err)
+		return err
+	}
+
+	step, ok, err := totp.Validate(secret, request.Code, time.Now())
+	if err != nil {
+		log.Println("error validate totp code : ", err)
+		return err
+	}
+	if !ok {
+		responsePayload := errorResponse(ctx, msgInvalidMFACode)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(totp.RecoveryCodeCount)
+	if err != nil {
+		log.Println("error generate recovery codes : ", err)
+		return err
+	}
+
+	recoveryCodeHashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		recoveryCodeHashes = append(recoveryCodeHashes, totp.HashRecoveryCode(code))
+	}
+
+	err = s.Repository.ConfirmProfileMFA(mfa.ProfileID, step, recoveryCodeHashes)
+	if err != nil {
+		log.Println("error confirm mfa : ", err)
+		return err
+	}
+
+	resp := generated.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) PostLoginMfa(ctx echo.Context) error {
+
+	var request generated.VerifyMFARequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	userID, challengeID, err := extractChallengeFromToken(request.ChallengeToken)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	mfa, err := s.Repository.GetProfileMFA(uint64(userID))
+	if err != nil || mfa.ConfirmedAt == nil {
+		log.Println("error fetch mfa : ", err)
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+	if mfa.LockedUntil != nil && time.Now().Before(*mfa.LockedUntil) {
+		return s.refuseLockedMFA(ctx, *mfa.LockedUntil)
+	}
+
+	// the attempt is counted before the comparison, so parallel guesses can't exceed the limit
+	attempts, err := s.Repository.IncrementMFAChallengeAttempts(mfa.ProfileID, challengeID)
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+	if err != nil {
+		log.Println("error count mfa attempt : ", err)
+		return err
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		responsePayload := errorResponse(ctx, msgMFAAttemptsExceeded)
+		return ctx.JSON(http.StatusTooManyRequests, responsePayload)
+	}
+
+	var verified bool
+	method := loginMethodTOTP
+	switch {
+	case request.Code != nil:
+		verified, err = s.verifyTOTPCode(mfa, *request.Code)
+	case request.RecoveryCode != nil:
+		method = loginMethodRecoveryCode
+		verified, err = s.Repository.UseRecoveryCode(mfa.ProfileID, totp.HashRecoveryCode(*request.RecoveryCode))
+	}
+	if err != nil {
+		log.Println("error verify second factor : ", err)
+		return err
+	}
+	if !verified {
+		s.recordSecurityEvent(ctx, mfa.ProfileID, repository.SecurityEventLoginFailed, map[string]string{"method": method})
+
+		lockedUntil, err := s.Repository.RecordMFAFailure(mfa.ProfileID, mfaMaxFailures, time.Now().Add(mfaLockoutDuration))
+		if err != nil {
+			log.Println("error record mfa failure : ", err)
+			return err
+		}
+		if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+			return s.refuseLockedMFA(ctx, *lockedUntil)
+		}
+
+		responsePayload := errorResponse(ctx, msgInvalidMFACode)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	used, err := s.Repository.UseMFAChallenge(mfa.ProfileID, challengeID)
+	if err != nil {
+		log.Println("error use mfa challenge : ", err)
+		return err
+	}
+	if !used {
+		// the challenge has completed another login in the meantime
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	profile, err := s.Repository.GetProfileByID(userID)
+	if err != nil {
+		log.Println("error fetch profile : ", err)
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+	if profile.Status == repository.ProfileStatusSuspended {
+		return s.refuseSuspendedLogin(ctx, profile, method)
+	}
+
+	return s.completeLogin(ctx, profile, method)
+}
+
+// refuseLockedMFA answers the second factors entered while too many wrong codes lock it
+func (s *Server) refuseLockedMFA(ctx echo.Context, lockedUntil time.Time) error {
+	retryAfter := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	ctx.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	responsePayload := errorResponse(ctx, msgMFALocked)
+	return ctx.JSON(http.StatusTooManyRequests, responsePayload)
+}
+
+// verifyTOTPCode checks code against the authenticator and consumes its time step, so a code can't be replayed
+func (s *Server) verifyTOTPCode(mfa repository.ProfileMFA, code string) (bool, error) {
+	secret, err := s.MFASecrets.Decrypt(mfa.Secret, mfaSecretOwner(mfa.ProfileID))
+	if err != nil {
+		return false, err
+	}
+
+	step, ok, err := totp.Validate(secret, code, time.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+	if step <= mfa.LastUsedStep {
+		return false, nil
+	}
+	return s.Repository.UseMFAStep(mfa.ProfileID, step)
+}
+
+// mfaSecretOwner binds the encrypted secret of an authenticator to its profile
+func mfaSecretOwner(profileID uint64) string {
+	return strconv.FormatUint(profileID, 10)
+}
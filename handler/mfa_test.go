@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/hasbiasshidiq/simple-profile/totp"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestMFA(t *testing.T, path string, token string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117"}
+	token, _ := createToken(profile, 1, nil, nil)
+	confirmedAt := time.Now()
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp", token, "")
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		var storedSecret string
+		mockRepository.EXPECT().UpsertProfileMFASecret(uint64(1), gomock.Any()).DoAndReturn(func(profileID uint64, secret string) (bool, error) {
+			storedSecret = secret
+			return true, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfileMfaTotp)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+
+			var resp generated.TOTPEnrollmentResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Len(t, resp.Secret, 32)
+			assert.True(t, strings.HasPrefix(resp.ProvisioningUri, "otpauth://totp/Simple%20Profile:+6289627117?"))
+
+			// the secret is encrypted at rest
+			assert.NotContains(t, storedSecret, resp.Secret)
+			decrypted, err := mockServer.MFASecrets.Decrypt(storedSecret, "1")
+			assert.NoError(t, err)
+			assert.Equal(t, resp.Secret, decrypted)
+		}
+	})
+
+	t.Run("Confirmed In The Meantime", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp", token, "")
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockRepository.EXPECT().UpsertProfileMFASecret(uint64(1), gomock.Any()).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfileMfaTotp)(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("Already Enabled", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp", token, "")
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{ProfileID: 1, ConfirmedAt: &confirmedAt}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostProfileMfaTotp(context)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("Challenge Token Is Not An Access Token", func(t *testing.T) {
+		challengeToken, _ := createMFAChallengeToken(profile, 1)
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp", challengeToken, "")
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostProfileMfaTotp(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	token, _ := createToken(profile, 1, nil, nil)
+	secret, _ := totp.GenerateSecret()
+
+	t.Run("Success", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now())
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp/confirm", token, `{"code": "`+code+`"}`)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+		encryptedSecret, _ := mockServer.MFASecrets.Encrypt(secret, "1")
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		var storedHashes []string
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{ProfileID: 1, Secret: encryptedSecret}, nil).Times(1)
+		mockRepository.EXPECT().ConfirmProfileMFA(uint64(1), totp.Step(time.Now()), gomock.Any()).DoAndReturn(func(profileID uint64, step int64, hashes []string) error {
+			storedHashes = hashes
+			return nil
+		}).Times(1)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfileMfaTotpConfirm)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ConfirmTOTPResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.RecoveryCodes, totp.RecoveryCodeCount) && assert.Len(t, storedHashes, totp.RecoveryCodeCount) {
+				// only the hashes are stored
+				assert.Equal(t, totp.HashRecoveryCode(resp.RecoveryCodes[0]), storedHashes[0])
+				assert.NotContains(t, storedHashes, resp.RecoveryCodes[0])
+			}
+		}
+	})
+
+	t.Run("Wrong Code", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now().Add(-time.Hour))
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp/confirm", token, `{"code": "`+code+`"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{ProfileID: 1, Secret: secret}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostProfileMfaTotpConfirm(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Not Enrolled", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/profile/mfa/totp/confirm", token, `{"code": "123456"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(repository.ProfileMFA{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.PostProfileMfaTotpConfirm(context)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+}
+
+func TestLoginWithMFA(t *testing.T) {
+	hasher, _ := password.NewHasher(testPasswordPolicy)
+	hashedPassword, _ := hasher.Hash("1n19s9H88@")
+	secret, _ := totp.GenerateSecret()
+	confirmedAt := time.Now()
+	mfaSecretKey := make([]byte, totp.SecretKeyLength)
+	secrets, _ := totp.NewSecretCipher(mfaSecretKey)
+	encryptedSecret, _ := secrets.Encrypt(secret, "1")
+
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "89627117", Password: hashedPassword}
+	mfa := repository.ProfileMFA{ProfileID: 1, Secret: encryptedSecret, ConfirmedAt: &confirmedAt}
+	challengeToken, _ := createMFAChallengeToken(profile, 7)
+
+	newServer := func(mockRepository *repository.MockRepositoryInterface) *Server {
+		return NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, MFASecretKey: mfaSecretKey, ValidateResponses: true})
+	}
+
+	t.Run("Password Returns A Challenge", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login", "", `{"phone_number": "+6289627117", "password": "1n19s9H88@"}`)
+
+		mockRepository.EXPECT().GetProfileByPhoneNumber("+62", "89627117").Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().CreateMFAChallenge(gomock.Any()).DoAndReturn(func(challenge repository.MFAChallenge) (uint64, error) {
+			assert.Equal(t, uint64(1), challenge.ProfileID)
+			assert.WithinDuration(t, time.Now().Add(mfaChallengeLifetime), challenge.ExpiresAt, time.Second)
+			return 7, nil
+		}).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLogin)(context)) {
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+
+			var resp generated.MFAChallengeResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, 300, resp.ExpiresIn)
+
+			// the challenge can't be used as an access token
+			_, _, err := parseAccessToken(resp.ChallengeToken)
+			assert.Error(t, err)
+			userID, challengeID, err := extractChallengeFromToken(resp.ChallengeToken)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, userID)
+			assert.Equal(t, uint64(7), challengeID)
+		}
+	})
+
+	t.Run("Authenticator Code", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now())
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "code": "`+code+`"}`)
+
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().UseMFAStep(uint64(1), totp.Step(time.Now())).Return(true, nil).Times(1)
+		mockRepository.EXPECT().UseMFAChallenge(uint64(1), uint64(7)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLoginMfa)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.LoginResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			userID, _, err := parseAccessToken(resp.JwtToken)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, userID)
+		}
+	})
+
+	t.Run("Replayed Code", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now())
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "code": "`+code+`"}`)
+
+		usedMFA := mfa
+		usedMFA.LastUsedStep = totp.Step(time.Now()) + totp.Skew
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(usedMFA, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventLoginFailed,
+			IPAddress: "192.0.2.1",
+			Details:   map[string]string{"method": "totp"},
+		}).Return(nil).Times(1)
+		mockRepository.EXPECT().RecordMFAFailure(uint64(1), mfaMaxFailures, gomock.Any()).Return(nil, nil).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.PostLoginMfa(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Recovery Code", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "recovery_code": "ABCDE-FGH23"}`)
+
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().UseRecoveryCode(uint64(1), totp.HashRecoveryCode("abcde-fgh23")).Return(true, nil).Times(1)
+		mockRepository.EXPECT().UseMFAChallenge(uint64(1), uint64(7)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().CreateSession(gomock.Any()).Return(uint64(1), nil).Times(1)
+		mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+		mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.PostLoginMfa(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Used Recovery Code", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "recovery_code": "abcde-fgh23"}`)
+
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().UseRecoveryCode(uint64(1), gomock.Any()).Return(false, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventLoginFailed,
+			IPAddress: "192.0.2.1",
+			Details:   map[string]string{"method": "recovery_code"},
+		}).Return(nil).Times(1)
+		mockRepository.EXPECT().RecordMFAFailure(uint64(1), mfaMaxFailures, gomock.Any()).Return(nil, nil).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.PostLoginMfa(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Too Many Attempts On The Challenge", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now())
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "code": "`+code+`"}`)
+
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(mfaChallengeMaxAttempts+1, nil).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLoginMfa)(context)) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"mfa_attempts_exceeded"`)
+		}
+	})
+
+	t.Run("Wrong Code Locks The Second Factor", func(t *testing.T) {
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "code": "000000"}`)
+
+		lockedUntil := time.Now().Add(mfaLockoutDuration)
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(1, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockRepository.EXPECT().RecordMFAFailure(uint64(1), mfaMaxFailures, gomock.Any()).DoAndReturn(func(profileID uint64, maxFailures int, lockUntil time.Time) (*time.Time, error) {
+			assert.WithinDuration(t, lockedUntil, lockUntil, time.Second)
+			return &lockUntil, nil
+		}).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostLoginMfa)(context)) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"mfa_locked"`)
+			assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("Locked Second Factor", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now())
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "code": "`+code+`"}`)
+
+		// even the right code is refused until the lock expires
+		lockedUntil := time.Now().Add(time.Minute)
+		lockedMFA := mfa
+		lockedMFA.LockedUntil = &lockedUntil
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(lockedMFA, nil).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.PostLoginMfa(context)) {
+			assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+			assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("Used Or Expired Challenge", func(t *testing.T) {
+		code, _ := totp.Code(secret, time.Now())
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+challengeToken+`", "code": "`+code+`"}`)
+
+		mockRepository.EXPECT().GetProfileMFA(uint64(1)).Return(mfa, nil).Times(1)
+		mockRepository.EXPECT().IncrementMFAChallengeAttempts(uint64(1), uint64(7)).Return(0, sql.ErrNoRows).Times(1)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.PostLoginMfa(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Access Token Is Not A Challenge", func(t *testing.T) {
+		accessToken, _ := createToken(profile, 1, nil, nil)
+		context, rec, mockRepository := setupTestMFA(t, "/login/mfa", "", `{"challenge_token": "`+accessToken+`", "code": "123456"}`)
+		mockServer := newServer(mockRepository)
+
+		if assert.NoError(t, mockServer.PostLoginMfa(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+func init() {
+	// the merge patches are JSON documents, kin-openapi only decodes the bodies of the media types it knows
+	openapi3filter.RegisterBodyDecoder(mergePatchContentType, decodeJSONBody)
+}
+
+// decodeJSONBody is the openapi3filter.BodyDecoder of the JSON media types
+func decodeJSONBody(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &openapi3filter.ParseError{Kind: openapi3filter.KindInvalidFormat, Cause: err}
+	}
+	return value, nil
+}
+
+// RequestValidator validates every request against the embedded OpenAPI spec before it reaches the handlers, the
+// requests of the routes missing from the spec are refused. The permissions required by the security of the route
+// are checked first, see authorize.
+// The operations marked with x-idempotent replay their responses to the retries with the same Idempotency-Key,
+// see withIdempotencyKey. When the server is created with ValidateResponses, the responses are checked against the
+// spec as well.
+func (s *Server) RequestValidator() echo.MiddlewareFunc {
+	return s.RequestValidatorWithBaseURL("")
+}
+
+// RequestValidatorWithBaseURL is RequestValidator for the routes registered under baseURL, e.g. /v1
+func (s *Server) RequestValidatorWithBaseURL(baseURL string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+
+			// the spec describes the paths without the version prefix
+			lookupURL := *req.URL
+			lookupURL.Path = strings.TrimPrefix(lookupURL.Path, baseURL)
+			lookupRequest := *req
+			lookupRequest.URL = &lookupURL
+
+			route, pathParams, err := s.Validator.router.FindRoute(&lookupRequest)
+			if err != nil {
+				// every route of the API is described by the spec, the others are refused rather than served
+				// without their validation and permissions
+				// the router returns copies of routers.ErrMethodNotAllowed
+				var routeError *routers.RouteError
+				if errors.As(err, &routeError) && routeError.Reason == routers.ErrMethodNotAllowed.Error() {
+					return ctx.JSON(http.StatusMethodNotAllowed, errorResponse(ctx, msgMethodNotAllowed))
+				}
+				return ctx.JSON(http.StatusNotFound, errorResponse(ctx, msgRouteNotFound))
+			}
+
+			if denied, ok := authorize(ctx, route); !ok {
+				return ctx.JSON(http.StatusForbidden, errorResponse(ctx, denied))
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					MultiError: true,
+					// authentication is done by the handlers and authorization by authorize
+					AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+					ExcludeRequestBody: streamsRequestBody(route),
+				},
+			}
+
+			err = openapi3filter.ValidateRequest(req.Context(), input)
+			if err != nil {
+				validationError, ok := toValidationError(err)
+				if !ok {
+					return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+				}
+				return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, validationError))
+			}
+
+			handler := next
+			if key := req.Header.Get(idempotencyKeyHeader); key != "" && acceptsIdempotencyKey(route) {
+				operation := req.Method + " " + lookupURL.Path
+				handler = func(ctx echo.Context) error {
+					return s.withIdempotencyKey(ctx, key, operation, next)
+				}
+			}
+
+			if !s.ValidateResponses {
+				return handler(ctx)
+			}
+			return validateResponse(ctx, handler, input)
+		}
+	}
+}
+
+// streamsRequestBody reports whether the operation reads its body as a stream, it's marked with x-streamed-body in
+// api.yml. Such a body isn't validated, the validation would read it in memory.
+func streamsRequestBody(route *routers.Route) bool {
+	streamed, _ := route.Operation.Extensions["x-streamed-body"].(bool)
+	return streamed
+}
+
+// bufferedResponseWriter holds the response back until it has been validated
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func validateResponse(ctx echo.Context, next echo.HandlerFunc, input *openapi3filter.RequestValidationInput) error {
+	writer := ctx.Response().Writer
+	buffer := &bufferedResponseWriter{header: writer.Header(), status: http.StatusOK}
+
+	ctx.Response().Writer = buffer
+	err := next(ctx)
+	ctx.Response().Writer = writer
+
+	// nothing has been written, the error handler will write the response
+	if !ctx.Response().Committed {
+		return err
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 buffer.status,
+		Header:                 buffer.header,
+		Options: &openapi3filter.Options{
+			MultiError:            true,
+			IncludeResponseStatus: true,
+		},
+	}
+	responseInput.SetBodyBytes(buffer.body.Bytes())
+
+	validationErr := openapi3filter.ValidateResponse(ctx.Request().Context(), responseInput)
+	if validationErr != nil {
+		log.Println("response doesn't match the spec : ", validationErr)
+
+		resp := errorResponse(ctx, msgResponseInvalid)
+		resp.Message = validationErr.Error()
+
+		writer.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		writer.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(writer).Encode(resp)
+		return err
+	}
+
+	writer.WriteHeader(buffer.status)
+	writer.Write(buffer.body.Bytes())
+	return err
+}
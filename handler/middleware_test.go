@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestRequestValidator(t *testing.T, method string, path string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(method, path, strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestRequestValidator(t *testing.T) {
+	var (
+		createProfileSuccess = `{
+			"phone_number" : "+6289627117",
+			"full_name" : "Hasbi Asshidiq",
+			"password" : "1n19s9H88@"
+		}`
+		createProfileInvalidFields = `{
+			"phone_number" : "+62896",
+			"full_name" : "Ha"
+		}`
+	)
+
+	t.Run("Every Failed Field Is Reported", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodPost, "/profile", createProfileInvalidFields)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+			rules := map[string]string{}
+			for _, fieldError := range resp.Errors {
+				rules[fieldError.Field+" "+fieldError.Rule] = fieldError.Message
+			}
+			assert.Contains(t, rules, "password required")
+			assert.Contains(t, rules, "full_name minLength")
+			assert.Contains(t, rules, "phone_number minLength")
+		}
+	})
+
+	t.Run("Empty Update", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodPut, "/profile", `{}`)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PutProfile(ctx, generated.PutProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Versioned Route", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodPut, "/v1/profile", `{}`)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PutProfile(ctx, generated.PutProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Response Matches Spec", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodPost, "/profile", createProfileSuccess)
+
+		mockRepository.EXPECT().GetPhoneNumberExistence(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+		mockRepository.EXPECT().CreateProfile(gomock.Any()).Return(1, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PostProfile)(context)) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+		}
+	})
+
+	t.Run("Response Doesn't Match Spec", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodPost, "/profile", createProfileSuccess)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy, ValidateResponses: true})
+
+		undocumented := func(ctx echo.Context) error {
+			return ctx.JSON(http.StatusTeapot, map[string]string{"unexpected": "field"})
+		}
+
+		if assert.NoError(t, mockServer.RequestValidator()(undocumented)(context)) {
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		}
+	})
+
+	t.Run("Route Not In The Spec", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodGet, "/v1/admin/internal", "")
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		// the handler isn't reached without the validation and the permissions of the spec
+		reached := false
+		handler := func(ctx echo.Context) error {
+			reached = true
+			return ctx.NoContent(http.StatusOK)
+		}
+		if assert.NoError(t, mockServer.RequestValidatorWithBaseURL("/v1")(handler)(context)) {
+			assert.False(t, reached)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"route_not_found"`)
+		}
+	})
+
+	t.Run("Method Not In The Spec", func(t *testing.T) {
+		context, rec, mockRepository := setupTestRequestValidator(t, http.MethodDelete, "/admin/profiles", "")
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, PasswordPolicy: testPasswordPolicy})
+
+		handler := func(ctx echo.Context) error {
+			return ctx.NoContent(http.StatusOK)
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		}
+	})
+}
+
+func TestValidationErrorResponse(t *testing.T) {
+	context, _, _ := setupTestRequestValidator(t, http.MethodPost, "/profile", ``)
+
+	t.Run("Field Errors", func(t *testing.T) {
+		resp := validationErrorResponse(context, &ValidationError{Fields: []FieldValidationError{{Field: "full_name", Rule: "required", Params: []string{}}}})
+		assert.Equal(t, "validation_failed", resp.Code)
+		if assert.Len(t, resp.Errors, 1) {
+			assert.Equal(t, "full_name", resp.Errors[0].Field)
+		}
+	})
+
+	t.Run("Other Errors Are Not Shown", func(t *testing.T) {
+		resp := validationErrorResponse(context, errors.New("pq: relation \"profiles\" does not exist"))
+		assert.Equal(t, "validation_failed", resp.Code)
+		assert.NotContains(t, resp.Message, "pq:")
+		assert.Empty(t, resp.Errors)
+	})
+}
@@ -0,0 +1,387 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) GetAdminProfileAttributeSchema(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	_, err = s.extractOperatorIDFromToken(token, rbac.PermissionProfilesRead)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	stored, err := s.Repository.GetLatestProfileAttributeSchema()
+	if errors.Is(err, sql.ErrNoRows) {
+		responsePayload := errorResponse(ctx, msgAttrSchemaNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch profile attribute schema : ", err)
+		return err
+	}
+
+	attributes, err := parseProfileAttributeSchema(stored.Schema, stored.Visibility)
+	if err != nil {
+		log.Println("error parse profile attribute schema : ", err)
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, profileAttributeSchemaResponse(stored, attributes))
+}
+
+func (s *Server) PutAdminProfileAttributeSchema(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	operatorID, err := s.extractOperatorIDFromToken(token, rbac.PermissionProfilesWrite)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var request generated.ProfileAttributeSchemaRequest
+
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	document, err := json.Marshal(request.Schema)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	visibility := map[string]string{}
+	if request.Visibility != nil {
+		for name, value := range *request.Visibility {
+			visibility[name] = string(value)
+		}
+	}
+
+	attributes, err := parseProfileAttributeSchema(document, visibility)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, err))
+	}
+
+	createdBy := uint64(operatorID)
+	stored := repository.ProfileAttributeSchema{Schema: document, Visibility: visibility, CreatedBy: &createdBy, CreatedAt: time.Now()}
+	stored.ID, err = s.Repository.CreateProfileAttributeSchema(stored)
+	if err != nil {
+		log.Println("error create profile attribute schema : ", err)
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, profileAttributeSchemaResponse(stored, attributes))
+}
+
+func (s *Server) PutAdminProfilesIdAttributes(ctx echo.Context, id int) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	_, err = s.extractOperatorIDFromToken(token, rbac.PermissionProfilesWrite)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var request generated.AdminProfileAttributes
+
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	profile, err := s.Repository.GetProfileByID(id)
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch profile : ", err)
+		return err
+	}
+
+	attributes, err := s.loadProfileAttributes()
+	if err != nil {
+		log.Println("error load profile attribute schema : ", err)
+		return err
+	}
+
+	written := map[string]interface{}(request.Attributes)
+	if written == nil {
+		written = map[string]interface{}{}
+	}
+	if err := validateProfileAttributes(attributes.schema, written); err != nil {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, err))
+	}
+
+	_, err = s.Repository.UpdateProfileByID(repository.Profile{ID: profile.ID, Attributes: written}, []string{repository.ProfileFieldAttributes}, 0)
+	if err != nil {
+		log.Println("error update profile attributes : ", err)
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, generated.AdminProfileAttributes{Attributes: written})
+}
+
+// profileAttributes is the schema of the custom attributes of the profiles with the visibility of each attribute.
+// Without a schema the profiles have no attributes.
+type profileAttributes struct {
+	schema     *openapi3.Schema
+	visibility map[string]string
+}
+
+// parseProfileAttributeSchema checks the schema set by an operator, an OpenAPI schema of an object, and the
+// visibility of its properties
+func parseProfileAttributeSchema(document []byte, visibility map[string]string) (*profileAttributes, error) {
+	malformed := func(reason string) error {
+		return &ValidationError{Fields: []FieldValidationError{{Field: "schema", Rule: "malformed", Params: []string{reason}}}}
+	}
+
+	schema := &openapi3.Schema{}
+	if err := json.Unmarshal(document, schema); err != nil {
+		return nil, malformed(err.Error())
+	}
+	if err := schema.Validate(context.Background()); err != nil {
+		return nil, malformed(err.Error())
+	}
+	if schema.Type != openapi3.TypeObject {
+		return nil, malformed("the attributes must be an object")
+	}
+
+	var validationError ValidationError
+	for name := range visibility {
+		if _, ok := schema.Properties[name]; !ok {
+			validationError.Fields = append(validationError.Fields, FieldValidationError{Field: "visibility." + name, Rule: "unknownAttribute", Params: []string{}})
+		}
+	}
+	if len(validationError.Fields) > 0 {
+		return nil, &validationError
+	}
+
+	return &profileAttributes{schema: schema, visibility: visibility}, nil
+}
+
+// loadProfileAttributes returns the latest schema of the custom attributes, an empty one when none was set
+func (s *Server) loadProfileAttributes() (*profileAttributes, error) {
+	stored, err := s.Repository.GetLatestProfileAttributeSchema()
+	if errors.Is(err, sql.ErrNoRows) {
+		return &profileAttributes{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseProfileAttributeSchema(stored.Schema, stored.Visibility)
+}
+
+// readableProfileAttributes returns the custom attributes of a profile its user sees, the schema is only read for
+// the profiles with attributes
+func (s *Server) readableProfileAttributes(attributes map[string]interface{}) (map[string]interface{}, error) {
+	if len(attributes) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	schema, err := s.loadProfileAttributes()
+	if err != nil {
+		return nil, err
+	}
+	return schema.readable(attributes), nil
+}
+
+// has tells whether the attribute is a property of the schema
+func (a *profileAttributes) has(name string) bool {
+	if a.schema == nil {
+		return false
+	}
+	_, ok := a.schema.Properties[name]
+	return ok
+}
+
+// visibilityOf returns the AttributeVisibility of the attribute for the users. The attributes which aren't in the
+// schema, e.g. removed from it since they were written, are hidden.
+func (a *profileAttributes) visibilityOf(name string) string {
+	if !a.has(name) {
+		return repository.AttributeVisibilityHidden
+	}
+	if visibility, ok := a.visibility[name]; ok {
+		return visibility
+	}
+	return repository.AttributeVisibilityReadWrite
+}
+
+// readable returns the attributes the user of the profile sees
+func (a *profileAttributes) readable(attributes map[string]interface{}) map[string]interface{} {
+	readable := map[string]interface{}{}
+	for name, value := range attributes {
+		if a.visibilityOf(name) != repository.AttributeVisibilityHidden {
+			readable[name] = value
+		}
+	}
+	return readable
+}
+
+// writeByUser applies the attributes written by the user of the profile to its current ones. They replace the
+// attributes the user changes, or are merged into them as a JSON Merge Patch, the other attributes are kept.
+func (a *profileAttributes) writeByUser(current map[string]interface{}, written map[string]interface{}, merge bool) (map[string]interface{}, error) {
+	var validationError ValidationError
+	for name := range written {
+		switch {
+		case !a.has(name):
+			validationError.Fields = append(validationError.Fields, FieldValidationError{Field: "attributes." + name, Rule: "unknownAttribute", Params: []string{}})
+		case a.visibilityOf(name) != repository.AttributeVisibilityReadWrite:
+			validationError.Fields = append(validationError.Fields, FieldValidationError{Field: "attributes." + name, Rule: "readOnly", Params: []string{}})
+		}
+	}
+	if len(validationError.Fields) > 0 {
+		return nil, &validationError
+	}
+
+	attributes := map[string]interface{}{}
+	userAttributes := map[string]interface{}{}
+	for name, value := range current {
+		switch {
+		case a.visibilityOf(name) != repository.AttributeVisibilityReadWrite:
+			attributes[name] = value
+		case merge:
+			userAttributes[name] = value
+		}
+	}
+
+	if merge {
+		userAttributes = mergePatch(userAttributes, written).(map[string]interface{})
+	} else {
+		for name, value := range written {
+			userAttributes[name] = value
+		}
+	}
+
+	// the users are only responsible for the attributes they change
+	if a.schema != nil {
+		if err := validateProfileAttributes(a.userSchema(), userAttributes); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, value := range userAttributes {
+		attributes[name] = value
+	}
+	return attributes, nil
+}
+
+// userSchema is the schema restricted to the attributes the users change
+func (a *profileAttributes) userSchema() *openapi3.Schema {
+	schema := *a.schema
+	schema.Properties = openapi3.Schemas{}
+	schema.Required = nil
+	for name, property := range a.schema.Properties {
+		if a.visibilityOf(name) == repository.AttributeVisibilityReadWrite {
+			schema.Properties[name] = property
+		}
+	}
+	for _, name := range a.schema.Required {
+		if a.visibilityOf(name) == repository.AttributeVisibilityReadWrite {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return &schema
+}
+
+// validateProfileAttributes reports the attributes which don't satisfy schema as validation failures of the
+// attributes field, no attribute is valid without schema
+func validateProfileAttributes(schema *openapi3.Schema, attributes map[string]interface{}) error {
+	if schema == nil {
+		var validationError ValidationError
+		for name := range attributes {
+			validationError.Fields = append(validationError.Fields, FieldValidationError{Field: "attributes." + name, Rule: "unknownAttribute", Params: []string{}})
+		}
+		if len(validationError.Fields) > 0 {
+			return &validationError
+		}
+		return nil
+	}
+
+	err := schema.VisitJSON(attributes, openapi3.MultiErrors())
+	if err == nil {
+		return nil
+	}
+
+	validationError, ok := toValidationError(err)
+	if !ok {
+		return err
+	}
+	for i := range validationError.Fields {
+		if validationError.Fields[i].Field == "" {
+			validationError.Fields[i].Field = "attributes"
+			continue
+		}
+		validationError.Fields[i].Field = "attributes." + validationError.Fields[i].Field
+	}
+	return validationError
+}
+
+// mergePatch applies a JSON Merge Patch (RFC 7396) to target: the members of an object patch are merged into the
+// target object, null removes them, any other patch replaces the target
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	merged := map[string]interface{}{}
+	if targetObject, ok := target.(map[string]interface{}); ok {
+		for name, value := range targetObject {
+			merged[name] = value
+		}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = mergePatch(merged[name], value)
+	}
+	return merged
+}
+
+// profileAttributeSchemaResponse lists the visibility of every property of the schema, the default one included
+func profileAttributeSchemaResponse(stored repository.ProfileAttributeSchema, attributes *profileAttributes) generated.ProfileAttributeSchemaResponse {
+	resp := generated.ProfileAttributeSchemaResponse{
+		Version:    int(stored.ID),
+		Schema:     map[string]interface{}{},
+		Visibility: map[string]generated.AttributeVisibility{},
+		CreatedAt:  stored.CreatedAt,
+	}
+	// the schema has been parsed already, it is a JSON object
+	_ = json.Unmarshal(stored.Schema, &resp.Schema)
+
+	for name := range attributes.schema.Properties {
+		resp.Visibility[name] = generated.AttributeVisibility(attributes.visibilityOf(name))
+	}
+	if stored.CreatedBy != nil {
+		createdBy := int(*stored.CreatedBy)
+		resp.CreatedBy = &createdBy
+	}
+	return resp
+}
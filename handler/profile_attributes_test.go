@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProfileAttributeSchema = repository.ProfileAttributeSchema{
+	ID: 2,
+	Schema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"loyalty_tier": {"type": "string", "enum": ["silver", "gold"]},
+			"preferred_branch": {"type": "string", "maxLength": 40},
+			"risk_score": {"type": "number"}
+		}
+	}`),
+	Visibility: map[string]string{"loyalty_tier": repository.AttributeVisibilityReadOnly, "risk_score": repository.AttributeVisibilityHidden},
+	CreatedAt:  time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func setupTestProfileAttributes(t *testing.T, method string, path string, token string, contentType string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(method, path, strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestProfileAttributes(t *testing.T) {
+	profile := repository.Profile{
+		ID:          1,
+		FullName:    "Bakri",
+		CountryCode: "+62",
+		PhoneNumber: "81234567",
+		Version:     3,
+		Attributes: map[string]interface{}{
+			"loyalty_tier":     "gold",
+			"preferred_branch": "Jakarta",
+			"risk_score":       0.3,
+			"removed":          "from the schema",
+		},
+	}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	t.Run("Read", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodGet, "/profile", token, echo.MIMEApplicationJSON, "")
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetProfile)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.GetProfileResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, generated.ProfileAttributes{"loyalty_tier": "gold", "preferred_branch": "Jakarta"}, resp.Attributes)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPatch, "/profile", token, mergePatchContentType, `{"attributes": {"preferred_branch": "Bandung"}}`)
+
+		updated := map[string]interface{}{
+			"loyalty_tier":     "gold",
+			"preferred_branch": "Bandung",
+			"risk_score":       0.3,
+			"removed":          "from the schema",
+		}
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(2)
+		mockRepository.EXPECT().UpdateProfileByID(repository.Profile{ID: 1, Attributes: updated}, []string{repository.ProfileFieldAttributes}, uint64(0)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "81234567", Version: 4, Attributes: updated}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PatchProfile(ctx, generated.PatchProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.UpdateProfileResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, generated.ProfileAttributes{"loyalty_tier": "gold", "preferred_branch": "Bandung"}, resp.Attributes)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPatch, "/profile", token, mergePatchContentType, `{"attributes": {"preferred_branch": null}}`)
+
+		updated := map[string]interface{}{"loyalty_tier": "gold", "risk_score": 0.3, "removed": "from the schema"}
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(2)
+		mockRepository.EXPECT().UpdateProfileByID(repository.Profile{ID: 1, Attributes: updated}, []string{repository.ProfileFieldAttributes}, uint64(0)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, Attributes: updated}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Read Only And Unknown Attributes", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPatch, "/profile", token, mergePatchContentType, `{"attributes": {"loyalty_tier": "silver", "removed": "again"}}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			rules := map[string]string{}
+			for _, fieldError := range resp.Errors {
+				rules[fieldError.Field] = fieldError.Rule
+			}
+			assert.Equal(t, map[string]string{"attributes.loyalty_tier": "readOnly", "attributes.removed": "unknownAttribute"}, rules)
+		}
+	})
+
+	t.Run("Invalid Value", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPatch, "/profile", token, mergePatchContentType, `{"attributes": {"preferred_branch": 12}}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "attributes.preferred_branch", resp.Errors[0].Field)
+				assert.Equal(t, "type", resp.Errors[0].Rule)
+			}
+		}
+	})
+}
+
+func TestProfileAttributesWriteByUser(t *testing.T) {
+	attributes, err := parseProfileAttributeSchema(testProfileAttributeSchema.Schema, testProfileAttributeSchema.Visibility)
+	if !assert.NoError(t, err) {
+		return
+	}
+	current := map[string]interface{}{"loyalty_tier": "gold", "preferred_branch": "Jakarta", "risk_score": 0.3}
+
+	t.Run("Replace", func(t *testing.T) {
+		written, err := attributes.writeByUser(current, map[string]interface{}{}, false)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]interface{}{"loyalty_tier": "gold", "risk_score": 0.3}, written)
+		}
+	})
+
+	t.Run("Without Schema", func(t *testing.T) {
+		_, err := (&profileAttributes{}).writeByUser(nil, map[string]interface{}{"preferred_branch": "Jakarta"}, true)
+		assert.Error(t, err)
+	})
+}
+
+func TestPutAdminProfileAttributeSchema(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleAdmin}, []string{rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite})
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPut, "/admin/profile-attribute-schema", token, echo.MIMEApplicationJSON, `{
+			"schema": {"type": "object", "properties": {"loyalty_tier": {"type": "string"}, "preferred_branch": {"type": "string"}}},
+			"visibility": {"loyalty_tier": "read_only"}
+		}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().CreateProfileAttributeSchema(gomock.Any()).DoAndReturn(func(input repository.ProfileAttributeSchema) (uint64, error) {
+			assert.Equal(t, map[string]string{"loyalty_tier": "read_only"}, input.Visibility)
+			if assert.NotNil(t, input.CreatedBy) {
+				assert.Equal(t, uint64(1), *input.CreatedBy)
+			}
+			return 3, nil
+		}).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.PutAdminProfileAttributeSchema)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ProfileAttributeSchemaResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, 3, resp.Version)
+			assert.Equal(t, map[string]generated.AttributeVisibility{"loyalty_tier": "read_only", "preferred_branch": "read_write"}, resp.Visibility)
+		}
+	})
+
+	t.Run("Not An Object", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPut, "/admin/profile-attribute-schema", token, echo.MIMEApplicationJSON, `{"schema": {"type": "string"}}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PutAdminProfileAttributeSchema(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "schema", resp.Errors[0].Field)
+				assert.Equal(t, "malformed", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Visibility Of An Unknown Attribute", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPut, "/admin/profile-attribute-schema", token, echo.MIMEApplicationJSON, `{
+			"schema": {"type": "object", "properties": {"loyalty_tier": {"type": "string"}}},
+			"visibility": {"tier": "hidden"}
+		}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PutAdminProfileAttributeSchema(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "visibility.tier", resp.Errors[0].Field)
+				assert.Equal(t, "unknownAttribute", resp.Errors[0].Rule)
+			}
+		}
+	})
+}
+
+func TestGetAdminProfileAttributeSchema(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodGet, "/admin/profile-attribute-schema", token, echo.MIMEApplicationJSON, "")
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetAdminProfileAttributeSchema)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.ProfileAttributeSchemaResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, 2, resp.Version)
+			assert.Len(t, resp.Visibility, 3)
+		}
+	})
+
+	t.Run("No Schema", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodGet, "/admin/profile-attribute-schema", token, echo.MIMEApplicationJSON, "")
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(repository.ProfileAttributeSchema{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetAdminProfileAttributeSchema(context)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+}
+
+func TestPutAdminProfilesIdAttributes(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleAdmin}, []string{rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite})
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPut, "/admin/profiles/12/attributes", token, echo.MIMEApplicationJSON, `{"attributes": {"loyalty_tier": "gold", "risk_score": 0.7}}`)
+
+		written := map[string]interface{}{"loyalty_tier": "gold", "risk_score": 0.7}
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(12).Return(repository.Profile{ID: 12}, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(repository.Profile{ID: 12, Attributes: written}, []string{repository.ProfileFieldAttributes}, uint64(0)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PutAdminProfilesIdAttributes(ctx, 12)
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+
+	t.Run("Invalid Value", func(t *testing.T) {
+		context, rec, mockRepository := setupTestProfileAttributes(t, http.MethodPut, "/admin/profiles/12/attributes", token, echo.MIMEApplicationJSON, `{"attributes": {"loyalty_tier": "platinum"}}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(12).Return(repository.Profile{ID: 12}, nil).Times(1)
+		mockRepository.EXPECT().GetLatestProfileAttributeSchema().Return(testProfileAttributeSchema, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PutAdminProfilesIdAttributes(context, 12)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "attributes.loyalty_tier", resp.Errors[0].Field)
+				assert.Equal(t, "enum", resp.Errors[0].Rule)
+			}
+		}
+	})
+}
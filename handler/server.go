@@ -1,17 +1,178 @@
 package handler
 
-import "github.com/hasbiasshidiq/simple-profile/repository"
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/hasbiasshidiq/simple-profile/email"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/phone"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/hasbiasshidiq/simple-profile/sms"
+	"github.com/hasbiasshidiq/simple-profile/storage"
+	"github.com/hasbiasshidiq/simple-profile/totp"
+)
+
+const defaultTOTPIssuer = "Simple Profile"
+
+// Defaults of the blob store, the blobs directory of the working directory served under /blobs
+const (
+	DefaultBlobDir     = "blobs"
+	DefaultBlobBaseURL = "/blobs"
+)
 
 type Server struct {
-	Repository repository.RepositoryInterface
+	Repository      repository.RepositoryInterface
+	Validator       *CustomValidator
+	PhoneParser     *phone.Parser
+	PasswordHasher  *password.Hasher
+	PasswordChecker *password.Checker
+	// PasswordHistorySize is the number of recent passwords, the current one included, which can't be reused
+	PasswordHistorySize int
+	// TOTPIssuer names the service in the authenticator apps
+	TOTPIssuer string
+	// MFASecrets encrypts the secrets of the authenticators stored in the database
+	MFASecrets *totp.SecretCipher
+	SMSSender  sms.Sender
+	// EmailSender delivers the verification links of the emails
+	EmailSender email.Sender
+	// PublicURL is the URL of the service in the links sent by email
+	PublicURL string
+	// BlobStore keeps the uploaded files, e.g. the avatar thumbnails
+	BlobStore storage.BlobStore
+	// RolePolicy grants the permissions of the roles to the access tokens
+	RolePolicy rbac.Policy
+	// IdempotencyKeyTTL is how long the responses of the requests with an Idempotency-Key are replayed
+	IdempotencyKeyTTL time.Duration
+	ValidateResponses bool
+	// runInBackground starts the long tasks which outlive their request, e.g. the data exports
+	runInBackground func(task func())
 }
 
 type NewServerOptions struct {
 	Repository repository.RepositoryInterface
+	// AllowedCountries are the ISO codes of the countries whose phone numbers are accepted, Indonesia by default
+	AllowedCountries []string
+	// PasswordPolicy is the algorithm and cost used to hash passwords, password.DefaultPolicy when empty
+	PasswordPolicy password.Policy
+	// PasswordRules are the requirements of new passwords, password.DefaultRules when nil
+	PasswordRules []password.Rule
+	// PasswordHistorySize prevents reusing the given number of recent passwords, 0 disables the check
+	PasswordHistorySize int
+	// TOTPIssuer names the service in the authenticator apps, "Simple Profile" by default
+	TOTPIssuer string
+	// MFASecretKey encrypts the secrets of the authenticators, totp.SecretKeyLength bytes. A random key is used when
+	// nil, the secrets can't be read after a restart.
+	MFASecretKey []byte
+	// SMSSender delivers the login codes, sms.LogSender when nil which is meant for development and tests only
+	SMSSender sms.Sender
+	// EmailSender delivers the verification links of the emails, email.LogSender when nil which is meant for
+	// development and tests only
+	EmailSender email.Sender
+	// PublicURL is the URL of the service in the links sent by email, e.g. https://api.example.com, the links never
+	// use the Host header of the requests
+	PublicURL string
+	// BlobStore keeps the uploaded files, a storage.LocalStore of DefaultBlobDir when nil
+	BlobStore storage.BlobStore
+	// RolePolicy maps the roles to their permissions, rbac.DefaultPolicy when nil
+	RolePolicy rbac.Policy
+	// IdempotencyKeyTTL is how long the responses of the requests with an Idempotency-Key are replayed, 24 hours by
+	// default
+	IdempotencyKeyTTL time.Duration
+	// ValidateResponses checks every response against api.yml, meant for tests
+	ValidateResponses bool
 }
 
 func NewServer(opts NewServerOptions) *Server {
+	swagger, err := generated.GetSwagger()
+	if err != nil {
+		panic(err)
+	}
+
+	validator, err := NewCustomValidator(swagger)
+	if err != nil {
+		panic(err)
+	}
+
+	phoneParser, err := phone.NewParser(opts.AllowedCountries)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordHasher, err := password.NewHasher(opts.PasswordPolicy)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordRules := append([]password.Rule{}, opts.PasswordRules...)
+	if opts.PasswordRules == nil {
+		passwordRules = password.DefaultRules()
+	}
+	if opts.PasswordHistorySize > 0 {
+		passwordRules = append(passwordRules, password.NotReused(passwordHasher, opts.PasswordHistorySize))
+	}
+
+	totpIssuer := opts.TOTPIssuer
+	if totpIssuer == "" {
+		totpIssuer = defaultTOTPIssuer
+	}
+
+	mfaSecretKey := opts.MFASecretKey
+	if mfaSecretKey == nil {
+		mfaSecretKey = make([]byte, totp.SecretKeyLength)
+		if _, err := rand.Read(mfaSecretKey); err != nil {
+			panic(err)
+		}
+	}
+	mfaSecrets, err := totp.NewSecretCipher(mfaSecretKey)
+	if err != nil {
+		panic(err)
+	}
+
+	smsSender := opts.SMSSender
+	if smsSender == nil {
+		smsSender = sms.LogSender{}
+	}
+
+	emailSender := opts.EmailSender
+	if emailSender == nil {
+		emailSender = email.LogSender{}
+	}
+
+	blobStore := opts.BlobStore
+	if blobStore == nil {
+		blobStore = &storage.LocalStore{Dir: DefaultBlobDir, BaseURL: DefaultBlobBaseURL}
+	}
+
+	rolePolicy := opts.RolePolicy
+	if rolePolicy == nil {
+		rolePolicy = rbac.DefaultPolicy()
+	}
+
+	idempotencyKeyTTL := opts.IdempotencyKeyTTL
+	if idempotencyKeyTTL <= 0 {
+		idempotencyKeyTTL = defaultIdempotencyKeyTTL
+	}
+
 	return &Server{
-		Repository: opts.Repository,
+		Repository:          opts.Repository,
+		Validator:           validator,
+		PhoneParser:         phoneParser,
+		PasswordHasher:      passwordHasher,
+		PasswordChecker:     password.NewChecker(passwordRules...),
+		PasswordHistorySize: opts.PasswordHistorySize,
+		TOTPIssuer:          totpIssuer,
+		MFASecrets:          mfaSecrets,
+		SMSSender:           smsSender,
+		EmailSender:         emailSender,
+		PublicURL:           strings.TrimSuffix(opts.PublicURL, "/"),
+		BlobStore:           blobStore,
+		RolePolicy:          rolePolicy,
+		IdempotencyKeyTTL:   idempotencyKeyTTL,
+		ValidateResponses:   opts.ValidateResponses,
+		runInBackground:     func(task func()) { go task() },
 	}
 }
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/labstack/echo/v4"
+)
+
+// revokeOtherSessions is the session ID revoking every session but the current one
+const revokeOtherSessions = "others"
+
+// unknownDevice names the sessions whose login had no User-Agent
+const unknownDevice = "Unknown device"
+
+func (s *Server) GetProfileSessions(ctx echo.Context) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, sessionID, err := s.extractSessionFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	sessions, err := s.Repository.GetActiveSessions(uint64(userID))
+	if err != nil {
+		log.Println("error fetch sessions : ", err)
+		return err
+	}
+
+	resp := generated.SessionListResponse{Sessions: []generated.Session{}}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, generated.Session{
+			Id:         int(session.ID),
+			DeviceName: session.DeviceName,
+			IpAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == sessionID,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) DeleteProfileSessionsId(ctx echo.Context, id string) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, sessionID, err := s.extractSessionFromToken(token)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	if id == revokeOtherSessions {
+		_, err = s.Repository.RevokeOtherSessions(uint64(userID), sessionID)
+		if err != nil {
+			log.Println("error revoke sessions : ", err)
+			return err
+		}
+		return ctx.NoContent(http.StatusNoContent)
+	}
+
+	revokedID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgSessionNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	// the session is looked up within the sessions of the user, the ones of others are not found
+	revoked, err := s.Repository.RevokeSession(uint64(userID), revokedID)
+	if err != nil {
+		log.Println("error revoke session : ", err)
+		return err
+	}
+	if !revoked {
+		responsePayload := errorResponse(ctx, msgSessionNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// clientIP is the IP address of the client of the request, read by the IPExtractor of the echo instance. The values
+// which aren't IP addresses, e.g. of proxy headers, are replaced by the address of the connection, and by an empty
+// string when neither is one.
+func clientIP(ctx echo.Context) string {
+	ip := net.ParseIP(ctx.RealIP())
+	if ip == nil {
+		host, _, _ := net.SplitHostPort(ctx.Request().RemoteAddr)
+		ip = net.ParseIP(host)
+	}
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// deviceName describes the device of a User-Agent as browser and operating system, e.g. Chrome on Android.
+// Clients which are not browsers are named after their first product token, e.g. okhttp.
+func deviceName(userAgent string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return unknownDevice
+	}
+
+	browser := ""
+	// the order matters, e.g. Edge and Opera mention Chrome and every Chromium browser mentions Safari
+	for _, candidate := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+
+	system := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.name
+			break
+		}
+	}
+
+	var name string
+	switch {
+	case browser != "" && system != "":
+		name = browser + " on " + system
+	case browser != "":
+		name = browser
+	case system != "":
+		name = system
+	default:
+		name = strings.SplitN(strings.Fields(userAgent)[0], "/", 2)[0]
+	}
+
+	// sessions.device_name is a VARCHAR(255), which counts characters
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	return name
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestSessions(t *testing.T, method string, path string, token string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestGetProfileSessions(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	token, _ := createToken(profile, 2, nil, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/sessions", token)
+
+		now := time.Now()
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetActiveSessions(uint64(1)).Return([]repository.Session{
+			{ID: 2, ProfileID: 1, DeviceName: "Chrome on Android", IPAddress: "192.0.2.1", LastSeenAt: now, CreatedAt: now},
+			{ID: 1, ProfileID: 1, DeviceName: "Safari on macOS", IPAddress: "192.0.2.2", LastSeenAt: now.Add(-time.Minute), CreatedAt: now.Add(-time.Hour)},
+		}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		if assert.NoError(t, mockServer.RequestValidator()(mockServer.GetProfileSessions)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.SessionListResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Sessions, 2) {
+				assert.Equal(t, "Chrome on Android", resp.Sessions[0].DeviceName)
+				assert.True(t, resp.Sessions[0].Current)
+				assert.False(t, resp.Sessions[1].Current)
+			}
+		}
+	})
+
+	t.Run("No Sessions", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/sessions", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetActiveSessions(uint64(1)).Return(nil, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileSessions(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `{"sessions": []}`, rec.Body.String())
+		}
+	})
+
+	t.Run("Revoked Session", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/sessions", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(false, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, Status: repository.ProfileStatusActive}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileSessions(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "invalid_token", resp.Code)
+		}
+	})
+
+	t.Run("Suspended Profile", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/sessions", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(false, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, Status: repository.ProfileStatusSuspended}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.GetProfileSessions(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "profile_suspended", resp.Code)
+		}
+	})
+
+	t.Run("Session Check Fails", func(t *testing.T) {
+		context, _, mockRepository := setupTestSessions(t, http.MethodGet, "/profile/sessions", token)
+
+		// the token isn't refused when the database is unavailable, the server error is answered by echo
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(false, errors.New("connection refused")).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		err := mockServer.GetProfileSessions(context)
+		assert.ErrorIs(t, err, errSessionCheckFailed)
+	})
+}
+
+func TestDeleteProfileSession(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	token, _ := createToken(profile, 2, nil, nil)
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodDelete, "/profile/sessions/3", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().RevokeSession(uint64(1), uint64(3)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.DeleteProfileSessionsId(context, "3")) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodDelete, "/profile/sessions/3", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().RevokeSession(uint64(1), uint64(3)).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.DeleteProfileSessionsId(context, "3")) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Revoke Others", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodDelete, "/profile/sessions/others", token)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(2)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().RevokeOtherSessions(uint64(1), uint64(2)).Return(int64(3), nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.DeleteProfileSessionsId(context, "others")) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSessions(t, http.MethodDelete, "/profile/sessions/all", token)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.RequestValidator()(func(ctx echo.Context) error {
+			return mockServer.DeleteProfileSessionsId(ctx, "all")
+		})(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+}
+
+func TestLoginCreatesSession(t *testing.T) {
+	profile := repository.Profile{ID: 1}
+	context, rec, mockRepository := setupTestLogin(t, `{}`)
+	context.Request().Header.Set("User-Agent", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36")
+	context.Request().Header.Set("X-Real-IP", "203.0.113.7")
+
+	mockRepository.EXPECT().CreateSession(gomock.Any()).DoAndReturn(func(session repository.Session) (uint64, error) {
+		assert.Equal(t, uint64(1), session.ProfileID)
+		assert.Equal(t, "Chrome on Android", session.DeviceName)
+		assert.Equal(t, "203.0.113.7", session.IPAddress)
+		assert.WithinDuration(t, time.Now().Add(accessTokenLifetime), session.ExpiresAt, time.Second)
+		return 5, nil
+	}).Times(1)
+	mockRepository.EXPECT().GetProfileRoles(uint64(1)).Return(nil, nil).Times(1)
+	mockRepository.EXPECT().UpsertProfileMetaData(gomock.Any()).Return(0, nil).Times(1)
+	mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+	mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+	if assert.NoError(t, mockServer.completeLogin(context, profile, loginMethodPassword)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp generated.LoginResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		userID, sessionID, err := parseAccessToken(resp.JwtToken)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, userID)
+		assert.Equal(t, uint64(5), sessionID)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor echo.IPExtractor
+		header    string
+		value     string
+		expected  string
+	}{
+		{name: "Proxy Header", header: "X-Real-IP", value: "203.0.113.7", expected: "203.0.113.7"},
+		{name: "IPv6 Proxy Header", header: "X-Real-IP", value: "2001:db8::1", expected: "2001:db8::1"},
+		{name: "Header Not An Address", header: "X-Forwarded-For", value: strings.Repeat("x", 60), expected: "192.0.2.1"},
+		{name: "Header Of An Untrusted Client", extractor: echo.ExtractIPDirect(), header: "X-Forwarded-For", value: "203.0.113.7", expected: "192.0.2.1"},
+		{name: "Connection", extractor: echo.ExtractIPDirect(), expected: "192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.IPExtractor = test.extractor
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+
+			assert.Equal(t, test.expected, clientIP(e.NewContext(req, httptest.NewRecorder())))
+		})
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0":           "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.6 Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:131.0) Gecko/20100101 Firefox/131.0":                                                    "Firefox on macOS",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36":                                   "Chrome on Linux",
+		"okhttp/4.12.0": "okhttp",
+		"":              "Unknown device",
+	}
+
+	for userAgent, expected := range tests {
+		assert.Equal(t, expected, deviceName(userAgent), userAgent)
+	}
+}
+
+func TestDeviceNameTruncatedOnCharacters(t *testing.T) {
+	// the name is cut at 255 characters, not in the middle of a multi-byte one
+	name := deviceName(strings.Repeat("é", 300) + "/1.0")
+	assert.Equal(t, strings.Repeat("é", 255), name)
+	assert.True(t, utf8.ValidString(name))
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) PostAdminProfilesIdSuspend(ctx echo.Context, id int) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	operatorID, err := s.extractOperatorIDFromToken(token, rbac.PermissionProfilesWrite)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var request generated.SuspendProfileRequest
+
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	if id == operatorID {
+		responsePayload := errorResponse(ctx, msgCannotSuspendSelf)
+		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	}
+
+	profile, err := s.Repository.GetProfileByID(id)
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch profile : ", err)
+		return err
+	}
+
+	suspended, err := s.Repository.SuspendProfile(profile.ID, uint64(operatorID), request.Reason)
+	if err != nil {
+		log.Println("error suspend profile : ", err)
+		return err
+	}
+	if !suspended {
+		responsePayload := errorResponse(ctx, msgAlreadySuspended)
+		return ctx.JSON(http.StatusConflict, responsePayload)
+	}
+
+	// the operator and the reason are the status columns of the profile, the event is shown to the user
+	s.recordOperatorSecurityEvent(profile.ID, repository.SecurityEventProfileSuspended)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) PostAdminProfilesIdUnsuspend(ctx echo.Context, id int) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	operatorID, err := s.extractOperatorIDFromToken(token, rbac.PermissionProfilesWrite)
+	if err != nil {
+		return tokenErrorResponse(ctx, err)
+	}
+
+	var request generated.UnsuspendProfileRequest
+
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	var reason string
+	if request.Reason != nil {
+		reason = *request.Reason
+	}
+
+	profile, err := s.Repository.GetProfileByID(id)
+	if err == sql.ErrNoRows {
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
+		return ctx.JSON(http.StatusNotFound, responsePayload)
+	}
+	if err != nil {
+		log.Println("error fetch profile : ", err)
+		return err
+	}
+
+	unsuspended, err := s.Repository.UnsuspendProfile(profile.ID, uint64(operatorID), reason)
+	if err != nil {
+		log.Println("error unsuspend profile : ", err)
+		return err
+	}
+	if !unsuspended {
+		responsePayload := errorResponse(ctx, msgNotSuspended)
+		return ctx.JSON(http.StatusConflict, responsePayload)
+	}
+
+	s.recordOperatorSecurityEvent(profile.ID, repository.SecurityEventProfileUnsuspended)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
+	"github.com/hasbiasshidiq/simple-profile/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestSuspension(t *testing.T, path string, token string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestPostAdminProfilesIdSuspend(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleAdmin}, []string{rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite})
+	profile := repository.Profile{ID: 12, Status: repository.ProfileStatusActive}
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/12/suspend", token, `{"reason": "spam"}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(12).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().SuspendProfile(uint64(12), uint64(1), "spam").Return(true, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 12,
+			Type:      repository.SecurityEventProfileSuspended,
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PostAdminProfilesIdSuspend(ctx, 12)
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("Already Suspended", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/12/suspend", token, `{"reason": "spam"}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(12).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().SuspendProfile(uint64(12), uint64(1), "spam").Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesIdSuspend(context, 12)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "profile_already_suspended", resp.Code)
+		}
+	})
+
+	t.Run("Own Profile", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/1/suspend", token, `{"reason": "testing"}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesIdSuspend(context, 1)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/404/suspend", token, `{"reason": "spam"}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(404).Return(repository.Profile{}, sql.ErrNoRows).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesIdSuspend(context, 404)) {
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("Read Permission Only", func(t *testing.T) {
+		supportToken, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleSupport}, []string{rbac.PermissionProfilesRead})
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/12/suspend", supportToken, `{"reason": "spam"}`)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PostAdminProfilesIdSuspend(ctx, 12)
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+}
+
+func TestPostAdminProfilesIdUnsuspend(t *testing.T) {
+	operator := repository.Profile{ID: 1}
+	token, _ := createToken(operator, 1, []string{rbac.RoleUser, rbac.RoleAdmin}, []string{rbac.PermissionProfilesRead, rbac.PermissionProfilesWrite})
+	profile := repository.Profile{ID: 12, Status: repository.ProfileStatusSuspended}
+
+	t.Run("Success", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/12/unsuspend", token, `{}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(12).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().UnsuspendProfile(uint64(12), uint64(1), "").Return(true, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 12,
+			Type:      repository.SecurityEventProfileUnsuspended,
+		}).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PostAdminProfilesIdUnsuspend(ctx, 12)
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		}
+	})
+
+	t.Run("Not Suspended", func(t *testing.T) {
+		context, rec, mockRepository := setupTestSuspension(t, "/admin/profiles/12/unsuspend", token, `{"reason": "appeal"}`)
+
+		mockRepository.EXPECT().TouchSession(uint64(1), uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(12).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().UnsuspendProfile(uint64(12), uint64(1), "appeal").Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PostAdminProfilesIdUnsuspend(context, 12)) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "profile_not_suspended", resp.Code)
+		}
+	})
+}
@@ -1,103 +1,356 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/go-playground/validator"
 	"github.com/hasbiasshidiq/simple-profile/generated"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"golang.org/x/text/language"
 )
 
-type (
-	UpdateProfileValidator struct {
-		FullName    *string `json:"full_name" validate:"omitempty,min=3,max=60"`
-		PhoneNumber *string `json:"phone_number" validate:"omitempty,min=10,max=13,indonesiaCountryCodePrefix"`
+// mergePatchContentType is the media type of the JSON Merge Patch documents of PATCH /profile
+const mergePatchContentType = "application/merge-patch+json"
+
+// profileMergePatchFields are the properties of a merge patch of the profile, which are named after the fields of
+// UpdateProfileByID, and whether null clears them. The fields the profile can't be without refuse null.
+var profileMergePatchFields = []struct {
+	field     string
+	clearable bool
+}{
+	{field: repository.ProfileFieldFullName, clearable: false},
+	{field: repository.ProfileFieldPhoneNumber, clearable: false},
+	{field: repository.ProfileFieldEmail, clearable: true},
+	{field: repository.ProfileFieldDateOfBirth, clearable: true},
+	{field: repository.ProfileFieldGender, clearable: true},
+	{field: repository.ProfileFieldLocale, clearable: true},
+	{field: repository.ProfileFieldTimezone, clearable: true},
+	{field: repository.ProfileFieldAttributes, clearable: true},
+}
+
+// profileUpdate is a change of the profile by PUT or PATCH, only its fields are written
+type profileUpdate struct {
+	fields      []string
+	fullName    string
+	phoneNumber string
+	// the optional attributes, nil clears them
+	email       *string
+	dateOfBirth *time.Time
+	gender      *string
+	locale      *string
+	timezone    *string
+	// attributes are the custom attributes written by the user, mergeAttributes merges them into the current ones
+	// instead of replacing them
+	attributes      map[string]interface{}
+	mergeAttributes bool
+}
+
+// has tells whether the update writes the field
+func (u profileUpdate) has(field string) bool {
+	for _, updated := range u.fields {
+		if updated == field {
+			return true
+		}
 	}
-)
+	return false
+}
 
-func (s *Server) PutProfile(ctx echo.Context) error {
+func (s *Server) PutProfile(ctx echo.Context, params generated.PutProfileParams) error {
 	// Extract the token from the Authorization header
 	token, err := extractToken(ctx)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Invalid Token"}
+		responsePayload := errorResponse(ctx, msgInvalidToken)
 		return ctx.JSON(http.StatusForbidden, responsePayload)
 	}
 
-	userID, err := extractUserIDFromToken(token)
+	userID, err := s.extractUserIDFromToken(token)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Invalid Token"}
-		return ctx.JSON(http.StatusForbidden, responsePayload)
+		return tokenErrorResponse(ctx, err)
 	}
 
 	var request generated.UpdateProfileRequest
 
-	customValidator := validator.New()
-	customValidator.RegisterValidation("indonesiaCountryCodePrefix", validatePhoneWithPrefix)
-	customValidator.RegisterValidation("strongPassword", validateStrongPassword)
+	err = ctx.Bind(&request)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
 
-	ctx.Echo().Validator = &CustomValidator{validator: customValidator}
+	// PUT replaces the whole profile, every field a merge patch can change is written. The request validator
+	// refuses the requests missing a required field, the optional ones absent are cleared.
+	update := profileUpdate{
+		fullName:    request.FullName,
+		phoneNumber: request.PhoneNumber,
+		email:       request.Email,
+		dateOfBirth: dateValue(request.DateOfBirth),
+		gender:      (*string)(request.Gender),
+		locale:      request.Locale,
+		timezone:    request.Timezone,
+	}
+	if request.Attributes != nil {
+		update.attributes = *request.Attributes
+	}
+	for _, property := range profileMergePatchFields {
+		update.fields = append(update.fields, property.field)
+	}
 
-	err = ctx.Bind(&request)
+	return s.updateProfile(ctx, userID, update, params.IfMatch)
+}
+
+func (s *Server) PatchProfile(ctx echo.Context, params generated.PatchProfileParams) error {
+	token, err := extractToken(ctx)
+	if err != nil {
+		responsePayload := errorResponse(ctx, msgInvalidToken)
+		return ctx.JSON(http.StatusForbidden, responsePayload)
+	}
+
+	userID, err := s.extractUserIDFromToken(token)
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, err.Error())
+		return tokenErrorResponse(ctx, err)
 	}
 
-	if request.FullName == nil && request.PhoneNumber == nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "full_name or phone_number should be filled"}
-		return ctx.JSON(http.StatusBadRequest, responsePayload)
+	mediaType, _, err := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || mediaType != mergePatchContentType {
+		return ctx.JSON(http.StatusUnsupportedMediaType, errorResponse(ctx, msgInvalidRequestBody))
 	}
-	updateProfileValidator := UpdateProfileValidator{}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	// the document is read twice, as raw properties to tell the absent ones from the null ones, and typed
+	var document map[string]json.RawMessage
+	var request generated.ProfileMergePatch
+	if json.Unmarshal(body, &document) != nil || document == nil || json.Unmarshal(body, &request) != nil {
+		return ctx.JSON(http.StatusBadRequest, errorResponse(ctx, msgInvalidRequestBody))
+	}
+
+	update := profileUpdate{
+		email:       request.Email,
+		dateOfBirth: dateValue(request.DateOfBirth),
+		gender:      (*string)(request.Gender),
+		locale:      request.Locale,
+		timezone:    request.Timezone,
+		// a merge patch of the attributes is merged into them, null removes every attribute the user changes
+		mergeAttributes: request.Attributes != nil,
+	}
+	if request.Attributes != nil {
+		update.attributes = *request.Attributes
+	}
+	var validationError ValidationError
+	for _, property := range profileMergePatchFields {
+		value, ok := document[property.field]
+		if !ok {
+			continue
+		}
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) && !property.clearable {
+			validationError.Fields = append(validationError.Fields, FieldValidationError{Field: property.field, Rule: "required"})
+			continue
+		}
+		update.fields = append(update.fields, property.field)
+	}
+	if len(validationError.Fields) > 0 {
+		return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, &validationError))
+	}
+
 	if request.FullName != nil {
-		updateProfileValidator.FullName = request.FullName
+		update.fullName = *request.FullName
 	}
 	if request.PhoneNumber != nil {
-		updateProfileValidator.PhoneNumber = request.PhoneNumber
+		update.phoneNumber = *request.PhoneNumber
 	}
 
-	if err = ctx.Validate(updateProfileValidator); err != nil {
-		return ctx.JSON(http.StatusBadRequest, generated.GeneralErrorResponse{Message: err.Error()})
+	return s.updateProfile(ctx, userID, update, params.IfMatch)
+}
+
+// updateProfile writes the fields of the update, on the version of ifMatch when given, and answers with the
+// updated profile
+func (s *Server) updateProfile(ctx echo.Context, userID int, update profileUpdate, ifMatch *string) error {
+	profile := repository.Profile{
+		ID:          uint64(userID),
+		FullName:    update.fullName,
+		DateOfBirth: update.dateOfBirth,
+		Gender:      update.gender,
+		Timezone:    update.timezone,
 	}
+	changesPhoneNumber := update.has(repository.ProfileFieldPhoneNumber)
 
-	if request.PhoneNumber != nil {
-		localPhoneNumber := (*request.PhoneNumber)[3:]
+	if changesPhoneNumber {
+		phoneNumber, err := s.PhoneParser.Parse(update.phoneNumber)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, phoneNumberError("phone_number", err)))
+		}
+		profile.CountryCode = phoneNumber.CountryCode
+		profile.PhoneNumber = phoneNumber.NationalNumber
+	}
 
-		isExist, err := s.Repository.GetPhoneNumberExistenceWithExcludedID(localPhoneNumber, userID)
+	if update.dateOfBirth != nil {
+		if err := dateOfBirthError("date_of_birth", *update.dateOfBirth); err != nil {
+			return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, err))
+		}
+	}
+
+	// the emails are stored in lower case, so the same address is always written the same way
+	if update.email != nil {
+		email := strings.ToLower(*update.email)
+		profile.Email = &email
+	}
+
+	// the locales are stored as canonical tags, e.g. id-ID for id-id
+	if update.locale != nil {
+		tag, err := language.Parse(*update.locale)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, &ValidationError{Fields: []FieldValidationError{{
+				Field:  "locale",
+				Rule:   "locale",
+				Params: []string{},
+			}}}))
+		}
+		locale := tag.String()
+		profile.Locale = &locale
+	}
+
+	// the current profile is needed to check If-Match, to record the previous phone number and to keep the attributes
+	// the user doesn't change
+	changesAttributes := update.has(repository.ProfileFieldAttributes)
+	var currentProfile repository.Profile
+	if ifMatch != nil || changesPhoneNumber || changesAttributes {
+		var err error
+		currentProfile, err = s.Repository.GetProfileByID(userID)
+		if err != nil {
+			responsePayload := errorResponse(ctx, msgProfileNotFound)
+			return ctx.JSON(http.StatusNotFound, responsePayload)
+		}
+	}
+
+	var expectedVersion uint64
+	if ifMatch != nil {
+		if !ifMatchAllows(*ifMatch, profileETag(currentProfile)) {
+			responsePayload := errorResponse(ctx, msgProfileModified)
+			return ctx.JSON(http.StatusPreconditionFailed, responsePayload)
+		}
+		expectedVersion = currentProfile.Version
+	}
+
+	if changesAttributes {
+		// the schema is only read for the profiles with attributes
+		attributes := &profileAttributes{}
+		if len(update.attributes) > 0 || len(currentProfile.Attributes) > 0 {
+			var err error
+			attributes, err = s.loadProfileAttributes()
+			if err != nil {
+				log.Println("error load profile attribute schema : ", err)
+				return err
+			}
+		}
+
+		var err error
+		profile.Attributes, err = attributes.writeByUser(currentProfile.Attributes, update.attributes, update.mergeAttributes)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, validationErrorResponse(ctx, err))
+		}
+	}
+
+	// the previous number is kept in the audit trail of the change
+	var previousPhoneNumber string
+	if changesPhoneNumber {
+		isExist, err := s.Repository.GetPhoneNumberExistenceWithExcludedID(profile.CountryCode, profile.PhoneNumber, userID)
 		if err != nil {
 			return err
 		}
 		if isExist {
-			responsePayload := generated.GeneralErrorResponse{Message: "Phone Number Already Exist"}
+			responsePayload := errorResponse(ctx, msgPhoneNumberExist)
 			return ctx.JSON(http.StatusConflict, responsePayload)
 		}
+		previousPhoneNumber = currentProfile.CountryCode + currentProfile.PhoneNumber
 	}
 
-	profile := repository.Profile{ID: uint64(userID)}
-	if request.PhoneNumber != nil {
-		localPhoneNumber := (*request.PhoneNumber)[3:]
-		profile.PhoneNumber = localPhoneNumber
-	}
-	if request.FullName != nil {
-		profile.FullName = *request.FullName
+	// the emails identify the accounts at login
+	changesEmail := update.has(repository.ProfileFieldEmail) && profile.Email != nil
+	if changesEmail {
+		isExist, err := s.Repository.GetEmailExistenceWithExcludedID(*profile.Email, userID)
+		if err != nil {
+			return err
+		}
+		if isExist {
+			responsePayload := errorResponse(ctx, msgEmailExist)
+			return ctx.JSON(http.StatusConflict, responsePayload)
+		}
 	}
 
-	err = s.Repository.UpdateProfileByID(profile)
-
+	updated, err := s.Repository.UpdateProfileByID(profile, update.fields, expectedVersion)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Can't update profile"}
+		responsePayload := errorResponse(ctx, msgUpdateProfileFailed)
 		return ctx.JSON(http.StatusConflict, responsePayload)
 	}
 
+	// another update was made between the check of If-Match and this one
+	if !updated && expectedVersion != 0 {
+		responsePayload := errorResponse(ctx, msgProfileModified)
+		return ctx.JSON(http.StatusPreconditionFailed, responsePayload)
+	}
+
+	if changesPhoneNumber && previousPhoneNumber != profile.CountryCode+profile.PhoneNumber {
+		s.recordSecurityEvent(ctx, profile.ID, repository.SecurityEventPhoneNumberChanged, map[string]string{
+			"previous_phone_number": previousPhoneNumber,
+			"phone_number":          profile.CountryCode + profile.PhoneNumber,
+		})
+	}
+
 	profile, err = s.Repository.GetProfileByID(userID)
 	if err != nil {
-		responsePayload := generated.GeneralErrorResponse{Message: "Profile not found"}
+		responsePayload := errorResponse(ctx, msgProfileNotFound)
 		return ctx.JSON(http.StatusNotFound, responsePayload)
 	}
 
-	resp := generated.UpdateProfileResponse{
-		FullName:    profile.FullName,
-		PhoneNumber: profile.CountryCode + profile.PhoneNumber,
+	// a new address is verified with a link, a failure leaves the user requesting another one
+	if changesEmail && profile.Email != nil && profile.EmailVerifiedAt == nil {
+		if _, err := s.sendEmailVerification(ctx, profile.ID, *profile.Email); err != nil {
+			log.Println("error send email verification : ", err)
+		}
+	}
+
+	attributes, err := s.readableProfileAttributes(profile.Attributes)
+	if err != nil {
+		log.Println("error load profile attribute schema : ", err)
+		return err
 	}
 
-	return ctx.JSON(http.StatusOK, resp)
+	ctx.Response().Header().Set("ETag", profileETag(profile))
+	return ctx.JSON(http.StatusOK, generated.UpdateProfileResponse(s.profileResponse(profile, attributes)))
+}
+
+// dateValue is the time of a date of the API, nil when not given
+func dateValue(date *openapi_types.Date) *time.Time {
+	if date == nil {
+		return nil
+	}
+	value := date.Time
+	return &value
+}
+
+// profileETag is the entity tag of the representations of the profile, it changes with every update
+func profileETag(profile repository.Profile) string {
+	return `"` + strconv.FormatUint(profile.Version, 10) + `"`
+}
+
+// ifMatchAllows tells whether the If-Match header lists the entity tag, or any tag with *. If-Match uses the strong
+// comparison, the weak tags never match.
+func ifMatchAllows(ifMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/hasbiasshidiq/simple-profile/testing-init"
 
 	"github.com/golang/mock/gomock"
+	emailsender "github.com/hasbiasshidiq/simple-profile/email"
+	"github.com/hasbiasshidiq/simple-profile/generated"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +21,7 @@ import (
 
 func setupTestPutProfile(t *testing.T, token string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
 	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/profile", strings.NewReader(requestBody))
+	req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(requestBody))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -38,105 +43,415 @@ func TestPutProfile(t *testing.T) {
 			"phone_number" : "+6289627117",
 			"full_name" : "Mr Bill Brod"
 		}`
-		updatePhoneNumberOnly = `{
-			"phone_number" : "+6289627117"
-		}`
-		updateNameOnly = `{
-			"full_name" : "Mr Bill Brod"
-		}`
 		invalidPhoneNumber = `{
-			"phone_number" : "08589627117"
+			"phone_number" : "0858-962",
+			"full_name" : "Mr Bill Brod"
 		}`
 	)
 
 	t.Run("Success", func(t *testing.T) {
-		profile := repository.Profile{ID: 1}
+		profile := repository.Profile{ID: 1, CountryCode: "+62", PhoneNumber: "81234567"}
 
-		token, _ := createToken(profile)
+		token, _ := createToken(profile, 1, nil, nil)
 		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
 
-		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
-		mockRepository.EXPECT().UpdateProfileByID(gomock.Any()).Return(nil).Times(1)
-		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(gomock.Any(), gomock.Any(), uint64(0)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, nil).Times(2)
+		mockRepository.EXPECT().CreateSecurityEvent(repository.SecurityEvent{
+			ProfileID: 1,
+			Type:      repository.SecurityEventPhoneNumberChanged,
+			IPAddress: "192.0.2.1",
+			Details:   map[string]string{"previous_phone_number": "+6281234567", "phone_number": "+6289627117"},
+		}).Return(nil).Times(1)
 
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
-		if assert.NoError(t, mockServer.PutProfile(context)) {
+		if assert.NoError(t, mockServer.PutProfile(context, generated.PutProfileParams{})) {
 			assert.Equal(t, http.StatusOK, rec.Code)
 		}
 	})
 
-	t.Run("Success Update Phone Number Only", func(t *testing.T) {
-		profile := repository.Profile{ID: 1}
+	t.Run("Unchanged Phone Number Is Not Recorded", func(t *testing.T) {
+		profile := repository.Profile{ID: 1, CountryCode: "+62", PhoneNumber: "89627117"}
 
-		token, _ := createToken(profile)
-		context, rec, mockRepository := setupTestPutProfile(t, token, updatePhoneNumberOnly)
+		token, _ := createToken(profile, 1, nil, nil)
+		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
 
-		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
-		mockRepository.EXPECT().UpdateProfileByID(gomock.Any()).Return(nil).Times(1)
-		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(gomock.Any(), gomock.Any(), uint64(0)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, nil).Times(2)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Times(0)
 
-		mockServer := &Server{Repository: mockRepository}
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
-		if assert.NoError(t, mockServer.PutProfile(context)) {
+		if assert.NoError(t, mockServer.PutProfile(context, generated.PutProfileParams{})) {
 			assert.Equal(t, http.StatusOK, rec.Code)
 		}
 	})
 
-	t.Run("Success Update Name Only", func(t *testing.T) {
+	t.Run("Forbidden", func(t *testing.T) {
+
+		token := "INVALIDTOKEN"
+		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
+
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PutProfile(context, generated.PutProfileParams{})) {
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Duplicate Phone Number", func(t *testing.T) {
 		profile := repository.Profile{ID: 1}
 
-		token, _ := createToken(profile)
-		context, rec, mockRepository := setupTestPutProfile(t, token, updateNameOnly)
+		token, _ := createToken(profile, 1, nil, nil)
+		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
-		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
-		mockRepository.EXPECT().UpdateProfileByID(gomock.Any()).Return(nil).Times(1)
-		mockRepository.EXPECT().GetProfileByID(gomock.Any()).Return(profile, nil).Times(1)
+		if assert.NoError(t, mockServer.PutProfile(context, generated.PutProfileParams{})) {
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		}
+	})
 
-		mockServer := &Server{Repository: mockRepository}
+	t.Run("Invalid Phone Number", func(t *testing.T) {
+		profile := repository.Profile{ID: 1}
+
+		token, _ := createToken(profile, 1, nil, nil)
+		context, rec, mockRepository := setupTestPutProfile(t, token, invalidPhoneNumber)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PutProfile(ctx, generated.PutProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
 
-		if assert.NoError(t, mockServer.PutProfile(context)) {
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "phoneLength", resp.Errors[0].Rule)
+				assert.Equal(t, []string{"7", "12"}, resp.Errors[0].Params)
+			}
+		}
+	})
+
+	t.Run("If-Match Current Version", func(t *testing.T) {
+		profile := repository.Profile{ID: 1, FullName: "Mr Bill Brod", CountryCode: "+62", PhoneNumber: "89627117", Version: 3}
+
+		token, _ := createToken(profile, 1, nil, nil)
+		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
+
+		ifMatch := `W/"2", "3"`
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID("+62", "89627117", 1).Return(false, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(
+			repository.Profile{ID: 1, FullName: "Mr Bill Brod", CountryCode: "+62", PhoneNumber: "89627117", Attributes: map[string]interface{}{}},
+			[]string{
+				repository.ProfileFieldFullName,
+				repository.ProfileFieldPhoneNumber,
+				repository.ProfileFieldEmail,
+				repository.ProfileFieldDateOfBirth,
+				repository.ProfileFieldGender,
+				repository.ProfileFieldLocale,
+				repository.ProfileFieldTimezone,
+				repository.ProfileFieldAttributes,
+			},
+			uint64(3),
+		).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, FullName: "Mr Bill Brod", CountryCode: "+62", PhoneNumber: "89627117", Version: 4}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PutProfile(ctx, generated.PutProfileParams{IfMatch: &ifMatch})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
 			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
 		}
 	})
 
-	t.Run("Forbidden", func(t *testing.T) {
+	t.Run("If-Match Outdated Version", func(t *testing.T) {
+		profile := repository.Profile{ID: 1, Version: 4}
 
-		token := "INVALIDTOKEN"
+		token, _ := createToken(profile, 1, nil, nil)
 		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
 
-		mockServer := &Server{Repository: mockRepository}
+		ifMatch := `"3"`
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
 
-		if assert.NoError(t, mockServer.PutProfile(context)) {
-			assert.Equal(t, http.StatusForbidden, rec.Code)
+		if assert.NoError(t, mockServer.PutProfile(context, generated.PutProfileParams{IfMatch: &ifMatch})) {
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "profile_modified", resp.Code)
 		}
 	})
 
-	t.Run("Duplicate Phone Number", func(t *testing.T) {
-		profile := repository.Profile{ID: 1}
+	t.Run("Changed Concurrently", func(t *testing.T) {
+		profile := repository.Profile{ID: 1, Version: 3}
 
-		token, _ := createToken(profile)
+		token, _ := createToken(profile, 1, nil, nil)
 		context, rec, mockRepository := setupTestPutProfile(t, token, updateProfileSuccess)
 
-		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
-		mockServer := &Server{Repository: mockRepository}
+		ifMatch := "*"
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(gomock.Any(), gomock.Any(), uint64(3)).Return(false, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PutProfile(context, generated.PutProfileParams{IfMatch: &ifMatch})) {
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		}
+	})
+}
+
+func setupTestPatchProfile(t *testing.T, token string, contentType string, requestBody string) (context echo.Context, rec *httptest.ResponseRecorder, mockRepository *repository.MockRepositoryInterface) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/profile", strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	context = e.NewContext(req, rec)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRepository = repository.NewMockRepositoryInterface(mockCtrl)
+
+	return context, rec, mockRepository
+}
+
+func TestPatchProfile(t *testing.T) {
+	profile := repository.Profile{ID: 1, FullName: "Bakri", CountryCode: "+62", PhoneNumber: "81234567", Version: 3}
+	token, _ := createToken(profile, 1, nil, nil)
+
+	t.Run("Update Name Only", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"full_name": "Mr Bill Brod"}`)
+
+		ifMatch := `W/"2", "3"`
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(repository.Profile{ID: 1, FullName: "Mr Bill Brod"}, []string{repository.ProfileFieldFullName}, uint64(3)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(repository.Profile{ID: 1, FullName: "Mr Bill Brod", CountryCode: "+62", PhoneNumber: "81234567", Version: 4}, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PatchProfile(ctx, generated.PatchProfileParams{IfMatch: &ifMatch})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+
+			var resp generated.UpdateProfileResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "Mr Bill Brod", resp.FullName)
+		}
+	})
+
+	t.Run("Update Phone Number Only", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"phone_number": "0896-2711-7"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(2)
+		mockRepository.EXPECT().GetPhoneNumberExistenceWithExcludedID("+62", "89627117", 1).Return(false, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(repository.Profile{ID: 1, CountryCode: "+62", PhoneNumber: "89627117"}, []string{repository.ProfileFieldPhoneNumber}, uint64(0)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().CreateSecurityEvent(gomock.Any()).Return(nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
 
-		if assert.NoError(t, mockServer.PutProfile(context)) {
+	t.Run("Update Attributes", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{
+			"email": "Bakri@Example.com",
+			"date_of_birth": "1990-12-31",
+			"gender": "male",
+			"locale": "id-id",
+			"timezone": "Asia/Jakarta"
+		}`)
+
+		email, gender, locale, timezone := "bakri@example.com", "male", "id-ID", "Asia/Jakarta"
+		dateOfBirth := time.Date(1990, time.December, 31, 0, 0, 0, 0, time.UTC)
+		updated := repository.Profile{ID: 1, Email: &email, DateOfBirth: &dateOfBirth, Gender: &gender, Locale: &locale, Timezone: &timezone}
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetEmailExistenceWithExcludedID(email, 1).Return(false, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(updated, []string{
+			repository.ProfileFieldEmail,
+			repository.ProfileFieldDateOfBirth,
+			repository.ProfileFieldGender,
+			repository.ProfileFieldLocale,
+			repository.ProfileFieldTimezone,
+		}, uint64(0)).Return(true, nil).Times(1)
+		updated.FullName, updated.CountryCode, updated.PhoneNumber, updated.Version = "Bakri", "+62", "81234567", 4
+		mockRepository.EXPECT().GetProfileByID(1).Return(updated, nil).Times(1)
+		mockRepository.EXPECT().MarkEmailVerificationSent(uint64(1), email, gomock.Any()).Return(true, nil).Times(1)
+		mailbox := filepath.Join(t.TempDir(), "mailbox.jsonl")
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, EmailSender: &emailsender.FileSender{Path: mailbox}, PublicURL: "https://api.example.com", ValidateResponses: true})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PatchProfile(ctx, generated.PatchProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var resp generated.UpdateProfileResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, &email, resp.Email)
+			assert.False(t, resp.EmailVerified)
+			if assert.NotNil(t, resp.DateOfBirth) {
+				assert.Equal(t, "1990-12-31", resp.DateOfBirth.String())
+			}
+			assert.Equal(t, &locale, resp.Locale)
+
+			// the new address is sent a verification link
+			messages, err := emailsender.ReadMessages(mailbox)
+			if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+				assert.Equal(t, email, messages[0].To)
+				assert.Contains(t, messages[0].Body, "https://api.example.com/profile/email/verify?token=")
+			}
+		}
+	})
+
+	t.Run("Email Already Used", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"email": "siti@example.com"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetEmailExistenceWithExcludedID("siti@example.com", 1).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
 			assert.Equal(t, http.StatusConflict, rec.Code)
+
+			var resp generated.GeneralErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, "email_already_exist", resp.Code)
 		}
 	})
 
-	t.Run("Invalid Phone Number", func(t *testing.T) {
-		profile := repository.Profile{ID: 1}
+	t.Run("Clear Attributes", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"email": null, "timezone": null}`)
 
-		token, _ := createToken(profile)
-		context, rec, mockRepository := setupTestPutProfile(t, token, invalidPhoneNumber)
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().UpdateProfileByID(repository.Profile{ID: 1}, []string{repository.ProfileFieldEmail, repository.ProfileFieldTimezone}, uint64(0)).Return(true, nil).Times(1)
+		mockRepository.EXPECT().GetProfileByID(1).Return(profile, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository, ValidateResponses: true})
 
-		mockServer := &Server{Repository: mockRepository}
+		handler := func(ctx echo.Context) error {
+			return mockServer.PatchProfile(ctx, generated.PatchProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
 
-		if assert.NoError(t, mockServer.PutProfile(context)) {
+	t.Run("Invalid Attributes", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{
+			"email": "Bakri <bakri@example.com>",
+			"gender": "unknown",
+			"locale": "not a locale",
+			"timezone": "Asia/Atlantis"
+		}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PatchProfile(ctx, generated.PatchProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			rules := map[string]string{}
+			for _, fieldError := range resp.Errors {
+				rules[fieldError.Field] = fieldError.Rule
+			}
+			assert.Equal(t, map[string]string{"email": "email", "gender": "enum", "locale": "locale", "timezone": "timezone"}, rules)
+		}
+	})
+
+	t.Run("Date Of Birth In The Future", func(t *testing.T) {
+		tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"date_of_birth": "`+tomorrow+`"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "date_of_birth", resp.Errors[0].Field)
+				assert.Equal(t, "pastDate", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Null Required Field", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"full_name": null}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
 			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "full_name", resp.Errors[0].Field)
+				assert.Equal(t, "required", resp.Errors[0].Rule)
+			}
 		}
 	})
 
+	t.Run("Invalid Value", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, mergePatchContentType, `{"full_name": "Bo"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		handler := func(ctx echo.Context) error {
+			return mockServer.PatchProfile(ctx, generated.PatchProfileParams{})
+		}
+		if assert.NoError(t, mockServer.RequestValidator()(handler)(context)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			var resp generated.ValidationErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			if assert.Len(t, resp.Errors, 1) {
+				assert.Equal(t, "minLength", resp.Errors[0].Rule)
+			}
+		}
+	})
+
+	t.Run("Not A Merge Patch", func(t *testing.T) {
+		context, rec, mockRepository := setupTestPatchProfile(t, token, echo.MIMEApplicationJSON, `{"full_name": "Mr Bill Brod"}`)
+
+		mockRepository.EXPECT().TouchSession(profile.ID, uint64(1)).Return(true, nil).Times(1)
+		mockServer := NewServer(NewServerOptions{Repository: mockRepository})
+
+		if assert.NoError(t, mockServer.PatchProfile(context, generated.PatchProfileParams{})) {
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		}
+	})
 }
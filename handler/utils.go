@@ -1,41 +1,191 @@
 package handler
 
 import (
+	"crypto/rsa"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/hasbiasshidiq/simple-profile/rbac"
 	"github.com/hasbiasshidiq/simple-profile/repository"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func extractUserIDFromToken(token string) (profileID int, err error) {
+// tokenTypeMFAChallenge marks the tokens which only allow completing the login with a second factor
+const tokenTypeMFAChallenge = "mfa_challenge"
+
+// tokenTypeDataExport marks the tokens of the download links of the data exports
+const tokenTypeDataExport = "data_export"
+
+// tokenTypeEmailVerification marks the tokens of the verification links of the emails
+const tokenTypeEmailVerification = "email_verification"
+
+// mfaChallengeLifetime is how long the user has to enter the authenticator code after the password
+const mfaChallengeLifetime = 5 * time.Minute
+
+// accessTokenLifetime is how long an access token, and the session it belongs to, is valid
+const accessTokenLifetime = time.Hour
+
+// errProfileSuspended refuses the access tokens of a suspended profile, see tokenErrorResponse
+var errProfileSuspended = errors.New("profile is suspended")
+
+// errPermissionDenied refuses the access tokens missing the permission of an operation, see
+// extractOperatorIDFromToken
+var errPermissionDenied = errors.New("permission denied")
+
+// errSessionCheckFailed is returned for the access tokens whose session couldn't be checked, e.g. the database is
+// unavailable, they aren't refused but answered with a server error
+var errSessionCheckFailed = errors.New("unable to check the session")
+
+// tokenErrorResponse answers a request whose access token is refused by extractUserIDFromToken
+func tokenErrorResponse(ctx echo.Context, err error) error {
+	if errors.Is(err, errSessionCheckFailed) {
+		return err
+	}
+
+	code := msgInvalidToken
+	switch {
+	case errors.Is(err, errProfileSuspended):
+		code = msgProfileSuspended
+	case errors.Is(err, errPermissionDenied):
+		code = msgPermissionDenied
+	}
+	responsePayload := errorResponse(ctx, code)
+	return ctx.JSON(http.StatusForbidden, responsePayload)
+}
+
+// extractUserIDFromToken accepts the access tokens of active sessions only, the last seen time of the session is
+// updated as it's used
+func (s *Server) extractUserIDFromToken(token string) (profileID int, err error) {
+	profileID, _, err = s.extractSessionFromToken(token)
+	return profileID, err
+}
+
+// extractOperatorIDFromToken is extractUserIDFromToken for the operations of the operators, the token must grant
+// permission. The request middleware checks the permissions listed by the spec too, but a handler doesn't depend
+// on how it's routed.
+func (s *Server) extractOperatorIDFromToken(token string, permission string) (operatorID int, err error) {
+	operatorID, err = s.extractUserIDFromToken(token)
+	if err != nil {
+		return operatorID, err
+	}
+
+	scopes, err := parseAccessTokenScopes(token)
+	if err != nil {
+		return operatorID, err
+	}
+	if missing := rbac.Missing(scopes, []string{permission}); len(missing) > 0 {
+		return operatorID, errPermissionDenied
+	}
+
+	return operatorID, nil
+}
+
+// extractSessionFromToken is extractUserIDFromToken returning the session of the token as well
+func (s *Server) extractSessionFromToken(token string) (profileID int, sessionID uint64, err error) {
+	profileID, sessionID, err = parseAccessToken(token)
+	if err != nil {
+		return profileID, sessionID, err
+	}
+
+	active, err := s.Repository.TouchSession(uint64(profileID), sessionID)
+	if err != nil {
+		log.Println("error touch session : ", err)
+		return profileID, sessionID, fmt.Errorf("%w: %v", errSessionCheckFailed, err)
+	}
+	if !active {
+		// the sessions of a suspended profile are revoked, its users are told why
+		profile, err := s.Repository.GetProfileByID(profileID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Println("error fetch profile : ", err)
+			return profileID, sessionID, fmt.Errorf("%w: %v", errSessionCheckFailed, err)
+		}
+		if err == nil && profile.Status == repository.ProfileStatusSuspended {
+			return profileID, sessionID, errProfileSuspended
+		}
+		return profileID, sessionID, errors.New("session is revoked or expired")
+	}
+
+	return profileID, sessionID, nil
+}
+
+// parseAccessToken validates an access token without checking whether its session is still active
+func parseAccessToken(token string) (profileID int, sessionID uint64, err error) {
+	claims, profileID, err := parseTokenOfType(token, "")
+	if err != nil {
+		return profileID, sessionID, err
+	}
+
+	sid, ok := claims["sid"].(float64)
+	if !ok {
+		return profileID, sessionID, errors.New("unable to extract session ID from token")
+	}
+
+	return profileID, uint64(sid), nil
+}
+
+// parseAccessTokenScopes validates an access token and returns the permissions granted by the roles of the user
+func parseAccessTokenScopes(token string) (scopes []string, err error) {
+	claims, _, err := parseTokenOfType(token, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// scope is a space separated list, as in OAuth 2.0
+	scope, _ := claims["scope"].(string)
+	return strings.Fields(scope), nil
+}
+
+// extractChallengeFromToken accepts the MFA challenge tokens returned by PostLogin only
+func extractChallengeFromToken(token string) (profileID int, challengeID uint64, err error) {
+	claims, profileID, err := parseTokenOfType(token, tokenTypeMFAChallenge)
+	if err != nil {
+		return profileID, challengeID, err
+	}
+
+	cid, ok := claims["cid"].(float64)
+	if !ok {
+		return profileID, challengeID, errors.New("unable to extract challenge ID from token")
+	}
+
+	return profileID, uint64(cid), nil
+}
+
+// parseTokenOfType validates token and checks its typ claim, access tokens have none
+func parseTokenOfType(token string, tokenType string) (claims jwt.MapClaims, profileID int, err error) {
 
 	// read public key from .key.pub file
 	pubKey, err := ioutil.ReadFile("cert/jwtRS256.key.pub")
 	if err != nil {
 		log.Println("Can't open public key ", err)
-		return profileID, err
+		return nil, profileID, err
 	}
 
 	// validate token based on public key and extract claims
 	claims, validated := validateToken(pubKey, token)
 	if !validated {
-		return profileID, errors.New("not valid token")
+		return nil, profileID, errors.New("not valid token")
+	}
+
+	typ, _ := claims["typ"].(string)
+	if typ != tokenType {
+		return nil, profileID, errors.New("unexpected token type")
 	}
 
 	// get subject
 	sub, ok := claims["sub"].(float64)
 	if !ok {
-		return profileID, errors.New("unable to extract user ID from token")
+		return nil, profileID, errors.New("unable to extract user ID from token")
 	}
 
-	return int(sub), nil
+	return claims, int(sub), nil
 }
 
 func extractToken(c echo.Context) (token string, err error) {
@@ -84,47 +234,70 @@ func validateToken(publicKey []byte, token string) (claims jwt.MapClaims, valida
 	return claims, true
 }
 
-func hashAndSalt(pwd []byte) string {
-
-	hash, err := bcrypt.GenerateFromPassword(pwd, bcrypt.MinCost)
-	if err != nil {
-		log.Println("can't generate hash : ", err)
-	}
-	return string(hash)
+// createToken creates the access token of the given session, granting the permissions of the roles of the user
+func createToken(profile repository.Profile, sessionID uint64, roles []string, scopes []string) (tokenString string, err error) {
+	return signToken(jwt.MapClaims{
+		"sub":   profile.ID,
+		"sid":   sessionID,
+		"roles": roles,
+		"scope": strings.Join(scopes, " "),
+		"exp":   time.Now().Add(accessTokenLifetime).Unix(),
+		"iat":   time.Now().Unix(),
+	})
 }
 
-func comparePasswords(hashedPwd string, plainPwd []byte) bool {
-	// Since we'll be getting the hashed password from the DB it
-	// will be a string so we'll need to convert it to a byte slice
-	byteHash := []byte(hashedPwd)
-	err := bcrypt.CompareHashAndPassword(byteHash, plainPwd)
-
-	return err == nil
+// createMFAChallengeToken creates the short-lived token exchanged for an access token with a second factor
+func createMFAChallengeToken(profile repository.Profile, challengeID uint64) (tokenString string, err error) {
+	return signToken(jwt.MapClaims{
+		"sub": profile.ID,
+		"cid": challengeID,
+		"typ": tokenTypeMFAChallenge,
+		"exp": time.Now().Add(mfaChallengeLifetime).Unix(),
+		"iat": time.Now().Unix(),
+	})
 }
 
-func createToken(profile repository.Profile) (tokenString string, err error) {
+// createDataExportToken creates the token of the download link of an export, it expires with the export
+func createDataExportToken(profileID uint64, exportID uint64, expiresAt time.Time) (tokenString string, err error) {
+	return signToken(jwt.MapClaims{
+		"sub": profileID,
+		"eid": exportID,
+		"typ": tokenTypeDataExport,
+		"exp": expiresAt.Unix(),
+		"iat": time.Now().Unix(),
+	})
+}
 
-	prvKey, err := ioutil.ReadFile("cert/jwtRS256.key")
-	if err != nil {
-		return tokenString, err
-	}
+// createEmailVerificationToken creates the token of the verification link of the email of a profile
+func createEmailVerificationToken(profileID uint64, email string, expiresAt time.Time) (tokenString string, err error) {
+	return signToken(jwt.MapClaims{
+		"sub":   profileID,
+		"email": email,
+		"typ":   tokenTypeEmailVerification,
+		"exp":   expiresAt.Unix(),
+		"iat":   time.Now().Unix(),
+	})
+}
 
-	parsedKey, err := jwt.ParseRSAPrivateKeyFromPEM(prvKey)
-	if err != nil {
-		return tokenString, err
-	}
+var (
+	signingKeyOnce sync.Once
+	signingKey     *rsa.PrivateKey
+	signingKeyErr  error
+)
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"sub": profile.ID,
-		"exp": time.Now().Add(time.Hour * 1).Unix(),
-		"iat": time.Now().Unix(),
+// signToken signs the claims with RS256, the private key is read from cert/jwtRS256.key by the first call only
+func signToken(claims jwt.MapClaims) (string, error) {
+	signingKeyOnce.Do(func() {
+		prvKey, err := ioutil.ReadFile("cert/jwtRS256.key")
+		if err != nil {
+			signingKeyErr = err
+			return
+		}
+		signingKey, signingKeyErr = jwt.ParseRSAPrivateKeyFromPEM(prvKey)
 	})
-
-	// Sign the token with the secret key
-	tokenString, err = token.SignedString(parsedKey)
-	if err != nil {
-		return tokenString, err
+	if signingKeyErr != nil {
+		return "", signingKeyErr
 	}
-	return tokenString, err
+
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(signingKey)
 }
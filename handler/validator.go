@@ -1,59 +1,267 @@
 package handler
 
 import (
-	"net/http"
-	"regexp"
-	"unicode"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/go-playground/validator"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/hasbiasshidiq/simple-profile/generated"
+	"github.com/hasbiasshidiq/simple-profile/password"
+	"github.com/hasbiasshidiq/simple-profile/phone"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
 )
 
 type (
 	CustomValidator struct {
-		validator *validator.Validate
+		swagger *openapi3.T
+		router  routers.Router
 	}
 )
 
-func (cv *CustomValidator) Validate(i interface{}) error {
-	if err := cv.validator.Struct(i); err != nil {
-		// Optionally, you could return the error to give each route more control over the status code
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+// ValidationError holds every field that failed validation, named after its JSON field
+type ValidationError struct {
+	Fields []FieldValidationError
+}
+
+// FieldValidationError describes a single failed rule on a field
+type FieldValidationError struct {
+	Field  string
+	Rule   string
+	Params []string
+}
+
+// minDateOfBirth is the earliest date of birth accepted
+var minDateOfBirth = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func init() {
+	// the formats of api.yml which aren't built into the OpenAPI validation, a failure is reported with the
+	// format as its rule
+	openapi3.DefineStringFormatCallback("email", validateEmail)
+	openapi3.DefineStringFormatCallback("locale", validateLocale)
+	openapi3.DefineStringFormatCallback("timezone", validateTimezone)
+}
+
+// validateEmail accepts a bare address with a domain name, e.g. name@example.com, without a display name
+func validateEmail(value string) error {
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return err
+	}
+	if address.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+		return errors.New("not a bare email address")
 	}
 	return nil
 }
 
-// validatePhoneWithPrefix is a custom validation function for phone numbers with prefix "+62"
-func validatePhoneWithPrefix(fl validator.FieldLevel) bool {
+// validateLocale accepts the well-formed BCP 47 language tags, e.g. id or id-ID
+func validateLocale(value string) error {
+	_, err := language.Parse(value)
+	return err
+}
 
-	phoneNumber := fl.Field().String()
+// validateTimezone accepts the names of the IANA time zone database, e.g. Asia/Jakarta
+func validateTimezone(value string) error {
+	if value == "" || value == "Local" {
+		return errors.New("not an IANA time zone")
+	}
+	_, err := time.LoadLocation(value)
+	return err
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s failed on the %s rule", field.Field, field.Rule))
+	}
+	return strings.Join(messages, "; ")
+}
 
-	// Use a regular expression to check if the phone number starts with "+62"
-	match, _ := regexp.MatchString(`^\+62[0-9]+$`, phoneNumber)
+// NewCustomValidator builds the validator shared by every handler from the embedded OpenAPI spec,
+// which is the single source of truth for the validation rules
+func NewCustomValidator(swagger *openapi3.T) (*CustomValidator, error) {
+	// the servers of api.yml describe where the spec is deployed, requests are matched by path only
+	swagger.Servers = nil
 
-	return match
+	router, err := legacy.NewRouter(swagger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CustomValidator{swagger: swagger, router: router}, nil
 }
 
-// validateStrongPassword is a custom validation function for strong passwords
-func validateStrongPassword(fl validator.FieldLevel) bool {
+// toValidationError flattens the errors reported by the OpenAPI validation into per-field failures.
+// It reports false when err is not caused by the value of a field, e.g. an undecodable body.
+func toValidationError(err error) (*ValidationError, bool) {
+	validationError := &ValidationError{}
+	if !validationError.collect(err, "") {
+		return nil, false
+	}
+	return validationError, true
+}
+
+func (e *ValidationError) collect(err error, field string) bool {
+	switch err := err.(type) {
+	case openapi3.MultiError:
+		for _, item := range err {
+			if !e.collect(item, field) {
+				return false
+			}
+		}
+		return true
+
+	case *openapi3filter.RequestError:
+		if err.Parameter != nil {
+			field = err.Parameter.Name
+			if errors.Is(err.Err, openapi3filter.ErrInvalidRequired) {
+				e.Fields = append(e.Fields, FieldValidationError{Field: field, Rule: "required", Params: []string{}})
+				return true
+			}
+		}
+		if err.Err == nil {
+			return false
+		}
+		return e.collect(err.Err, field)
+
+	case *openapi3.SchemaError:
+		e.Fields = append(e.Fields, schemaFieldError(err, field))
+		return true
+	}
+
+	return false
+}
 
-	password := fl.Field().String()
+func schemaFieldError(err *openapi3.SchemaError, prefix string) FieldValidationError {
+	path := err.JSONPointer()
+	if prefix != "" {
+		path = append([]string{prefix}, path...)
+	}
 
-	// Check if the password contains at least 1 uppercase letter, 1 number, and 1 special character
-	hasUppercase := false
-	hasNumber := false
-	hasSpecial := false
+	fieldError := FieldValidationError{
+		Field:  strings.Join(path, "."),
+		Rule:   err.SchemaField,
+		Params: []string{},
+	}
 
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUppercase = true
-		case unicode.IsNumber(char):
-			hasNumber = true
-		case !unicode.IsLetter(char) && !unicode.IsNumber(char):
-			hasSpecial = true
+	schema := err.Schema
+	switch err.SchemaField {
+	case "minLength":
+		fieldError.Params = []string{strconv.FormatUint(schema.MinLength, 10)}
+	case "maxLength":
+		if schema.MaxLength != nil {
+			fieldError.Params = []string{strconv.FormatUint(*schema.MaxLength, 10)}
 		}
+	case "minProperties":
+		fieldError.Params = []string{strconv.FormatUint(schema.MinProps, 10)}
+	case "pattern":
+		fieldError.Params = []string{schema.Pattern}
+	case "enum":
+		values := make([]string, 0, len(schema.Enum))
+		for _, value := range schema.Enum {
+			values = append(values, fmt.Sprint(value))
+		}
+		fieldError.Params = []string{strings.Join(values, ", ")}
+	case "format":
+		// report the format itself, e.g. date-time, as the failed rule
+		fieldError.Rule = schema.Format
+	}
+
+	return fieldError
+}
+
+// validationErrorResponse converts a validation error into the 400 response body
+// with messages in the request language
+func validationErrorResponse(ctx echo.Context, err error) generated.ValidationErrorResponse {
+	resp := generated.ValidationErrorResponse{
+		Code:    string(msgValidationFailed),
+		Message: localize(ctx, msgValidationFailed),
+		Errors:  []generated.ValidationErrorDetail{},
 	}
 
-	return hasUppercase && hasNumber && hasSpecial
+	validationError, ok := err.(*ValidationError)
+	if !ok {
+		// the error may describe the internals of the server, the client only gets the generic message
+		log.Println("error validate request : ", err)
+		return resp
+	}
+
+	for _, field := range validationError.Fields {
+		resp.Errors = append(resp.Errors, generated.ValidationErrorDetail{
+			Field:   field.Field,
+			Rule:    field.Rule,
+			Message: validationMessage(ctx, field),
+			Params:  append([]string{}, field.Params...),
+		})
+	}
+	return resp
+}
+
+// validationMessage describes a failed rule in a sentence the client can show next to the field,
+// the message is formatted with the field name followed by the rule parameters
+func validationMessage(ctx echo.Context, field FieldValidationError) string {
+	return validationMessageIn(requestLanguage(ctx), field)
+}
+
+// validationMessageIn is validationMessage in lang
+func validationMessageIn(lang string, field FieldValidationError) string {
+	args := []interface{}{field.Field}
+	for _, param := range field.Params {
+		args = append(args, param)
+	}
+
+	code := messageCode("validation_" + field.Rule)
+	if _, ok := messageCatalogue[supportedLanguages[0]][code]; !ok {
+		code = msgValidationDefault
+	}
+	return localizeIn(lang, code, args...)
+}
+
+// phoneNumberError reports a phone number which can't be parsed as a validation failure of field
+func phoneNumberError(field string, err error) error {
+	parseError, ok := err.(*phone.ParseError)
+	if !ok {
+		return err
+	}
+
+	return &ValidationError{Fields: []FieldValidationError{{
+		Field:  field,
+		Rule:   parseError.Rule,
+		Params: parseError.Params,
+	}}}
+}
+
+// dateOfBirthError reports a date of birth in the future or before minDateOfBirth as a validation failure of field
+func dateOfBirthError(field string, dateOfBirth time.Time) error {
+	var rule FieldValidationError
+	switch {
+	case dateOfBirth.After(time.Now()):
+		rule = FieldValidationError{Field: field, Rule: "pastDate", Params: []string{}}
+	case dateOfBirth.Before(minDateOfBirth):
+		rule = FieldValidationError{Field: field, Rule: "minDate", Params: []string{minDateOfBirth.Format("2006-01-02")}}
+	default:
+		return nil
+	}
+	return &ValidationError{Fields: []FieldValidationError{rule}}
+}
+
+// passwordPolicyError reports the password policy violations of field as validation failures
+func passwordPolicyError(field string, violations []*password.Violation) error {
+	validationError := &ValidationError{}
+	for _, violation := range violations {
+		validationError.Fields = append(validationError.Fields, FieldValidationError{
+			Field:  field,
+			Rule:   violation.Rule,
+			Params: violation.Params,
+		})
+	}
+	return validationError
 }
@@ -0,0 +1,106 @@
+package password
+
+import (
+	"bufio"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+)
+
+// BloomFilter is a compact set of strings which may report false positives but never false negatives,
+// it keeps a breached-password list of millions of entries in a few megabytes
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+// NewBloomFilter sizes a filter for capacity items with the given false positive rate, e.g. 0.001
+func NewBloomFilter(capacity int, falsePositiveRate float64) *BloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	// optimal number of bits and hash functions, see https://en.wikipedia.org/wiki/Bloom_filter
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/float64(capacity)*math.Ln2)))
+
+	return &BloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// Add adds value to the filter
+func (f *BloomFilter) Add(value string) {
+	h1, h2 := bloomHashes(value)
+	for i := uint64(0); i < f.hashes; i++ {
+		position := (h1 + i*h2) % f.size
+		f.bits[position/64] |= 1 << (position % 64)
+	}
+}
+
+// Contains reports whether value has probably been added to the filter
+func (f *BloomFilter) Contains(value string) bool {
+	h1, h2 := bloomHashes(value)
+	for i := uint64(0); i < f.hashes; i++ {
+		position := (h1 + i*h2) % f.size
+		if f.bits[position/64]&(1<<(position%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives the hash functions of the filter from two FNV hashes (double hashing)
+func bloomHashes(value string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(value))
+	h2 := fnv.New64()
+	h2.Write([]byte(value))
+
+	// an odd second hash visits every position when the size is a power of two
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+// LoadBreachedList reads a file with one breached password per line into a bloom filter,
+// empty lines and lines starting with # are skipped
+func LoadBreachedList(path string, falsePositiveRate float64) (*BloomFilter, error) {
+	passwords, err := readBreachedList(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := NewBloomFilter(passwords, falsePositiveRate)
+	_, err = readBreachedList(path, filter.Add)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// readBreachedList counts the passwords of the file and passes each of them to add when it's not nil,
+// the file is read twice so that the list is never held in memory
+func readBreachedList(path string, add func(password string)) (count int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		count++
+		if add != nil {
+			add(line)
+		}
+	}
+	return count, scanner.Err()
+}
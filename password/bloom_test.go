@@ -0,0 +1,47 @@
+package password
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := NewBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		filter.Add(fmt.Sprintf("breached-%d", i))
+	}
+
+	// a bloom filter never reports false negatives
+	for i := 0; i < 10000; i++ {
+		assert.True(t, filter.Contains(fmt.Sprintf("breached-%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.Contains(fmt.Sprintf("safe-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300, "false positive rate should stay close to 1%%")
+}
+
+func TestLoadBreachedList(t *testing.T) {
+	t.Run("Missing File", func(t *testing.T) {
+		_, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt"), 0.001)
+		assert.Error(t, err)
+	})
+
+	t.Run("Empty File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.txt")
+		assert.NoError(t, os.WriteFile(path, nil, 0o600))
+
+		filter, err := LoadBreachedList(path, 0.001)
+		if assert.NoError(t, err) {
+			assert.False(t, filter.Contains("1n19s9H88@"))
+		}
+	})
+}
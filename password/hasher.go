@@ -0,0 +1,136 @@
+// Package password hashes and verifies passwords according to a configurable policy.
+//
+// Hashes are encoded in the PHC string format, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// bcrypt hashes keep their standard $2a$<cost>$ encoding, so hashes created before argon2id
+// was introduced can still be verified and are upgraded on the next successful login.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	// ErrUnknownAlgorithm is returned for a hash or policy using an algorithm which is not supported
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+	// ErrInvalidHash is returned for a hash which can't be decoded
+	ErrInvalidHash = errors.New("invalid password hash")
+)
+
+// Policy is the algorithm and the parameters used to hash new passwords
+type Policy struct {
+	Algorithm  string
+	Argon2id   Argon2idParams
+	BcryptCost int
+}
+
+// DefaultPolicy follows the OWASP recommendation of argon2id with 64 MiB of memory
+var DefaultPolicy = Policy{
+	Algorithm: AlgorithmArgon2id,
+	Argon2id: Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+	BcryptCost: 12,
+}
+
+// algorithm is implemented by every supported hashing algorithm
+type algorithm interface {
+	hash(password string) (string, error)
+	verify(password string, encoded string) (bool, error)
+	// outdated reports whether encoded was created with other parameters than the algorithm's
+	outdated(encoded string) bool
+}
+
+// Hasher hashes passwords with the algorithm of its policy and verifies hashes of every supported algorithm
+type Hasher struct {
+	policy     Policy
+	current    algorithm
+	algorithms map[string]algorithm
+}
+
+// NewHasher creates a hasher for policy, the zero values of the policy are taken from DefaultPolicy
+func NewHasher(policy Policy) (*Hasher, error) {
+	if policy.Algorithm == "" {
+		policy.Algorithm = DefaultPolicy.Algorithm
+	}
+	if policy.Argon2id == (Argon2idParams{}) {
+		policy.Argon2id = DefaultPolicy.Argon2id
+	}
+	if policy.BcryptCost == 0 {
+		policy.BcryptCost = DefaultPolicy.BcryptCost
+	}
+
+	argon2id, err := newArgon2id(policy.Argon2id)
+	if err != nil {
+		return nil, err
+	}
+	bcrypt, err := newBcrypt(policy.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := map[string]algorithm{
+		AlgorithmArgon2id: argon2id,
+		AlgorithmBcrypt:   bcrypt,
+	}
+	current, ok := algorithms[policy.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, policy.Algorithm)
+	}
+
+	return &Hasher{policy: policy, current: current, algorithms: algorithms}, nil
+}
+
+// Policy returns the policy used to hash new passwords
+func (h *Hasher) Policy() Policy {
+	return h.policy
+}
+
+// Hash hashes password according to the policy
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.current.hash(password)
+}
+
+// Verify reports whether password matches encoded, whatever algorithm encoded was created with
+func (h *Hasher) Verify(password string, encoded string) (bool, error) {
+	algorithm, err := h.algorithmOf(encoded)
+	if err != nil {
+		return false, err
+	}
+	return algorithm.verify(password, encoded)
+}
+
+// NeedsRehash reports whether encoded was created with another algorithm or other parameters than the policy,
+// it should be replaced by a new hash once the password is known, i.e. after a successful login
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	algorithm, err := h.algorithmOf(encoded)
+	if err != nil {
+		return true
+	}
+	return algorithm != h.current || algorithm.outdated(encoded)
+}
+
+func (h *Hasher) algorithmOf(encoded string) (algorithm, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.algorithms[AlgorithmArgon2id], nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return h.algorithms[AlgorithmBcrypt], nil
+	case encoded == "":
+		return nil, ErrInvalidHash
+	}
+	return nil, ErrUnknownAlgorithm
+}
@@ -0,0 +1,159 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the tests fast, production uses DefaultPolicy
+var testArgon2id = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		prefix string
+	}{
+		{name: "Argon2id", policy: Policy{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2id}, prefix: "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{name: "Bcrypt", policy: Policy{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, prefix: "$2a$04$"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasher, err := NewHasher(test.policy)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			encoded, err := hasher.Hash("1n19s9H88@")
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, strings.HasPrefix(encoded, test.prefix), encoded)
+
+			ok, err := hasher.Verify("1n19s9H88@", encoded)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = hasher.Verify("1n19s9H88!", encoded)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, hasher.NeedsRehash(encoded))
+		})
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	hasher, err := NewHasher(Policy{Argon2id: testArgon2id})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		encoded string
+		err     error
+	}{
+		{name: "Empty", encoded: "", err: ErrInvalidHash},
+		{name: "Unknown Algorithm", encoded: "$scrypt$ln=15,r=8,p=1$c2FsdA$aGFzaA", err: ErrUnknownAlgorithm},
+		{name: "Missing Parts", encoded: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA", err: ErrInvalidHash},
+		{name: "Wrong Version", encoded: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "Broken Base64", encoded: "$argon2id$v=19$m=1024,t=1,p=1$!!$aGFzaA", err: ErrInvalidHash},
+		{name: "No Iterations", encoded: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "No Threads", encoded: "$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "Too Many Threads", encoded: "$argon2id$v=19$m=1024,t=1,p=256$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "Too Little Memory", encoded: "$argon2id$v=19$m=4,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "Too Much Memory", encoded: "$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "Too Many Iterations", encoded: "$argon2id$v=19$m=1024,t=4294967295,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA", err: ErrInvalidHash},
+		{name: "Empty Key", encoded: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$", err: ErrInvalidHash},
+		{name: "Broken Bcrypt", encoded: "$2a$04$short", err: ErrInvalidHash},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := hasher.Verify("1n19s9H88@", test.encoded)
+			assert.ErrorIs(t, err, test.err)
+			assert.False(t, ok)
+			assert.True(t, hasher.NeedsRehash(test.encoded))
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	oldBcrypt, _ := NewHasher(Policy{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	bcryptHash, _ := oldBcrypt.Hash("1n19s9H88@")
+
+	weakArgon2id, _ := NewHasher(Policy{Argon2id: Argon2idParams{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}})
+	weakArgon2idHash, _ := weakArgon2id.Hash("1n19s9H88@")
+
+	argon2idHasher, err := NewHasher(Policy{Argon2id: testArgon2id})
+	assert.NoError(t, err)
+	argon2idHash, _ := argon2idHasher.Hash("1n19s9H88@")
+
+	strongerBcrypt, err := NewHasher(Policy{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1, Argon2id: testArgon2id})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		hasher   *Hasher
+		encoded  string
+		expected bool
+	}{
+		{name: "Bcrypt To Argon2id", hasher: argon2idHasher, encoded: bcryptHash, expected: true},
+		{name: "Argon2id Parameters Changed", hasher: argon2idHasher, encoded: weakArgon2idHash, expected: true},
+		{name: "Argon2id Up To Date", hasher: argon2idHasher, encoded: argon2idHash, expected: false},
+		{name: "Bcrypt Cost Changed", hasher: strongerBcrypt, encoded: bcryptHash, expected: true},
+		{name: "Argon2id To Bcrypt", hasher: strongerBcrypt, encoded: argon2idHash, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.hasher.NeedsRehash(test.encoded))
+
+			// the outdated hash still verifies, so the password can be rehashed after the login
+			ok, err := test.hasher.Verify("1n19s9H88@", test.encoded)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		hasher, err := NewHasher(Policy{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, DefaultPolicy, hasher.Policy())
+		}
+	})
+
+	t.Run("Unknown Algorithm", func(t *testing.T) {
+		_, err := NewHasher(Policy{Algorithm: "md5"})
+		assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+	})
+
+	t.Run("Bcrypt Cost Out Of Range", func(t *testing.T) {
+		_, err := NewHasher(Policy{Algorithm: AlgorithmBcrypt, BcryptCost: 40})
+		assert.Error(t, err)
+	})
+
+	t.Run("Argon2id Without Iterations", func(t *testing.T) {
+		_, err := NewHasher(Policy{Argon2id: Argon2idParams{Memory: 1024, Parallelism: 1, SaltLength: 16, KeyLength: 32}})
+		assert.Error(t, err)
+	})
+}
+
+func TestIsBcryptHash(t *testing.T) {
+	bcryptHasher, _ := NewHasher(Policy{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	bcryptHash, _ := bcryptHasher.Hash("1n19s9H88@")
+	argon2idHasher, _ := NewHasher(Policy{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2id})
+	argon2idHash, _ := argon2idHasher.Hash("1n19s9H88@")
+
+	assert.True(t, IsBcryptHash(bcryptHash))
+	assert.True(t, IsBcryptHash("$2y$"+bcryptHash[4:]))
+	assert.False(t, IsBcryptHash(argon2idHash))
+	assert.False(t, IsBcryptHash(bcryptHash[:59]))
+	assert.False(t, IsBcryptHash("$2a$99$"+bcryptHash[7:]))
+	assert.False(t, IsBcryptHash("1n19s9H88@"))
+}
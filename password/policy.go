@@ -0,0 +1,218 @@
+package password
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule names reported by the password policy, they follow the validation error response
+const (
+	RuleMinLength    = "minLength"
+	RuleMaxLength    = "maxLength"
+	RuleUppercase    = "passwordUppercase"
+	RuleLowercase    = "passwordLowercase"
+	RuleNumber       = "passwordNumber"
+	RuleSpecial      = "passwordSpecial"
+	RuleBreached     = "passwordBreached"
+	RulePersonalInfo = "passwordPersonalInfo"
+	RuleReused       = "passwordReused"
+)
+
+// Candidate is a password being set together with what is known about its owner
+type Candidate struct {
+	Password string
+	FullName string
+	// PhoneNumbers are the ways the owner's phone number is written, e.g. +628123456789 and 8123456789
+	PhoneNumbers []string
+	// PreviousHashes are the hashes of the current and the previous passwords, newest first
+	PreviousHashes []string
+}
+
+// Violation describes a rule the candidate password doesn't satisfy
+type Violation struct {
+	Rule   string
+	Params []string
+	reason string
+}
+
+func (v *Violation) Error() string {
+	return v.reason
+}
+
+// Rule is a single requirement of the password policy, it returns nil when the candidate satisfies it
+type Rule interface {
+	Check(candidate Candidate) *Violation
+}
+
+// RuleFunc adapts a function to a Rule
+type RuleFunc func(candidate Candidate) *Violation
+
+func (f RuleFunc) Check(candidate Candidate) *Violation {
+	return f(candidate)
+}
+
+// Checker applies the rules of a deployment's password policy
+type Checker struct {
+	rules []Rule
+}
+
+// NewChecker creates a checker applying rules in order
+func NewChecker(rules ...Rule) *Checker {
+	return &Checker{rules: rules}
+}
+
+// Check returns every rule violated by the candidate, none when the password is acceptable
+func (c *Checker) Check(candidate Candidate) []*Violation {
+	violations := []*Violation{}
+	for _, rule := range c.rules {
+		if violation := rule.Check(candidate); violation != nil {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// DefaultRules are the rules used when a deployment doesn't configure its own
+func DefaultRules() []Rule {
+	rules := []Rule{MinLength(6), MaxLength(64)}
+	rules = append(rules, RequireClasses(ClassUppercase, ClassNumber, ClassSpecial)...)
+	return append(rules, NoPersonalInfo())
+}
+
+// MinLength rejects passwords with less than length characters
+func MinLength(length int) Rule {
+	return RuleFunc(func(candidate Candidate) *Violation {
+		if len([]rune(candidate.Password)) >= length {
+			return nil
+		}
+		return &Violation{
+			Rule:   RuleMinLength,
+			Params: []string{strconv.Itoa(length)},
+			reason: "password must be at least " + strconv.Itoa(length) + " characters",
+		}
+	})
+}
+
+// MaxLength rejects passwords with more than length characters
+func MaxLength(length int) Rule {
+	return RuleFunc(func(candidate Candidate) *Violation {
+		if len([]rune(candidate.Password)) <= length {
+			return nil
+		}
+		return &Violation{
+			Rule:   RuleMaxLength,
+			Params: []string{strconv.Itoa(length)},
+			reason: "password must be at most " + strconv.Itoa(length) + " characters",
+		}
+	})
+}
+
+// CharacterClass is a kind of character a password may be required to contain
+type CharacterClass string
+
+const (
+	ClassUppercase CharacterClass = "uppercase"
+	ClassLowercase CharacterClass = "lowercase"
+	ClassNumber    CharacterClass = "number"
+	ClassSpecial   CharacterClass = "special"
+)
+
+var characterClasses = map[CharacterClass]struct {
+	rule     string
+	contains func(char rune) bool
+}{
+	ClassUppercase: {RuleUppercase, unicode.IsUpper},
+	ClassLowercase: {RuleLowercase, unicode.IsLower},
+	ClassNumber:    {RuleNumber, unicode.IsNumber},
+	ClassSpecial:   {RuleSpecial, func(char rune) bool { return !unicode.IsLetter(char) && !unicode.IsNumber(char) }},
+}
+
+// ParseCharacterClass returns the class named name, e.g. uppercase
+func ParseCharacterClass(name string) (CharacterClass, bool) {
+	class := CharacterClass(strings.ToLower(strings.TrimSpace(name)))
+	_, ok := characterClasses[class]
+	return class, ok
+}
+
+// RequireClass requires at least one character of class
+func RequireClass(class CharacterClass) Rule {
+	definition := characterClasses[class]
+	return RuleFunc(func(candidate Candidate) *Violation {
+		if definition.contains == nil || strings.IndexFunc(candidate.Password, definition.contains) >= 0 {
+			return nil
+		}
+		return &Violation{
+			Rule:   definition.rule,
+			Params: []string{},
+			reason: "password must contain a " + string(class) + " character",
+		}
+	})
+}
+
+// RequireClasses requires at least one character of each class, every missing class is reported by its own rule
+func RequireClasses(classes ...CharacterClass) []Rule {
+	rules := make([]Rule, 0, len(classes))
+	for _, class := range classes {
+		rules = append(rules, RequireClass(class))
+	}
+	return rules
+}
+
+// NotBreached rejects the passwords of a breached-password list, see LoadBreachedList
+func NotBreached(breached *BloomFilter) Rule {
+	return RuleFunc(func(candidate Candidate) *Violation {
+		if !breached.Contains(candidate.Password) {
+			return nil
+		}
+		return &Violation{
+			Rule:   RuleBreached,
+			Params: []string{},
+			reason: "password has appeared in a data breach",
+		}
+	})
+}
+
+// minPersonalInfoLength ignores short name parts, e.g. "Al", which are common in passwords by chance
+const minPersonalInfoLength = 3
+
+// NoPersonalInfo rejects passwords containing a part of the owner's name or the owner's phone number
+func NoPersonalInfo() Rule {
+	return RuleFunc(func(candidate Candidate) *Violation {
+		password := strings.ToLower(candidate.Password)
+
+		personalInfo := strings.Fields(strings.ToLower(candidate.FullName))
+		for _, phoneNumber := range candidate.PhoneNumbers {
+			personalInfo = append(personalInfo, strings.TrimPrefix(phoneNumber, "+"))
+		}
+
+		for _, info := range personalInfo {
+			if len([]rune(info)) >= minPersonalInfoLength && strings.Contains(password, info) {
+				return &Violation{
+					Rule:   RulePersonalInfo,
+					Params: []string{},
+					reason: "password must not contain the name or phone number",
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// NotReused rejects the current and the previous passwords of the candidate,
+// remembered is the number of passwords the deployment keeps in PreviousHashes
+func NotReused(hasher *Hasher, remembered int) Rule {
+	return RuleFunc(func(candidate Candidate) *Violation {
+		for _, previousHash := range candidate.PreviousHashes {
+			ok, err := hasher.Verify(candidate.Password, previousHash)
+			if err == nil && ok {
+				return &Violation{
+					Rule:   RuleReused,
+					Params: []string{strconv.Itoa(remembered)},
+					reason: "password must be different from the previous passwords",
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker(t *testing.T) {
+	hasher, err := NewHasher(Policy{Argon2id: testArgon2id})
+	assert.NoError(t, err)
+	previousHash, _ := hasher.Hash("0ld-Passw0rd")
+
+	breachedPath := filepath.Join(t.TempDir(), "breached.txt")
+	assert.NoError(t, os.WriteFile(breachedPath, []byte("# top passwords\nP@ssw0rd\n\nQwerty123!\r\n"), 0o600))
+	breached, err := LoadBreachedList(breachedPath, 0.001)
+	assert.NoError(t, err)
+
+	rules := append(DefaultRules(), RequireClass(ClassLowercase), NotBreached(breached), NotReused(hasher, 3))
+	checker := NewChecker(rules...)
+
+	tests := []struct {
+		name     string
+		password string
+		rules    []string
+	}{
+		{name: "Strong", password: "1n19s9H88@"},
+		{name: "Too Short", password: "aB1@", rules: []string{RuleMinLength}},
+		{name: "Too Long", password: "aB1@" + strings.Repeat("x", 61), rules: []string{RuleMaxLength}},
+		{name: "Missing Classes", password: "abcdefgh", rules: []string{RuleUppercase, RuleNumber, RuleSpecial}},
+		{name: "Missing Lowercase", password: "1N19S9H88@", rules: []string{RuleLowercase}},
+		{name: "Contains Name", password: "Hasbi#2024x", rules: []string{RulePersonalInfo}},
+		{name: "Contains Name In Other Case", password: "aSSHIDIQ#2024", rules: []string{RulePersonalInfo}},
+		{name: "Contains National Number", password: "X!x81234567890", rules: []string{RulePersonalInfo}},
+		{name: "Contains E164 Number", password: "X!x6281234567890", rules: []string{RulePersonalInfo}},
+		{name: "Short Name Part Is Allowed", password: "Al#2024xyz"},
+		{name: "Breached", password: "P@ssw0rd", rules: []string{RuleBreached}},
+		{name: "Breached With Windows Line Ending", password: "Qwerty123!", rules: []string{RuleBreached}},
+		{name: "Reused", password: "0ld-Passw0rd", rules: []string{RuleReused}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations := checker.Check(Candidate{
+				Password:       test.password,
+				FullName:       "Hasbi Asshidiq Al",
+				PhoneNumbers:   []string{"+6281234567890", "81234567890"},
+				PreviousHashes: []string{previousHash},
+			})
+
+			rules := []string{}
+			for _, violation := range violations {
+				rules = append(rules, violation.Rule)
+			}
+			assert.ElementsMatch(t, test.rules, rules)
+		})
+	}
+}
+
+func TestNotReusedParams(t *testing.T) {
+	hasher, err := NewHasher(Policy{Argon2id: testArgon2id})
+	assert.NoError(t, err)
+	previousHash, _ := hasher.Hash("0ld-Passw0rd")
+
+	violation := NotReused(hasher, 5).Check(Candidate{Password: "0ld-Passw0rd", PreviousHashes: []string{previousHash}})
+	if assert.NotNil(t, violation) {
+		assert.Equal(t, []string{"5"}, violation.Params)
+	}
+}
+
+func TestParseCharacterClass(t *testing.T) {
+	class, ok := ParseCharacterClass(" Uppercase ")
+	assert.True(t, ok)
+	assert.Equal(t, ClassUppercase, class)
+
+	_, ok = ParseCharacterClass("emoji")
+	assert.False(t, ok)
+}
@@ -0,0 +1,194 @@
+// Package phone parses international phone numbers in E.164 format, e.g. +6281234567890,
+// into their country calling code and national number. Numbers typed in local format are normalized first.
+package phone
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// RuleFormat is reported when the number is not made of digits after normalization
+	RuleFormat = "e164"
+	// RuleCountry is reported when the calling code doesn't belong to an allowed country
+	RuleCountry = "phoneCountry"
+	// RuleLength is reported when the national number is too short or too long for its country
+	RuleLength = "phoneLength"
+)
+
+// Country holds the numbering rules of a country
+type Country struct {
+	// ISO 3166-1 alpha-2 code, e.g. ID
+	ISO string
+	// CallingCode without the leading +, e.g. 62
+	CallingCode string
+	// MinLength and MaxLength bound the digits of the national number
+	MinLength int
+	MaxLength int
+}
+
+// Countries lists the countries whose numbering rules are known, keyed by ISO code
+var Countries = map[string]Country{
+	"ID": {ISO: "ID", CallingCode: "62", MinLength: 7, MaxLength: 12},
+	"MY": {ISO: "MY", CallingCode: "60", MinLength: 8, MaxLength: 10},
+	"SG": {ISO: "SG", CallingCode: "65", MinLength: 8, MaxLength: 8},
+	"BN": {ISO: "BN", CallingCode: "673", MinLength: 7, MaxLength: 7},
+	"TL": {ISO: "TL", CallingCode: "670", MinLength: 7, MaxLength: 8},
+	"PH": {ISO: "PH", CallingCode: "63", MinLength: 8, MaxLength: 10},
+	"TH": {ISO: "TH", CallingCode: "66", MinLength: 8, MaxLength: 9},
+	"AU": {ISO: "AU", CallingCode: "61", MinLength: 9, MaxLength: 9},
+	"US": {ISO: "US", CallingCode: "1", MinLength: 10, MaxLength: 10},
+}
+
+// DefaultCountries is the allow-list used when none is configured
+var DefaultCountries = []string{"ID"}
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Number is a parsed phone number
+type Number struct {
+	Country Country
+	// CountryCode is the calling code with the leading +, e.g. +62, as stored in profiles.country_code
+	CountryCode string
+	// NationalNumber is the number without the calling code, e.g. 81234567890
+	NationalNumber string
+}
+
+// E164 returns the number in E.164 format
+func (n Number) E164() string {
+	return n.CountryCode + n.NationalNumber
+}
+
+// ParseError describes why a number can't be parsed, Rule and Params follow the validation error response
+type ParseError struct {
+	Rule   string
+	Params []string
+	reason string
+}
+
+func (e *ParseError) Error() string {
+	return e.reason
+}
+
+// separators are the characters people put between the digits, e.g. +62 812-3456 (789)
+var separators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "\u00a0", "")
+
+// Parser parses numbers of the allowed countries only
+type Parser struct {
+	// countries are the allowed countries sorted by calling code length, longest first,
+	// so that +673 is matched before a shorter code with the same prefix
+	countries []Country
+	// home is the country of the numbers written with the national trunk prefix 0, e.g. 0812...
+	home Country
+}
+
+// NewParser creates a parser accepting the numbers of the given ISO country codes,
+// the first one is the home country of the numbers written in local format
+func NewParser(allowedCountries []string) (*Parser, error) {
+	if len(allowedCountries) == 0 {
+		allowedCountries = DefaultCountries
+	}
+
+	countries := make([]Country, 0, len(allowedCountries))
+	for _, iso := range allowedCountries {
+		country, ok := Countries[strings.ToUpper(strings.TrimSpace(iso))]
+		if !ok {
+			return nil, fmt.Errorf("unknown country %q", iso)
+		}
+		countries = append(countries, country)
+	}
+
+	home := countries[0]
+	sort.SliceStable(countries, func(i, j int) bool {
+		return len(countries[i].CallingCode) > len(countries[j].CallingCode)
+	})
+
+	return &Parser{countries: countries, home: home}, nil
+}
+
+// CallingCodes returns the calling codes of the allowed countries, e.g. [+62 +60]
+func (p *Parser) CallingCodes() []string {
+	codes := make([]string, 0, len(p.countries))
+	for _, country := range p.countries {
+		codes = append(codes, "+"+country.CallingCode)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Normalize converts the common ways of writing a phone number into E.164:
+//
+//	+62 812-3456-789    => +628123456789
+//	0812 3456 789       => +628123456789 (0 is the trunk prefix of the home country)
+//	00628123456789      => +628123456789 (00 is the international call prefix)
+//	628123456789        => +628123456789
+//	+62 0812 3456 789   => +628123456789 (the trunk prefix is dropped after the calling code)
+//	+62 (0)812 3456 789 => +628123456789
+//
+// The result is not validated, Parse reports numbers which are still not E.164.
+func (p *Parser) Normalize(raw string) string {
+	number := separators.Replace(strings.TrimSpace(raw))
+
+	switch {
+	case number == "":
+		return number
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = "+" + number[2:]
+	case strings.HasPrefix(number, "0"):
+		return "+" + p.home.CallingCode + number[1:]
+	default:
+		number = "+" + number
+	}
+
+	// the national numbers of the known countries never start with 0, it's the trunk prefix written by habit
+	for _, country := range p.countries {
+		if strings.HasPrefix(number[1:], country.CallingCode+"0") {
+			return "+" + country.CallingCode + number[1+len(country.CallingCode)+1:]
+		}
+	}
+	return number
+}
+
+// Parse normalizes raw, splits it into calling code and national number and checks it against its country rules
+func (p *Parser) Parse(raw string) (Number, error) {
+	raw = p.Normalize(raw)
+	if !e164Pattern.MatchString(raw) {
+		return Number{}, &ParseError{
+			Rule:   RuleFormat,
+			Params: []string{},
+			reason: "phone number must be the country code followed by the number",
+		}
+	}
+
+	digits := raw[1:]
+	for _, country := range p.countries {
+		if !strings.HasPrefix(digits, country.CallingCode) {
+			continue
+		}
+
+		nationalNumber := digits[len(country.CallingCode):]
+		if len(nationalNumber) < country.MinLength || len(nationalNumber) > country.MaxLength {
+			return Number{}, &ParseError{
+				Rule:   RuleLength,
+				Params: []string{fmt.Sprint(country.MinLength), fmt.Sprint(country.MaxLength)},
+				reason: fmt.Sprintf("%s numbers must have %d to %d digits after +%s", country.ISO, country.MinLength, country.MaxLength, country.CallingCode),
+			}
+		}
+
+		return Number{
+			Country:        country,
+			CountryCode:    "+" + country.CallingCode,
+			NationalNumber: nationalNumber,
+		}, nil
+	}
+
+	allowed := strings.Join(p.CallingCodes(), ", ")
+	return Number{}, &ParseError{
+		Rule:   RuleCountry,
+		Params: []string{allowed},
+		reason: "phone number country code is not supported, allowed: " + allowed,
+	}
+}
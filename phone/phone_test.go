@@ -0,0 +1,130 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	parser, err := NewParser([]string{"ID", "MY", "SG", "BN"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		raw            string
+		countryCode    string
+		nationalNumber string
+		rule           string
+	}{
+		{name: "Indonesia", raw: "+6289627117", countryCode: "+62", nationalNumber: "89627117"},
+		{name: "Indonesia Long", raw: "+62812345678901", countryCode: "+62", nationalNumber: "812345678901"},
+		{name: "Malaysia", raw: "+60123456789", countryCode: "+60", nationalNumber: "123456789"},
+		{name: "Singapore", raw: "+6581234567", countryCode: "+65", nationalNumber: "81234567"},
+		{name: "Three Digit Calling Code", raw: "+6737123456", countryCode: "+673", nationalNumber: "7123456"},
+		{name: "Letters After Normalization", raw: "0896-abc-117", rule: RuleFormat},
+		{name: "Only Separators", raw: " - ", rule: RuleFormat},
+		{name: "Letters", raw: "+62896abc117", rule: RuleFormat},
+		{name: "Singapore Too Long", raw: "+65812345678", rule: RuleLength},
+		{name: "Indonesia Too Short", raw: "+62812345", rule: RuleLength},
+		{name: "Country Not Allowed", raw: "+6621234567", rule: RuleCountry},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			number, err := parser.Parse(test.raw)
+
+			if test.rule != "" {
+				parseError, ok := err.(*ParseError)
+				if assert.True(t, ok) {
+					assert.Equal(t, test.rule, parseError.Rule)
+				}
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.countryCode, number.CountryCode)
+				assert.Equal(t, test.nationalNumber, number.NationalNumber)
+				assert.Equal(t, test.raw, number.E164())
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	parser, err := NewParser([]string{"ID", "MY"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{name: "Canonical", raw: "+628123456789", expected: "+628123456789"},
+		{name: "Spaces", raw: "+62 812 3456 789", expected: "+628123456789"},
+		{name: "Dashes", raw: "0812-3456-789", expected: "+628123456789"},
+		{name: "Dots And Parentheses", raw: "(0812) 3456.789", expected: "+628123456789"},
+		{name: "Non Breaking Space", raw: "0812\u00a03456789", expected: "+628123456789"},
+		{name: "Surrounding Whitespace", raw: "  08123456789\t", expected: "+628123456789"},
+		{name: "Trunk Prefix Uses Home Country", raw: "08123456789", expected: "+628123456789"},
+		{name: "Calling Code Without Plus", raw: "628123456789", expected: "+628123456789"},
+		{name: "International Call Prefix", raw: "00628123456789", expected: "+628123456789"},
+		{name: "Other Country Without Plus", raw: "60123456789", expected: "+60123456789"},
+		{name: "Calling Code Inside Number Is Kept", raw: "+6281262626262", expected: "+6281262626262"},
+		{name: "Trunk Prefix After Calling Code", raw: "+62 0812-3456-789", expected: "+628123456789"},
+		{name: "Trunk Prefix After Calling Code Without Plus", raw: "62 08123456789", expected: "+628123456789"},
+		{name: "Trunk Prefix In Parentheses", raw: "+62 (0)812 3456 789", expected: "+628123456789"},
+		{name: "Trunk Prefix After International Call Prefix", raw: "0062 08123456789", expected: "+628123456789"},
+		{name: "Trunk Prefix After Other Calling Code", raw: "+60 0123456789", expected: "+60123456789"},
+		{name: "Empty", raw: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, parser.Normalize(test.raw))
+		})
+	}
+}
+
+func TestParseLocalFormats(t *testing.T) {
+	parser, err := NewParser([]string{"MY", "ID"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		raw            string
+		countryCode    string
+		nationalNumber string
+	}{
+		{name: "Trunk Prefix Uses First Allowed Country", raw: "012-345 6789", countryCode: "+60", nationalNumber: "123456789"},
+		{name: "Indonesia Without Plus", raw: "62 812 3456 789", countryCode: "+62", nationalNumber: "8123456789"},
+		{name: "Indonesia With Spaces", raw: "+62 812-3456-789", countryCode: "+62", nationalNumber: "8123456789"},
+		{name: "Indonesia With Trunk Prefix", raw: "+62 0812-3456-789", countryCode: "+62", nationalNumber: "8123456789"},
+		{name: "Malaysia With Trunk Prefix", raw: "60 012-345 6789", countryCode: "+60", nationalNumber: "123456789"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			number, err := parser.Parse(test.raw)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.countryCode, number.CountryCode)
+				assert.Equal(t, test.nationalNumber, number.NationalNumber)
+			}
+		})
+	}
+}
+
+func TestNewParser(t *testing.T) {
+
+	t.Run("Default Countries", func(t *testing.T) {
+		parser, err := NewParser(nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"+62"}, parser.CallingCodes())
+		}
+	})
+
+	t.Run("Unknown Country", func(t *testing.T) {
+		_, err := NewParser([]string{"ID", "XX"})
+		assert.Error(t, err)
+	})
+}
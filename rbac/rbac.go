@@ -0,0 +1,103 @@
+// Package rbac maps the roles of a profile to the permissions, or scopes, granted to its access tokens.
+// The routes require permissions in the OpenAPI spec, e.g. security: [{bearerAuth: [profiles:read]}].
+package rbac
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// RoleUser is the role of every profile, the stored roles are granted in addition to it
+const RoleUser = "user"
+
+// Roles of the operators
+const (
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// Permissions of the default policy
+const (
+	// PermissionProfilesRead allows reading the profiles of other users
+	PermissionProfilesRead = "profiles:read"
+	// PermissionProfilesWrite allows changing the profiles of other users
+	PermissionProfilesWrite = "profiles:write"
+)
+
+var permissionPattern = regexp.MustCompile(`^[a-z][a-z_]*:[a-z][a-z_]*$`)
+
+// Policy maps each role to its permissions
+type Policy map[string][]string
+
+// DefaultPolicy is the policy used when none is configured: users manage their own profile only,
+// support agents read the profiles of others and admins change them
+func DefaultPolicy() Policy {
+	return Policy{
+		RoleUser:    {},
+		RoleSupport: {PermissionProfilesRead},
+		RoleAdmin:   {PermissionProfilesRead, PermissionProfilesWrite},
+	}
+}
+
+// ParsePolicy reads a policy written in JSON, e.g. {"support": ["profiles:read"]}.
+// Permissions are written as resource:action.
+func ParsePolicy(raw string) (Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return nil, err
+	}
+
+	for role, permissions := range policy {
+		if role == "" {
+			return nil, errors.New("role without a name")
+		}
+		for _, permission := range permissions {
+			if !permissionPattern.MatchString(permission) {
+				return nil, fmt.Errorf("role %s: invalid permission %q, expected resource:action", role, permission)
+			}
+		}
+	}
+	if _, ok := policy[RoleUser]; !ok {
+		policy[RoleUser] = []string{}
+	}
+
+	return policy, nil
+}
+
+// Permissions returns the sorted permissions granted by roles, the unknown roles grant nothing
+func (p Policy) Permissions(roles []string) []string {
+	granted := map[string]bool{}
+	for _, role := range roles {
+		for _, permission := range p[role] {
+			granted[permission] = true
+		}
+	}
+
+	permissions := make([]string, 0, len(granted))
+	for permission := range granted {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Missing returns the required permissions which are not granted
+func Missing(granted []string, required []string) []string {
+	missing := []string{}
+	for _, permission := range required {
+		found := false
+		for _, grant := range granted {
+			if grant == permission {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, permission)
+		}
+	}
+	return missing
+}
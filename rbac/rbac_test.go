@@ -0,0 +1,43 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissions(t *testing.T) {
+	policy := DefaultPolicy()
+
+	assert.Empty(t, policy.Permissions([]string{RoleUser}))
+	assert.Equal(t, []string{"profiles:read"}, policy.Permissions([]string{RoleUser, RoleSupport}))
+	assert.Equal(t, []string{"profiles:read", "profiles:write"}, policy.Permissions([]string{RoleSupport, RoleAdmin}))
+	assert.Empty(t, policy.Permissions([]string{"unknown"}))
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		policy, err := ParsePolicy(`{"auditor": ["profiles:read", "activity:read"]}`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"activity:read", "profiles:read"}, policy.Permissions([]string{"auditor"}))
+			// every profile has the user role, even when the policy doesn't mention it
+			assert.Contains(t, policy, RoleUser)
+		}
+	})
+
+	t.Run("Invalid Permission", func(t *testing.T) {
+		_, err := ParsePolicy(`{"auditor": ["read everything"]}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, err := ParsePolicy(`["admin"]`)
+		assert.Error(t, err)
+	})
+}
+
+func TestMissing(t *testing.T) {
+	assert.Empty(t, Missing([]string{"profiles:read", "profiles:write"}, []string{"profiles:write"}))
+	assert.Equal(t, []string{"profiles:write"}, Missing([]string{"profiles:read"}, []string{"profiles:read", "profiles:write"}))
+	assert.Empty(t, Missing(nil, nil))
+}
@@ -2,11 +2,58 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// profileColumns are the columns of profiles read by scanProfile, in its order
+const profileColumns = `id, full_name, country_code, phone_number, password, created_at, updated_at, deleted_at,
+			status, status_reason, status_changed_by, status_changed_at, version,
+			email, email_verified_at, date_of_birth, gender, locale, timezone, attributes, avatar_key`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProfile(row rowScanner) (profile Profile, err error) {
+	var attributes []byte
+	err = row.Scan(
+		&profile.ID,
+		&profile.FullName,
+		&profile.CountryCode,
+		&profile.PhoneNumber,
+		&profile.Password,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+		&profile.DeletedAt,
+		&profile.Status,
+		&profile.StatusReason,
+		&profile.StatusChangedBy,
+		&profile.StatusChangedAt,
+		&profile.Version,
+		&profile.Email,
+		&profile.EmailVerifiedAt,
+		&profile.DateOfBirth,
+		&profile.Gender,
+		&profile.Locale,
+		&profile.Timezone,
+		&attributes,
+		&profile.AvatarKey,
+	)
+	if err != nil {
+		return profile, err
+	}
+
+	err = json.Unmarshal(attributes, &profile.Attributes)
+	return profile, err
+}
+
 func (r *Repository) CreateProfile(input Profile) (createdID int, err error) {
 
 	stmt, err := r.Db.Prepare(
@@ -36,7 +83,101 @@ func (r *Repository) CreateProfile(input Profile) (createdID int, err error) {
 	return createdID, nil
 }
 
-func (r *Repository) GetPhoneNumberExistence(phoneNumber string) (isExist bool, err error) {
+func (r *Repository) ImportProfiles(profiles []Profile, dryRun bool) (duplicates []int, err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the batch is copied to a temporary table first, COPY into profiles would fail on the first registered number
+	_, err = tx.Exec(`
+		CREATE TEMPORARY TABLE profile_imports (
+			position INT NOT NULL,
+			full_name VARCHAR(60) NOT NULL,
+			country_code VARCHAR(5) NOT NULL,
+			phone_number VARCHAR(20) NOT NULL,
+			password VARCHAR(255) NOT NULL
+		) ON COMMIT DROP`)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("profile_imports", "position", "full_name", "country_code", "phone_number", "password"))
+	if err != nil {
+		return nil, err
+	}
+	for position, profile := range profiles {
+		_, err = stmt.Exec(position, profile.FullName, profile.CountryCode, profile.PhoneNumber, profile.Password)
+		if err != nil {
+			stmt.Close()
+			return nil, err
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	if err = stmt.Close(); err != nil {
+		return nil, err
+	}
+
+	// the numbers registered concurrently are skipped by the insert and reported with the others
+	query := `
+		SELECT
+			i.position
+		FROM
+			profile_imports i
+		WHERE
+			EXISTS (SELECT 1 FROM profiles p WHERE p.country_code = i.country_code and p.phone_number = i.phone_number
+				and p.deleted_at is null)
+		ORDER BY i.position`
+	if !dryRun {
+		query = `
+		WITH inserted AS (
+			INSERT INTO profiles
+				(full_name, country_code, phone_number, password)
+			SELECT
+				full_name, country_code, phone_number, password
+			FROM
+				profile_imports
+			ORDER BY position
+			ON CONFLICT (country_code, phone_number) WHERE deleted_at IS NULL DO NOTHING
+			RETURNING country_code, phone_number
+		)
+		SELECT
+			i.position
+		FROM
+			profile_imports i
+		WHERE
+			NOT EXISTS (SELECT 1 FROM inserted n WHERE n.country_code = i.country_code and n.phone_number = i.phone_number)
+		ORDER BY i.position`
+	}
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var position int
+		if err := rows.Scan(&position); err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, position)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return duplicates, nil
+	}
+	return duplicates, tx.Commit()
+}
+
+func (r *Repository) GetPhoneNumberExistence(countryCode string, phoneNumber string) (isExist bool, err error) {
 	var profileID int
 	err = r.Db.QueryRow(`
 		SELECT 
@@ -44,8 +185,8 @@ func (r *Repository) GetPhoneNumberExistence(phoneNumber string) (isExist bool,
 		FROM 
 			profiles
 		WHERE 
-			phone_number = $1 and deleted_at is null`,
-		phoneNumber).Scan(&profileID)
+			country_code = $1 and phone_number = $2 and deleted_at is null`,
+		countryCode, phoneNumber).Scan(&profileID)
 
 	if err == sql.ErrNoRows {
 		return false, nil
@@ -57,7 +198,7 @@ func (r *Repository) GetPhoneNumberExistence(phoneNumber string) (isExist bool,
 
 }
 
-func (r *Repository) GetPhoneNumberExistenceWithExcludedID(phoneNumber string, excludedID int) (isExist bool, err error) {
+func (r *Repository) GetPhoneNumberExistenceWithExcludedID(countryCode string, phoneNumber string, excludedID int) (isExist bool, err error) {
 	var profileID int
 	err = r.Db.QueryRow(`
 		SELECT 
@@ -65,8 +206,8 @@ func (r *Repository) GetPhoneNumberExistenceWithExcludedID(phoneNumber string, e
 		FROM 
 			profiles
 		WHERE 
-			phone_number = $1 and id != $2 and deleted_at is null`,
-		phoneNumber, excludedID).Scan(&profileID)
+			country_code = $1 and phone_number = $2 and id != $3 and deleted_at is null`,
+		countryCode, phoneNumber, excludedID).Scan(&profileID)
 
 	if err == sql.ErrNoRows {
 		return false, nil
@@ -77,76 +218,865 @@ func (r *Repository) GetPhoneNumberExistenceWithExcludedID(phoneNumber string, e
 	return true, err
 
 }
-func (r *Repository) GetProfileByPhoneNumber(phoneNumber string) (profile Profile, err error) {
 
-	// Fetch a single row from the database
+// GetEmailExistenceWithExcludedID tells whether another profile has verified the email, the unverified addresses
+// don't belong to anyone yet
+func (r *Repository) GetEmailExistenceWithExcludedID(email string, excludedID int) (isExist bool, err error) {
+	var profileID int
+	err = r.Db.QueryRow(`
+		SELECT 
+			id 
+		FROM 
+			profiles
+		WHERE 
+			email = $1 and id != $2 and email_verified_at is not null and deleted_at is null`,
+		email, excludedID).Scan(&profileID)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, err
+}
+
+// GetProfileByEmail returns the profile which verified the email address, several profiles may have it unverified
+func (r *Repository) GetProfileByEmail(email string) (profile Profile, err error) {
 	row := r.Db.QueryRow(`
-		SELECT * FROM 
+		SELECT `+profileColumns+` FROM 
 			profiles 
 		WHERE 
-			phone_number = $1 and deleted_at is null`, phoneNumber)
+			email = $1 and email_verified_at is not null and deleted_at is null`, email)
 
-	err = row.Scan(
-		&profile.ID,
-		&profile.FullName,
-		&profile.CountryCode,
-		&profile.PhoneNumber,
-		&profile.Password,
-		&profile.CreatedAt,
-		&profile.UpdatedAt,
-		&profile.DeletedAt,
-	)
+	return scanProfile(row)
+}
+
+// MarkEmailVerificationSent records that a verification link of the email of the profile is being sent, unless
+// another one has been sent since sentBefore. It's false when the profile's email isn't email anymore.
+func (r *Repository) MarkEmailVerificationSent(profileID uint64, email string, sentBefore time.Time) (marked bool, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE profiles SET email_verification_sent_at = NOW()
+		WHERE id = $1 and email = $2 and deleted_at is null
+			and (email_verification_sent_at is null or email_verification_sent_at < $3)`,
+		profileID, email, sentBefore)
 	if err != nil {
-		return profile, err
+		return false, err
 	}
-	return profile, nil
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// VerifyEmail marks the email of the profile as verified, as long as it's still email. It's false when the email
+// has been changed or was already verified, and ErrEmailTaken when another profile has verified it first.
+func (r *Repository) VerifyEmail(profileID uint64, email string) (verified bool, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE profiles SET email_verified_at = NOW(), version = version + 1
+		WHERE id = $1 and email = $2 and email_verified_at is null and deleted_at is null`,
+		profileID, email)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "profiles_verified_email_key" {
+		return false, ErrEmailTaken
+	}
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) GetProfileByPhoneNumber(countryCode string, phoneNumber string) (profile Profile, err error) {
+
+	// Fetch a single row from the database
+	row := r.Db.QueryRow(`
+		SELECT `+profileColumns+` FROM 
+			profiles 
+		WHERE 
+			country_code = $1 and phone_number = $2 and deleted_at is null`, countryCode, phoneNumber)
+
+	return scanProfile(row)
 }
 
 func (r *Repository) GetProfileByID(id int) (profile Profile, err error) {
 
 	// Fetch a single row from the database
 	row := r.Db.QueryRow(`
-		SELECT * FROM 
+		SELECT `+profileColumns+` FROM 
 			profiles 
 		WHERE 
 			id = $1 and deleted_at is null`, id)
 
-	err = row.Scan(
-		&profile.ID,
-		&profile.FullName,
-		&profile.CountryCode,
-		&profile.PhoneNumber,
-		&profile.Password,
-		&profile.CreatedAt,
-		&profile.UpdatedAt,
-		&profile.DeletedAt,
-	)
+	return scanProfile(row)
+}
+
+func (r *Repository) GetProfileByIDIncludingDeleted(id uint64) (profile Profile, err error) {
+	return scanProfile(r.Db.QueryRow(`
+		SELECT
+			`+profileColumns+`
+		FROM
+			profiles
+		WHERE
+			id = $1`, id))
+}
+
+func (r *Repository) ListProfiles(filter ProfileFilter) (profiles []Profile, err error) {
+	conditions := []string{}
+	args := []interface{}{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch filter.Deleted {
+	case DeletedInclude:
+	case DeletedOnly:
+		conditions = append(conditions, "deleted_at is not null")
+	default:
+		conditions = append(conditions, "deleted_at is null")
+	}
+	if filter.NamePrefix != "" {
+		// the wildcards typed by the user are matched literally
+		prefix := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(filter.NamePrefix))
+		conditions = append(conditions, "lower(full_name) LIKE "+arg(prefix+"%"))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if filter.CountryCode != "" {
+		conditions = append(conditions, "country_code = "+arg(filter.CountryCode))
+	}
+	if filter.PhoneNumber != "" {
+		conditions = append(conditions, "phone_number = "+arg(filter.PhoneNumber))
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(*filter.CreatedTo))
+	}
+
+	// keyset pagination: the rows after the cursor in the order of the sort, the ID breaks the ties
+	var order string
+	switch filter.Sort {
+	case ProfileSortCreatedAtAsc:
+		order = "created_at ASC, id ASC"
+		if filter.After != nil {
+			conditions = append(conditions, fmt.Sprintf("(created_at, id) > (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.ID)))
+		}
+	case ProfileSortFullNameAsc:
+		order = "lower(full_name) ASC, id ASC"
+		if filter.After != nil {
+			conditions = append(conditions, fmt.Sprintf("(lower(full_name), id) > (lower(%s), %s)", arg(filter.After.FullName), arg(filter.After.ID)))
+		}
+	case ProfileSortFullNameDesc:
+		order = "lower(full_name) DESC, id DESC"
+		if filter.After != nil {
+			conditions = append(conditions, fmt.Sprintf("(lower(full_name), id) < (lower(%s), %s)", arg(filter.After.FullName), arg(filter.After.ID)))
+		}
+	default:
+		order = "created_at DESC, id DESC"
+		if filter.After != nil {
+			conditions = append(conditions, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.ID)))
+		}
+	}
+
+	query := `
+		SELECT
+			` + profileColumns + `
+		FROM
+			profiles`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE\n\t\t\t" + strings.Join(conditions, " and ")
+	}
+	query += "\n\t\tORDER BY " + order + "\n\t\tLIMIT " + arg(filter.Limit)
+
+	rows, err := r.Db.Query(query, args...)
 	if err != nil {
-		return profile, err
+		return nil, err
 	}
-	return profile, nil
+	defer rows.Close()
+
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
 }
 
-func (r *Repository) UpdateProfileByID(profile Profile) (err error) {
-	query := "UPDATE profiles SET"
+// UpdateProfileByID writes the given ProfileField fields of the profile, empty values included, and increments its
+// version. The update is conditional on the version of the profile being expectedVersion, 0 updates any version.
+func (r *Repository) UpdateProfileByID(profile Profile, fields []string, expectedVersion uint64) (updated bool, err error) {
+	query := "UPDATE profiles SET version = version + 1"
 	setValues := make([]string, 0)
+	args := []interface{}{profile.ID, expectedVersion}
+
+	// Values are passed as query arguments, $1 is the profile ID and $2 the expected version
+	setValue := func(column string, value interface{}) {
+		args = append(args, value)
+		setValues = append(setValues, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	for _, field := range fields {
+		switch field {
+		case ProfileFieldFullName:
+			setValue("full_name", profile.FullName)
+		case ProfileFieldPhoneNumber:
+			setValue("country_code", profile.CountryCode)
+			setValue("phone_number", profile.PhoneNumber)
+		case ProfileFieldEmail:
+			setValue("email", profile.Email)
+			// the verification belongs to the previous address, the right-hand sides read the row before the update
+			setValues = append(setValues, fmt.Sprintf("email_verified_at = CASE WHEN email = $%d THEN email_verified_at END", len(args)))
+			setValues = append(setValues, fmt.Sprintf("email_verification_sent_at = CASE WHEN email = $%d THEN email_verification_sent_at END", len(args)))
+		case ProfileFieldDateOfBirth:
+			setValue("date_of_birth", profile.DateOfBirth)
+		case ProfileFieldGender:
+			setValue("gender", profile.Gender)
+		case ProfileFieldLocale:
+			setValue("locale", profile.Locale)
+		case ProfileFieldTimezone:
+			setValue("timezone", profile.Timezone)
+		case ProfileFieldAttributes:
+			attributes := []byte("{}")
+			if profile.Attributes != nil {
+				attributes, err = json.Marshal(profile.Attributes)
+				if err != nil {
+					return false, err
+				}
+			}
+			setValue("attributes", attributes)
+		case ProfileFieldAvatar:
+			setValue("avatar_key", profile.AvatarKey)
+		default:
+			return false, fmt.Errorf("unknown profile field %q", field)
+		}
+	}
+
+	// No fields to update
+	if len(setValues) == 0 {
+		return true, nil
+	}
+
+	query += ", " + strings.Join(setValues, ", ") + " WHERE id = $1 and ($2 = 0 or version = $2)"
+	result, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) UpdatePasswordByID(id uint64, password string) (err error) {
+	_, err = r.Db.Exec(`
+		UPDATE profiles SET
+			password = $2, updated_at = $3
+		WHERE
+			id = $1 and deleted_at is null`,
+		id, password, time.Now())
+	return err
+}
 
-	if profile.FullName != "" {
-		setValues = append(setValues, fmt.Sprintf("full_name = '%s'", profile.FullName))
+func (r *Repository) ChangePasswordByID(id uint64, password string) (err error) {
+	// The replaced password is kept in the history in the same statement, so the reuse check sees every change
+	_, err = r.Db.Exec(`
+		WITH previous AS (
+			INSERT INTO password_history (profile_id, password)
+				SELECT id, password FROM profiles WHERE id = $1 and deleted_at is null
+		)
+		UPDATE profiles SET
+			password = $2, updated_at = $3
+		WHERE
+			id = $1 and deleted_at is null`,
+		id, password, time.Now())
+	return err
+}
+
+func (r *Repository) GetPasswordHistory(profileID uint64, limit int) (passwords []string, err error) {
+	rows, err := r.Db.Query(`
+		SELECT
+			password
+		FROM
+			password_history
+		WHERE
+			profile_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		profileID, limit)
+	if err != nil {
+		return nil, err
 	}
-	if profile.PhoneNumber != "" {
-		setValues = append(setValues, fmt.Sprintf("phone_number = '%s'", profile.PhoneNumber))
+	defer rows.Close()
+
+	for rows.Next() {
+		var password string
+		if err := rows.Scan(&password); err != nil {
+			return nil, err
+		}
+		passwords = append(passwords, password)
 	}
+	return passwords, rows.Err()
+}
+
+func (r *Repository) GetProfileMFA(profileID uint64) (mfa ProfileMFA, err error) {
+	err = r.Db.QueryRow(`
+		SELECT
+			profile_id, secret, last_used_step, failed_attempts, locked_until, confirmed_at, created_at, updated_at
+		FROM
+			profile_mfa
+		WHERE
+			profile_id = $1`, profileID).Scan(
+		&mfa.ProfileID,
+		&mfa.Secret,
+		&mfa.LastUsedStep,
+		&mfa.FailedAttempts,
+		&mfa.LockedUntil,
+		&mfa.ConfirmedAt,
+		&mfa.CreatedAt,
+		&mfa.UpdatedAt,
+	)
+	return mfa, err
+}
 
-	// If there are fields to update, execute the query
-	if len(setValues) > 0 {
-		query += " " + strings.Join(setValues, ", ") + " WHERE id = $1"
-		_, err := r.Db.Exec(query, profile.ID)
+func (r *Repository) UpsertProfileMFASecret(profileID uint64, secret string) (stored bool, err error) {
+	// A confirmed authenticator is never replaced, the enrollment can only be restarted before the confirmation.
+	// Nothing is stored when the authenticator has been confirmed in the meantime.
+	result, err := r.Db.Exec(`
+		INSERT INTO profile_mfa
+			(profile_id, secret) VALUES ($1, $2)
+		ON CONFLICT (profile_id)
+			DO
+				UPDATE
+					SET secret = $2, last_used_step = 0, updated_at = $3
+					WHERE profile_mfa.confirmed_at is null`,
+		profileID, secret, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) ConfirmProfileMFA(profileID uint64, step int64, recoveryCodeHashes []string) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// No fields to update
-	return nil
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE profile_mfa SET
+			confirmed_at = $2, last_used_step = $3, updated_at = $2
+		WHERE
+			profile_id = $1 and confirmed_at is null`,
+		profileID, now, step)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM mfa_recovery_codes WHERE profile_id = $1`, profileID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.Exec(`
+			INSERT INTO mfa_recovery_codes
+				(profile_id, code_hash) VALUES ($1, $2)`,
+			profileID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) UseMFAStep(profileID uint64, step int64) (used bool, err error) {
+	// A code is accepted once, the condition on last_used_step rejects replays atomically
+	result, err := r.Db.Exec(`
+		UPDATE profile_mfa SET
+			last_used_step = $2, updated_at = $3
+		WHERE
+			profile_id = $1 and last_used_step < $2`,
+		profileID, step, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) UseRecoveryCode(profileID uint64, codeHash string) (used bool, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE mfa_recovery_codes SET
+			used_at = $3
+		WHERE
+			profile_id = $1 and code_hash = $2 and used_at is null`,
+		profileID, codeHash, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) RecordMFAFailure(profileID uint64, maxFailures int, lockUntil time.Time) (lockedUntil *time.Time, err error) {
+	// The failures are counted until maxFailures, which locks the second factor until lockUntil and starts counting again
+	err = r.Db.QueryRow(`
+		UPDATE profile_mfa SET
+			failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE
+			profile_id = $1
+		RETURNING locked_until`,
+		profileID, maxFailures, lockUntil).Scan(&lockedUntil)
+	return lockedUntil, err
+}
+
+func (r *Repository) CreateMFAChallenge(input MFAChallenge) (createdID uint64, err error) {
+	err = r.Db.QueryRow(`
+		INSERT INTO mfa_challenges
+			(
+				profile_id,
+				expires_at
+			) VALUES ($1, $2) RETURNING id`,
+		input.ProfileID,
+		input.ExpiresAt,
+	).Scan(&createdID)
+
+	return createdID, err
+}
+
+func (r *Repository) IncrementMFAChallengeAttempts(profileID uint64, id uint64) (attempts int, err error) {
+	// sql.ErrNoRows when the challenge is unknown, expired or already used
+	err = r.Db.QueryRow(`
+		UPDATE mfa_challenges SET
+			attempts = attempts + 1
+		WHERE
+			id = $1 and profile_id = $2 and used_at is null and expires_at > $3
+		RETURNING attempts`, id, profileID, time.Now()).Scan(&attempts)
+	return attempts, err
+}
+
+func (r *Repository) UseMFAChallenge(profileID uint64, id uint64) (used bool, err error) {
+	// The challenge is used once, a verified second factor also clears the failures of the profile
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE mfa_challenges SET
+			used_at = $3
+		WHERE
+			id = $1 and profile_id = $2 and used_at is null`,
+		id, profileID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 1 {
+		return false, err
+	}
+
+	_, err = tx.Exec(`UPDATE profile_mfa SET failed_attempts = 0 WHERE profile_id = $1`, profileID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *Repository) CreateLoginOTP(input LoginOTP) (createdID int, err error) {
+	err = r.Db.QueryRow(`
+		INSERT INTO login_otps
+			(
+				profile_id,
+				code_hash,
+				expires_at
+			) VALUES ($1, $2, $3) RETURNING id`,
+		input.ProfileID,
+		input.CodeHash,
+		input.ExpiresAt,
+	).Scan(&createdID)
+
+	return createdID, err
+}
+
+func (r *Repository) GetLatestLoginOTP(profileID uint64) (otp LoginOTP, err error) {
+	// Only the latest code is valid, requesting a new code replaces the previous ones
+	err = r.Db.QueryRow(`
+		SELECT
+			id, profile_id, code_hash, attempts, expires_at, used_at, created_at
+		FROM
+			login_otps
+		WHERE
+			profile_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`, profileID).Scan(
+		&otp.ID,
+		&otp.ProfileID,
+		&otp.CodeHash,
+		&otp.Attempts,
+		&otp.ExpiresAt,
+		&otp.UsedAt,
+		&otp.CreatedAt,
+	)
+	return otp, err
+}
+
+func (r *Repository) IncrementLoginOTPAttempts(id uint64) (attempts int, err error) {
+	err = r.Db.QueryRow(`
+		UPDATE login_otps SET
+			attempts = attempts + 1
+		WHERE
+			id = $1
+		RETURNING attempts`, id).Scan(&attempts)
+	return attempts, err
+}
+
+func (r *Repository) UseLoginOTP(id uint64) (used bool, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE login_otps SET
+			used_at = $2
+		WHERE
+			id = $1 and used_at is null`,
+		id, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// ReserveLoginOTPRequest records a code requested for the phone number, unless one has been requested since
+// requestedBefore or maxPerWindow have been requested in the window, which starts again after windowStartedBefore
+func (r *Repository) ReserveLoginOTPRequest(phoneNumber string, requestedBefore time.Time, windowStartedBefore time.Time, maxPerWindow int) (reserved bool, err error) {
+	result, err := r.Db.Exec(`
+		INSERT INTO login_otp_requests
+			(phone_number, last_requested_at, window_started_at, window_count) VALUES ($1, NOW(), NOW(), 1)
+		ON CONFLICT (phone_number)
+			DO UPDATE SET
+				last_requested_at = NOW(),
+				window_started_at = CASE WHEN login_otp_requests.window_started_at < $3
+					THEN NOW() ELSE login_otp_requests.window_started_at END,
+				window_count = CASE WHEN login_otp_requests.window_started_at < $3
+					THEN 1 ELSE login_otp_requests.window_count + 1 END
+			WHERE login_otp_requests.last_requested_at < $2
+				and (login_otp_requests.window_started_at < $3 or login_otp_requests.window_count < $4)`,
+		phoneNumber, requestedBefore, windowStartedBefore, maxPerWindow)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) CreateSession(input Session) (createdID uint64, err error) {
+	err = r.Db.QueryRow(`
+		INSERT INTO sessions
+			(
+				profile_id,
+				device_name,
+				ip_address,
+				expires_at
+			)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM profiles WHERE id = $1 and status = 'active')
+		RETURNING id`,
+		input.ProfileID,
+		input.DeviceName,
+		input.IPAddress,
+		input.ExpiresAt,
+	).Scan(&createdID)
+	return createdID, err
+}
+
+// sessionTouchInterval limits the writes of last_seen_at, a session used more often keeps its last write
+const sessionTouchInterval = time.Minute
+
+func (r *Repository) TouchSession(profileID uint64, id uint64) (active bool, err error) {
+	// A session is active until it's revoked or its token expires, and while its profile is active. The update
+	// runs whether its result is read or not.
+	now := time.Now()
+	err = r.Db.QueryRow(`
+		WITH active AS (
+			SELECT
+				id, last_seen_at
+			FROM
+				sessions
+			WHERE
+				id = $2 and profile_id = $1 and revoked_at is null and expires_at > $3
+				and EXISTS (SELECT 1 FROM profiles WHERE id = $1 and status = 'active')
+		), touched AS (
+			UPDATE sessions SET
+				last_seen_at = $3
+			WHERE
+				id IN (SELECT id FROM active WHERE last_seen_at < $4)
+		)
+		SELECT EXISTS (SELECT 1 FROM active)`,
+		profileID, id, now, now.Add(-sessionTouchInterval)).Scan(&active)
+	return active, err
+}
+
+func (r *Repository) GetActiveSessions(profileID uint64) (sessions []Session, err error) {
+	rows, err := r.Db.Query(`
+		SELECT
+			id, profile_id, device_name, ip_address, expires_at, last_seen_at, revoked_at, created_at
+		FROM
+			sessions
+		WHERE
+			profile_id = $1 and revoked_at is null and expires_at > $2
+		ORDER BY last_seen_at DESC, id DESC`,
+		profileID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// GetSessions returns every session of the profile, the revoked and expired ones included, the latest first
+func (r *Repository) GetSessions(profileID uint64) (sessions []Session, err error) {
+	rows, err := r.Db.Query(`
+		SELECT
+			id, profile_id, device_name, ip_address, expires_at, last_seen_at, revoked_at, created_at
+		FROM
+			sessions
+		WHERE
+			profile_id = $1
+		ORDER BY id DESC`,
+		profileID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+func scanSessions(rows *sql.Rows) (sessions []Session, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.ProfileID,
+			&session.DeviceName,
+			&session.IPAddress,
+			&session.ExpiresAt,
+			&session.LastSeenAt,
+			&session.RevokedAt,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *Repository) RevokeSession(profileID uint64, id uint64) (revoked bool, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE sessions SET
+			revoked_at = $3
+		WHERE
+			id = $2 and profile_id = $1 and revoked_at is null and expires_at > $3`,
+		profileID, id, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) RevokeOtherSessions(profileID uint64, keptID uint64) (revoked int64, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE sessions SET
+			revoked_at = $3
+		WHERE
+			profile_id = $1 and id <> $2 and revoked_at is null and expires_at > $3`,
+		profileID, keptID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *Repository) SuspendProfile(profileID uint64, actorID uint64, reason string) (suspended bool, err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE profiles SET
+			status = 'suspended', status_reason = $3, status_changed_by = $2, status_changed_at = $4
+		WHERE
+			id = $1 and status = 'active' and deleted_at is null`,
+		profileID, actorID, reason, now)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 1 {
+		return false, err
+	}
+
+	// the suspended user is logged out of every device at once
+	_, err = tx.Exec(`
+		UPDATE sessions SET
+			revoked_at = $2
+		WHERE
+			profile_id = $1 and revoked_at is null and expires_at > $2`,
+		profileID, now)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *Repository) UnsuspendProfile(profileID uint64, actorID uint64, reason string) (unsuspended bool, err error) {
+	result, err := r.Db.Exec(`
+		UPDATE profiles SET
+			status = 'active', status_reason = $3, status_changed_by = $2, status_changed_at = $4
+		WHERE
+			id = $1 and status = 'suspended' and deleted_at is null`,
+		profileID, actorID, sql.NullString{String: reason, Valid: reason != ""}, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *Repository) GetProfileRoles(profileID uint64) (roles []string, err error) {
+	rows, err := r.Db.Query(`
+		SELECT
+			role
+		FROM
+			profile_roles
+		WHERE
+			profile_id = $1
+		ORDER BY role`,
+		profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *Repository) CreateSecurityEvent(input SecurityEvent) (err error) {
+	details := []byte("{}")
+	if input.Details != nil {
+		details, err = json.Marshal(input.Details)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.Db.Exec(`
+		INSERT INTO security_events
+			(
+				profile_id,
+				type,
+				ip_address,
+				user_agent,
+				details
+			) VALUES ($1, $2, $3, $4, $5)`,
+		input.ProfileID,
+		input.Type,
+		input.IPAddress,
+		input.UserAgent,
+		details,
+	)
+	return err
+}
+
+func (r *Repository) GetSecurityEvents(profileID uint64, beforeID uint64, limit int) (events []SecurityEvent, err error) {
+	// The IDs increase with time, paging by ID is stable while new events are recorded.
+	// beforeID 0 starts from the latest event.
+	rows, err := r.Db.Query(`
+		SELECT
+			id, profile_id, type, ip_address, user_agent, details, created_at
+		FROM
+			security_events
+		WHERE
+			profile_id = $1 and ($2 = 0 or id < $2)
+		ORDER BY id DESC
+		LIMIT $3`,
+		profileID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event SecurityEvent
+		var details []byte
+		err := rows.Scan(
+			&event.ID,
+			&event.ProfileID,
+			&event.Type,
+			&event.IPAddress,
+			&event.UserAgent,
+			&details,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (r *Repository) GetProfileMetaData(profileID uint64) (metadata ProfileMetaData, err error) {
+	err = r.Db.QueryRow(`
+		SELECT
+			id, profile_id, login_attempt, created_at, updated_at
+		FROM
+			profile_metadata
+		WHERE
+			profile_id = $1`, profileID).Scan(
+		&metadata.ID,
+		&metadata.ProfileID,
+		&metadata.LoginAttempt,
+		&metadata.CreatedAt,
+		&metadata.UpdatedAt,
+	)
+	return metadata, err
 }
 
 func (r *Repository) UpsertProfileMetaData(input ProfileMetaData) (createdID int, err error) {
@@ -182,3 +1112,204 @@ func (r *Repository) UpsertProfileMetaData(input ProfileMetaData) (createdID int
 
 	return createdID, nil
 }
+
+func (r *Repository) CreateDataExport(profileID uint64) (createdID uint64, err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// only the latest export of a profile is kept, the archives of the previous ones are personal data too
+	_, err = tx.Exec(`DELETE FROM data_exports WHERE profile_id = $1`, profileID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO data_exports
+			(profile_id, status)
+		VALUES
+			($1, 'pending')
+		RETURNING id`,
+		profileID).Scan(&createdID)
+	if err != nil {
+		return 0, err
+	}
+
+	return createdID, tx.Commit()
+}
+
+func (r *Repository) GetLatestDataExport(profileID uint64) (export DataExport, err error) {
+	err = r.Db.QueryRow(`
+		SELECT
+			id, profile_id, status, created_at, completed_at, expires_at
+		FROM
+			data_exports
+		WHERE
+			profile_id = $1
+		ORDER BY id DESC
+		LIMIT 1`, profileID).Scan(
+		&export.ID,
+		&export.ProfileID,
+		&export.Status,
+		&export.CreatedAt,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+	)
+	return export, err
+}
+
+func (r *Repository) CompleteDataExport(id uint64, archive []byte, expiresAt time.Time) (err error) {
+	_, err = r.Db.Exec(`
+		UPDATE data_exports SET
+			status = 'ready', archive = $2, completed_at = $3, expires_at = $4
+		WHERE
+			id = $1 and status = 'pending'`,
+		id, archive, time.Now(), expiresAt)
+	return err
+}
+
+func (r *Repository) FailDataExport(id uint64) (err error) {
+	_, err = r.Db.Exec(`
+		UPDATE data_exports SET
+			status = 'failed', completed_at = $2
+		WHERE
+			id = $1 and status = 'pending'`,
+		id, time.Now())
+	return err
+}
+
+// GetDataExportArchive returns the archive of a ready export which hasn't expired, sql.ErrNoRows otherwise.
+// The archives of the profiles which are no longer active can't be downloaded.
+func (r *Repository) GetDataExportArchive(profileID uint64, id uint64) (archive []byte, err error) {
+	err = r.Db.QueryRow(`
+		SELECT
+			e.archive
+		FROM
+			data_exports e
+			JOIN profiles p ON p.id = e.profile_id
+		WHERE
+			e.id = $1 and e.profile_id = $2 and e.status = 'ready' and e.expires_at > $3
+			and p.status = 'active' and p.deleted_at is null`,
+		id, profileID, time.Now()).Scan(&archive)
+	return archive, err
+}
+
+// ReserveIdempotencyKey records the request of the key unless the key is already used. A key is free again once
+// expired, and the request of a key left in progress since abandonedBefore can be retried with the same fingerprint.
+// The stored request of a used key is returned.
+func (r *Repository) ReserveIdempotencyKey(input IdempotencyKey, abandonedBefore time.Time) (reserved bool, stored IdempotencyKey, err error) {
+	now := time.Now()
+	err = r.Db.QueryRow(`
+		INSERT INTO idempotency_keys
+			(scope, key, fingerprint, created_at, expires_at)
+		VALUES
+			($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint, status_code = NULL, header = NULL, body = NULL,
+			created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE
+			idempotency_keys.expires_at <= $4
+			or (idempotency_keys.status_code is null and idempotency_keys.created_at <= $6
+				and idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+		RETURNING key`,
+		input.Scope, input.Key, input.Fingerprint, now, input.ExpiresAt, abandonedBefore).Scan(&stored.Key)
+	if err == nil {
+		return true, stored, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, stored, err
+	}
+
+	var statusCode sql.NullInt64
+	var header []byte
+	err = r.Db.QueryRow(`
+		SELECT
+			scope, key, fingerprint, status_code, header, body, created_at, expires_at
+		FROM
+			idempotency_keys
+		WHERE
+			scope = $1 and key = $2`, input.Scope, input.Key).Scan(
+		&stored.Scope,
+		&stored.Key,
+		&stored.Fingerprint,
+		&statusCode,
+		&header,
+		&stored.Body,
+		&stored.CreatedAt,
+		&stored.ExpiresAt,
+	)
+	if err != nil {
+		return false, stored, err
+	}
+
+	stored.StatusCode = int(statusCode.Int64)
+	if header != nil {
+		if err := json.Unmarshal(header, &stored.Header); err != nil {
+			return false, stored, err
+		}
+	}
+	return false, stored, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(scope string, key string, statusCode int, header map[string][]string, body []byte) (err error) {
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(`
+		UPDATE idempotency_keys SET
+			status_code = $3, header = $4, body = $5
+		WHERE
+			scope = $1 and key = $2`,
+		scope, key, statusCode, encodedHeader, body)
+	return err
+}
+
+func (r *Repository) DeleteIdempotencyKey(scope string, key string) (err error) {
+	_, err = r.Db.Exec(`DELETE FROM idempotency_keys WHERE scope = $1 and key = $2`, scope, key)
+	return err
+}
+
+func (r *Repository) GetLatestProfileAttributeSchema() (schema ProfileAttributeSchema, err error) {
+	var visibility []byte
+	err = r.Db.QueryRow(`
+		SELECT id, schema, visibility, created_by, created_at
+		FROM profile_attribute_schemas
+		ORDER BY id DESC
+		LIMIT 1`,
+	).Scan(&schema.ID, &schema.Schema, &visibility, &schema.CreatedBy, &schema.CreatedAt)
+	if err != nil {
+		return schema, err
+	}
+
+	err = json.Unmarshal(visibility, &schema.Visibility)
+	return schema, err
+}
+
+func (r *Repository) CreateProfileAttributeSchema(input ProfileAttributeSchema) (createdID uint64, err error) {
+	visibility := []byte("{}")
+	if input.Visibility != nil {
+		visibility, err = json.Marshal(input.Visibility)
+		if err != nil {
+			return createdID, err
+		}
+	}
+
+	err = r.Db.QueryRow(`
+		INSERT INTO profile_attribute_schemas
+			(
+				schema,
+				visibility,
+				created_by,
+				created_at
+			) VALUES ($1, $2, $3, $4) RETURNING id`,
+		[]byte(input.Schema),
+		visibility,
+		input.CreatedBy,
+		input.CreatedAt,
+	).Scan(&createdID)
+	return createdID, err
+}
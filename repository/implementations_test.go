@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestRepository connects to the database of TEST_DATABASE_URL with the schema of database.sql applied, the
+// tests are skipped without one
+func setupTestRepository(t *testing.T) *Repository {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL isn't set")
+	}
+
+	schema, err := os.ReadFile("../database.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository := NewRepository(NewRepositoryOptions{Dsn: dsn})
+	t.Cleanup(func() { repository.Db.Close() })
+	if err := repository.ApplySchema(context.Background(), string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+func TestPhoneNumberOfDeletedProfileRegisteredAgain(t *testing.T) {
+	repository := setupTestRepository(t)
+
+	phoneNumber := fmt.Sprintf("8%09d", time.Now().UnixNano()%1e9)
+	t.Cleanup(func() {
+		repository.Db.Exec(`DELETE FROM profiles WHERE country_code = '+62' and phone_number = $1`, phoneNumber)
+	})
+	profile := Profile{FullName: "Bakri", CountryCode: "+62", PhoneNumber: phoneNumber, Password: "hash"}
+
+	deletedID, err := repository.CreateProfile(profile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = repository.CreateProfile(profile)
+	assert.Error(t, err, "the number of a profile not deleted is unique")
+
+	_, err = repository.Db.Exec(`UPDATE profiles SET deleted_at = NOW() WHERE id = $1`, deletedID)
+	assert.NoError(t, err)
+
+	isExist, err := repository.GetPhoneNumberExistence("+62", phoneNumber)
+	assert.NoError(t, err)
+	assert.False(t, isExist)
+
+	createdID, err := repository.CreateProfile(profile)
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, deletedID, createdID)
+	}
+
+	found, err := repository.GetProfileByPhoneNumber("+62", phoneNumber)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(createdID), found.ID)
+	}
+}
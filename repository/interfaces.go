@@ -4,12 +4,60 @@
 // interfaces using mockgen. See the Makefile for more information.
 package repository
 
+import "time"
+
 type RepositoryInterface interface {
-	GetPhoneNumberExistence(phoneNumber string) (isExist bool, err error)
-	GetPhoneNumberExistenceWithExcludedID(phoneNumber string, excludedID int) (isExist bool, err error)
-	GetProfileByPhoneNumber(phoneNumber string) (profile Profile, err error)
+	GetPhoneNumberExistence(countryCode string, phoneNumber string) (isExist bool, err error)
+	GetPhoneNumberExistenceWithExcludedID(countryCode string, phoneNumber string, excludedID int) (isExist bool, err error)
+	GetProfileByPhoneNumber(countryCode string, phoneNumber string) (profile Profile, err error)
+	GetEmailExistenceWithExcludedID(email string, excludedID int) (isExist bool, err error)
+	GetProfileByEmail(email string) (profile Profile, err error)
+	MarkEmailVerificationSent(profileID uint64, email string, sentBefore time.Time) (marked bool, err error)
+	VerifyEmail(profileID uint64, email string) (verified bool, err error)
 	GetProfileByID(id int) (profile Profile, err error)
 	CreateProfile(input Profile) (createdID int, err error)
-	UpdateProfileByID(profile Profile) (err error)
+	ImportProfiles(profiles []Profile, dryRun bool) (duplicates []int, err error)
+	UpdateProfileByID(profile Profile, fields []string, expectedVersion uint64) (updated bool, err error)
+	UpdatePasswordByID(id uint64, password string) (err error)
+	ChangePasswordByID(id uint64, password string) (err error)
+	GetPasswordHistory(profileID uint64, limit int) (passwords []string, err error)
+	GetProfileMFA(profileID uint64) (mfa ProfileMFA, err error)
+	UpsertProfileMFASecret(profileID uint64, secret string) (stored bool, err error)
+	ConfirmProfileMFA(profileID uint64, step int64, recoveryCodeHashes []string) (err error)
+	UseMFAStep(profileID uint64, step int64) (used bool, err error)
+	UseRecoveryCode(profileID uint64, codeHash string) (used bool, err error)
+	RecordMFAFailure(profileID uint64, maxFailures int, lockUntil time.Time) (lockedUntil *time.Time, err error)
+	CreateMFAChallenge(input MFAChallenge) (createdID uint64, err error)
+	IncrementMFAChallengeAttempts(profileID uint64, id uint64) (attempts int, err error)
+	UseMFAChallenge(profileID uint64, id uint64) (used bool, err error)
+	CreateLoginOTP(input LoginOTP) (createdID int, err error)
+	GetLatestLoginOTP(profileID uint64) (otp LoginOTP, err error)
+	IncrementLoginOTPAttempts(id uint64) (attempts int, err error)
+	UseLoginOTP(id uint64) (used bool, err error)
+	ReserveLoginOTPRequest(phoneNumber string, requestedBefore time.Time, windowStartedBefore time.Time, maxPerWindow int) (reserved bool, err error)
+	CreateSession(input Session) (createdID uint64, err error)
+	TouchSession(profileID uint64, id uint64) (active bool, err error)
+	GetActiveSessions(profileID uint64) (sessions []Session, err error)
+	GetSessions(profileID uint64) (sessions []Session, err error)
+	RevokeSession(profileID uint64, id uint64) (revoked bool, err error)
+	RevokeOtherSessions(profileID uint64, keptID uint64) (revoked int64, err error)
+	CreateSecurityEvent(input SecurityEvent) (err error)
+	GetProfileRoles(profileID uint64) (roles []string, err error)
+	ListProfiles(filter ProfileFilter) (profiles []Profile, err error)
+	GetProfileByIDIncludingDeleted(id uint64) (profile Profile, err error)
+	GetProfileMetaData(profileID uint64) (metadata ProfileMetaData, err error)
+	SuspendProfile(profileID uint64, actorID uint64, reason string) (suspended bool, err error)
+	UnsuspendProfile(profileID uint64, actorID uint64, reason string) (unsuspended bool, err error)
+	GetSecurityEvents(profileID uint64, beforeID uint64, limit int) (events []SecurityEvent, err error)
 	UpsertProfileMetaData(input ProfileMetaData) (createdID int, err error)
+	CreateDataExport(profileID uint64) (createdID uint64, err error)
+	GetLatestDataExport(profileID uint64) (export DataExport, err error)
+	CompleteDataExport(id uint64, archive []byte, expiresAt time.Time) (err error)
+	FailDataExport(id uint64) (err error)
+	GetDataExportArchive(profileID uint64, id uint64) (archive []byte, err error)
+	ReserveIdempotencyKey(input IdempotencyKey, abandonedBefore time.Time) (reserved bool, stored IdempotencyKey, err error)
+	CompleteIdempotencyKey(scope string, key string, statusCode int, header map[string][]string, body []byte) (err error)
+	DeleteIdempotencyKey(scope string, key string) (err error)
+	GetLatestProfileAttributeSchema() (schema ProfileAttributeSchema, err error)
+	CreateProfileAttributeSchema(input ProfileAttributeSchema) (createdID uint64, err error)
 }
@@ -6,6 +6,7 @@ package repository
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -33,6 +34,107 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// ChangePasswordByID mocks base method.
+func (m *MockRepositoryInterface) ChangePasswordByID(id uint64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePasswordByID", id, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePasswordByID indicates an expected call of ChangePasswordByID.
+func (mr *MockRepositoryInterfaceMockRecorder) ChangePasswordByID(id, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePasswordByID", reflect.TypeOf((*MockRepositoryInterface)(nil).ChangePasswordByID), id, password)
+}
+
+// CompleteDataExport mocks base method.
+func (m *MockRepositoryInterface) CompleteDataExport(id uint64, archive []byte, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteDataExport", id, archive, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteDataExport indicates an expected call of CompleteDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteDataExport(id, archive, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteDataExport), id, archive, expiresAt)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) CompleteIdempotencyKey(scope, key string, statusCode int, header map[string][]string, body []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", scope, key, statusCode, header, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteIdempotencyKey(scope, key, statusCode, header, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteIdempotencyKey), scope, key, statusCode, header, body)
+}
+
+// ConfirmProfileMFA mocks base method.
+func (m *MockRepositoryInterface) ConfirmProfileMFA(profileID uint64, step int64, recoveryCodeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmProfileMFA", profileID, step, recoveryCodeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmProfileMFA indicates an expected call of ConfirmProfileMFA.
+func (mr *MockRepositoryInterfaceMockRecorder) ConfirmProfileMFA(profileID, step, recoveryCodeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmProfileMFA", reflect.TypeOf((*MockRepositoryInterface)(nil).ConfirmProfileMFA), profileID, step, recoveryCodeHashes)
+}
+
+// CreateDataExport mocks base method.
+func (m *MockRepositoryInterface) CreateDataExport(profileID uint64) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDataExport", profileID)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDataExport indicates an expected call of CreateDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateDataExport(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateDataExport), profileID)
+}
+
+// CreateLoginOTP mocks base method.
+func (m *MockRepositoryInterface) CreateLoginOTP(input LoginOTP) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLoginOTP", input)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLoginOTP indicates an expected call of CreateLoginOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateLoginOTP(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoginOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateLoginOTP), input)
+}
+
+// CreateMFAChallenge mocks base method.
+func (m *MockRepositoryInterface) CreateMFAChallenge(input MFAChallenge) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMFAChallenge", input)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMFAChallenge indicates an expected call of CreateMFAChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateMFAChallenge(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMFAChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateMFAChallenge), input)
+}
+
 // CreateProfile mocks base method.
 func (m *MockRepositoryInterface) CreateProfile(input Profile) (int, error) {
 	m.ctrl.T.Helper()
@@ -48,34 +150,226 @@ func (mr *MockRepositoryInterfaceMockRecorder) CreateProfile(input interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProfile", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateProfile), input)
 }
 
+// CreateProfileAttributeSchema mocks base method.
+func (m *MockRepositoryInterface) CreateProfileAttributeSchema(input ProfileAttributeSchema) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateProfileAttributeSchema", input)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateProfileAttributeSchema indicates an expected call of CreateProfileAttributeSchema.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateProfileAttributeSchema(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProfileAttributeSchema", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateProfileAttributeSchema), input)
+}
+
+// CreateSecurityEvent mocks base method.
+func (m *MockRepositoryInterface) CreateSecurityEvent(input SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSecurityEvent", input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSecurityEvent indicates an expected call of CreateSecurityEvent.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateSecurityEvent(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSecurityEvent", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateSecurityEvent), input)
+}
+
+// CreateSession mocks base method.
+func (m *MockRepositoryInterface) CreateSession(input Session) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", input)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateSession(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateSession), input)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) DeleteIdempotencyKey(scope, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", scope, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteIdempotencyKey(scope, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteIdempotencyKey), scope, key)
+}
+
+// FailDataExport mocks base method.
+func (m *MockRepositoryInterface) FailDataExport(id uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailDataExport", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailDataExport indicates an expected call of FailDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) FailDataExport(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).FailDataExport), id)
+}
+
+// GetActiveSessions mocks base method.
+func (m *MockRepositoryInterface) GetActiveSessions(profileID uint64) ([]Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveSessions", profileID)
+	ret0, _ := ret[0].([]Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveSessions indicates an expected call of GetActiveSessions.
+func (mr *MockRepositoryInterfaceMockRecorder) GetActiveSessions(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveSessions", reflect.TypeOf((*MockRepositoryInterface)(nil).GetActiveSessions), profileID)
+}
+
+// GetDataExportArchive mocks base method.
+func (m *MockRepositoryInterface) GetDataExportArchive(profileID, id uint64) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataExportArchive", profileID, id)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataExportArchive indicates an expected call of GetDataExportArchive.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDataExportArchive(profileID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataExportArchive", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDataExportArchive), profileID, id)
+}
+
+// GetEmailExistenceWithExcludedID mocks base method.
+func (m *MockRepositoryInterface) GetEmailExistenceWithExcludedID(email string, excludedID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailExistenceWithExcludedID", email, excludedID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailExistenceWithExcludedID indicates an expected call of GetEmailExistenceWithExcludedID.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEmailExistenceWithExcludedID(email, excludedID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailExistenceWithExcludedID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEmailExistenceWithExcludedID), email, excludedID)
+}
+
+// GetLatestDataExport mocks base method.
+func (m *MockRepositoryInterface) GetLatestDataExport(profileID uint64) (DataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestDataExport", profileID)
+	ret0, _ := ret[0].(DataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestDataExport indicates an expected call of GetLatestDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLatestDataExport(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLatestDataExport), profileID)
+}
+
+// GetLatestLoginOTP mocks base method.
+func (m *MockRepositoryInterface) GetLatestLoginOTP(profileID uint64) (LoginOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestLoginOTP", profileID)
+	ret0, _ := ret[0].(LoginOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestLoginOTP indicates an expected call of GetLatestLoginOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLatestLoginOTP(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestLoginOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLatestLoginOTP), profileID)
+}
+
+// GetLatestProfileAttributeSchema mocks base method.
+func (m *MockRepositoryInterface) GetLatestProfileAttributeSchema() (ProfileAttributeSchema, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestProfileAttributeSchema")
+	ret0, _ := ret[0].(ProfileAttributeSchema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestProfileAttributeSchema indicates an expected call of GetLatestProfileAttributeSchema.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLatestProfileAttributeSchema() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestProfileAttributeSchema", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLatestProfileAttributeSchema))
+}
+
+// GetPasswordHistory mocks base method.
+func (m *MockRepositoryInterface) GetPasswordHistory(profileID uint64, limit int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistory", profileID, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistory indicates an expected call of GetPasswordHistory.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordHistory(profileID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistory", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordHistory), profileID, limit)
+}
+
 // GetPhoneNumberExistence mocks base method.
-func (m *MockRepositoryInterface) GetPhoneNumberExistence(phoneNumber string) (bool, error) {
+func (m *MockRepositoryInterface) GetPhoneNumberExistence(countryCode, phoneNumber string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPhoneNumberExistence", phoneNumber)
+	ret := m.ctrl.Call(m, "GetPhoneNumberExistence", countryCode, phoneNumber)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPhoneNumberExistence indicates an expected call of GetPhoneNumberExistence.
-func (mr *MockRepositoryInterfaceMockRecorder) GetPhoneNumberExistence(phoneNumber interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) GetPhoneNumberExistence(countryCode, phoneNumber interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPhoneNumberExistence", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPhoneNumberExistence), phoneNumber)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPhoneNumberExistence", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPhoneNumberExistence), countryCode, phoneNumber)
 }
 
 // GetPhoneNumberExistenceWithExcludedID mocks base method.
-func (m *MockRepositoryInterface) GetPhoneNumberExistenceWithExcludedID(phoneNumber string, excludedID int) (bool, error) {
+func (m *MockRepositoryInterface) GetPhoneNumberExistenceWithExcludedID(countryCode, phoneNumber string, excludedID int) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPhoneNumberExistenceWithExcludedID", phoneNumber, excludedID)
+	ret := m.ctrl.Call(m, "GetPhoneNumberExistenceWithExcludedID", countryCode, phoneNumber, excludedID)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPhoneNumberExistenceWithExcludedID indicates an expected call of GetPhoneNumberExistenceWithExcludedID.
-func (mr *MockRepositoryInterfaceMockRecorder) GetPhoneNumberExistenceWithExcludedID(phoneNumber, excludedID interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) GetPhoneNumberExistenceWithExcludedID(countryCode, phoneNumber, excludedID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPhoneNumberExistenceWithExcludedID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPhoneNumberExistenceWithExcludedID), countryCode, phoneNumber, excludedID)
+}
+
+// GetProfileByEmail mocks base method.
+func (m *MockRepositoryInterface) GetProfileByEmail(email string) (Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfileByEmail", email)
+	ret0, _ := ret[0].(Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfileByEmail indicates an expected call of GetProfileByEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) GetProfileByEmail(email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPhoneNumberExistenceWithExcludedID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPhoneNumberExistenceWithExcludedID), phoneNumber, excludedID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileByEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileByEmail), email)
 }
 
 // GetProfileByID mocks base method.
@@ -93,33 +387,349 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetProfileByID(id interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileByID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileByID), id)
 }
 
+// GetProfileByIDIncludingDeleted mocks base method.
+func (m *MockRepositoryInterface) GetProfileByIDIncludingDeleted(id uint64) (Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfileByIDIncludingDeleted", id)
+	ret0, _ := ret[0].(Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfileByIDIncludingDeleted indicates an expected call of GetProfileByIDIncludingDeleted.
+func (mr *MockRepositoryInterfaceMockRecorder) GetProfileByIDIncludingDeleted(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileByIDIncludingDeleted", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileByIDIncludingDeleted), id)
+}
+
 // GetProfileByPhoneNumber mocks base method.
-func (m *MockRepositoryInterface) GetProfileByPhoneNumber(phoneNumber string) (Profile, error) {
+func (m *MockRepositoryInterface) GetProfileByPhoneNumber(countryCode, phoneNumber string) (Profile, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetProfileByPhoneNumber", phoneNumber)
+	ret := m.ctrl.Call(m, "GetProfileByPhoneNumber", countryCode, phoneNumber)
 	ret0, _ := ret[0].(Profile)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetProfileByPhoneNumber indicates an expected call of GetProfileByPhoneNumber.
-func (mr *MockRepositoryInterfaceMockRecorder) GetProfileByPhoneNumber(phoneNumber interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) GetProfileByPhoneNumber(countryCode, phoneNumber interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileByPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileByPhoneNumber), phoneNumber)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileByPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileByPhoneNumber), countryCode, phoneNumber)
 }
 
-// UpdateProfileByID mocks base method.
-func (m *MockRepositoryInterface) UpdateProfileByID(profile Profile) error {
+// GetProfileMFA mocks base method.
+func (m *MockRepositoryInterface) GetProfileMFA(profileID uint64) (ProfileMFA, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfileMFA", profileID)
+	ret0, _ := ret[0].(ProfileMFA)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfileMFA indicates an expected call of GetProfileMFA.
+func (mr *MockRepositoryInterfaceMockRecorder) GetProfileMFA(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileMFA", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileMFA), profileID)
+}
+
+// GetProfileMetaData mocks base method.
+func (m *MockRepositoryInterface) GetProfileMetaData(profileID uint64) (ProfileMetaData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfileMetaData", profileID)
+	ret0, _ := ret[0].(ProfileMetaData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfileMetaData indicates an expected call of GetProfileMetaData.
+func (mr *MockRepositoryInterfaceMockRecorder) GetProfileMetaData(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileMetaData", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileMetaData), profileID)
+}
+
+// GetProfileRoles mocks base method.
+func (m *MockRepositoryInterface) GetProfileRoles(profileID uint64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProfileRoles", profileID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProfileRoles indicates an expected call of GetProfileRoles.
+func (mr *MockRepositoryInterfaceMockRecorder) GetProfileRoles(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfileRoles", reflect.TypeOf((*MockRepositoryInterface)(nil).GetProfileRoles), profileID)
+}
+
+// GetSecurityEvents mocks base method.
+func (m *MockRepositoryInterface) GetSecurityEvents(profileID, beforeID uint64, limit int) ([]SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecurityEvents", profileID, beforeID, limit)
+	ret0, _ := ret[0].([]SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecurityEvents indicates an expected call of GetSecurityEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) GetSecurityEvents(profileID, beforeID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecurityEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).GetSecurityEvents), profileID, beforeID, limit)
+}
+
+// GetSessions mocks base method.
+func (m *MockRepositoryInterface) GetSessions(profileID uint64) ([]Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", profileID)
+	ret0, _ := ret[0].([]Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockRepositoryInterfaceMockRecorder) GetSessions(profileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockRepositoryInterface)(nil).GetSessions), profileID)
+}
+
+// ImportProfiles mocks base method.
+func (m *MockRepositoryInterface) ImportProfiles(profiles []Profile, dryRun bool) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportProfiles", profiles, dryRun)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportProfiles indicates an expected call of ImportProfiles.
+func (mr *MockRepositoryInterfaceMockRecorder) ImportProfiles(profiles, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportProfiles", reflect.TypeOf((*MockRepositoryInterface)(nil).ImportProfiles), profiles, dryRun)
+}
+
+// IncrementLoginOTPAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementLoginOTPAttempts(id uint64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementLoginOTPAttempts", id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementLoginOTPAttempts indicates an expected call of IncrementLoginOTPAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementLoginOTPAttempts(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementLoginOTPAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementLoginOTPAttempts), id)
+}
+
+// IncrementMFAChallengeAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementMFAChallengeAttempts(profileID, id uint64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementMFAChallengeAttempts", profileID, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementMFAChallengeAttempts indicates an expected call of IncrementMFAChallengeAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementMFAChallengeAttempts(profileID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementMFAChallengeAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementMFAChallengeAttempts), profileID, id)
+}
+
+// ListProfiles mocks base method.
+func (m *MockRepositoryInterface) ListProfiles(filter ProfileFilter) ([]Profile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProfiles", filter)
+	ret0, _ := ret[0].([]Profile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProfiles indicates an expected call of ListProfiles.
+func (mr *MockRepositoryInterfaceMockRecorder) ListProfiles(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProfiles", reflect.TypeOf((*MockRepositoryInterface)(nil).ListProfiles), filter)
+}
+
+// MarkEmailVerificationSent mocks base method.
+func (m *MockRepositoryInterface) MarkEmailVerificationSent(profileID uint64, email string, sentBefore time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEmailVerificationSent", profileID, email, sentBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkEmailVerificationSent indicates an expected call of MarkEmailVerificationSent.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkEmailVerificationSent(profileID, email, sentBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEmailVerificationSent", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkEmailVerificationSent), profileID, email, sentBefore)
+}
+
+// RecordMFAFailure mocks base method.
+func (m *MockRepositoryInterface) RecordMFAFailure(profileID uint64, maxFailures int, lockUntil time.Time) (*time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordMFAFailure", profileID, maxFailures, lockUntil)
+	ret0, _ := ret[0].(*time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordMFAFailure indicates an expected call of RecordMFAFailure.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordMFAFailure(profileID, maxFailures, lockUntil interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordMFAFailure", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordMFAFailure), profileID, maxFailures, lockUntil)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) ReserveIdempotencyKey(input IdempotencyKey, abandonedBefore time.Time) (bool, IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", input, abandonedBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(IdempotencyKey)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) ReserveIdempotencyKey(input, abandonedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).ReserveIdempotencyKey), input, abandonedBefore)
+}
+
+// ReserveLoginOTPRequest mocks base method.
+func (m *MockRepositoryInterface) ReserveLoginOTPRequest(phoneNumber string, requestedBefore, windowStartedBefore time.Time, maxPerWindow int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveLoginOTPRequest", phoneNumber, requestedBefore, windowStartedBefore, maxPerWindow)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReserveLoginOTPRequest indicates an expected call of ReserveLoginOTPRequest.
+func (mr *MockRepositoryInterfaceMockRecorder) ReserveLoginOTPRequest(phoneNumber, requestedBefore, windowStartedBefore, maxPerWindow interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveLoginOTPRequest", reflect.TypeOf((*MockRepositoryInterface)(nil).ReserveLoginOTPRequest), phoneNumber, requestedBefore, windowStartedBefore, maxPerWindow)
+}
+
+// RevokeOtherSessions mocks base method.
+func (m *MockRepositoryInterface) RevokeOtherSessions(profileID, keptID uint64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOtherSessions", profileID, keptID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeOtherSessions indicates an expected call of RevokeOtherSessions.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeOtherSessions(profileID, keptID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOtherSessions", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeOtherSessions), profileID, keptID)
+}
+
+// RevokeSession mocks base method.
+func (m *MockRepositoryInterface) RevokeSession(profileID, id uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", profileID, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeSession(profileID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeSession), profileID, id)
+}
+
+// SuspendProfile mocks base method.
+func (m *MockRepositoryInterface) SuspendProfile(profileID, actorID uint64, reason string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateProfileByID", profile)
+	ret := m.ctrl.Call(m, "SuspendProfile", profileID, actorID, reason)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SuspendProfile indicates an expected call of SuspendProfile.
+func (mr *MockRepositoryInterfaceMockRecorder) SuspendProfile(profileID, actorID, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SuspendProfile", reflect.TypeOf((*MockRepositoryInterface)(nil).SuspendProfile), profileID, actorID, reason)
+}
+
+// TouchSession mocks base method.
+func (m *MockRepositoryInterface) TouchSession(profileID, id uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", profileID, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockRepositoryInterfaceMockRecorder) TouchSession(profileID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockRepositoryInterface)(nil).TouchSession), profileID, id)
+}
+
+// UnsuspendProfile mocks base method.
+func (m *MockRepositoryInterface) UnsuspendProfile(profileID, actorID uint64, reason string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnsuspendProfile", profileID, actorID, reason)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnsuspendProfile indicates an expected call of UnsuspendProfile.
+func (mr *MockRepositoryInterfaceMockRecorder) UnsuspendProfile(profileID, actorID, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsuspendProfile", reflect.TypeOf((*MockRepositoryInterface)(nil).UnsuspendProfile), profileID, actorID, reason)
+}
+
+// UpdatePasswordByID mocks base method.
+func (m *MockRepositoryInterface) UpdatePasswordByID(id uint64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePasswordByID", id, password)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// UpdatePasswordByID indicates an expected call of UpdatePasswordByID.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdatePasswordByID(id, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePasswordByID", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdatePasswordByID), id, password)
+}
+
+// UpdateProfileByID mocks base method.
+func (m *MockRepositoryInterface) UpdateProfileByID(profile Profile, fields []string, expectedVersion uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfileByID", profile, fields, expectedVersion)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // UpdateProfileByID indicates an expected call of UpdateProfileByID.
-func (mr *MockRepositoryInterfaceMockRecorder) UpdateProfileByID(profile interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateProfileByID(profile, fields, expectedVersion interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfileByID", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateProfileByID), profile)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfileByID", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateProfileByID), profile, fields, expectedVersion)
+}
+
+// UpsertProfileMFASecret mocks base method.
+func (m *MockRepositoryInterface) UpsertProfileMFASecret(profileID uint64, secret string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertProfileMFASecret", profileID, secret)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertProfileMFASecret indicates an expected call of UpsertProfileMFASecret.
+func (mr *MockRepositoryInterfaceMockRecorder) UpsertProfileMFASecret(profileID, secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertProfileMFASecret", reflect.TypeOf((*MockRepositoryInterface)(nil).UpsertProfileMFASecret), profileID, secret)
 }
 
 // UpsertProfileMetaData mocks base method.
@@ -136,3 +746,78 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpsertProfileMetaData(input inter
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertProfileMetaData", reflect.TypeOf((*MockRepositoryInterface)(nil).UpsertProfileMetaData), input)
 }
+
+// UseLoginOTP mocks base method.
+func (m *MockRepositoryInterface) UseLoginOTP(id uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseLoginOTP", id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseLoginOTP indicates an expected call of UseLoginOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) UseLoginOTP(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseLoginOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).UseLoginOTP), id)
+}
+
+// UseMFAChallenge mocks base method.
+func (m *MockRepositoryInterface) UseMFAChallenge(profileID, id uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMFAChallenge", profileID, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMFAChallenge indicates an expected call of UseMFAChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) UseMFAChallenge(profileID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMFAChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).UseMFAChallenge), profileID, id)
+}
+
+// UseMFAStep mocks base method.
+func (m *MockRepositoryInterface) UseMFAStep(profileID uint64, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMFAStep", profileID, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMFAStep indicates an expected call of UseMFAStep.
+func (mr *MockRepositoryInterfaceMockRecorder) UseMFAStep(profileID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMFAStep", reflect.TypeOf((*MockRepositoryInterface)(nil).UseMFAStep), profileID, step)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockRepositoryInterface) UseRecoveryCode(profileID uint64, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", profileID, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockRepositoryInterfaceMockRecorder) UseRecoveryCode(profileID, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockRepositoryInterface)(nil).UseRecoveryCode), profileID, codeHash)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockRepositoryInterface) VerifyEmail(profileID uint64, email string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", profileID, email)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) VerifyEmail(profileID, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).VerifyEmail), profileID, email)
+}
@@ -2,6 +2,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/lib/pq"
@@ -24,3 +25,24 @@ func NewRepository(opts NewRepositoryOptions) *Repository {
 		Db: db,
 	}
 }
+
+// schemaLockID serializes the schema changes of the instances deployed at the same time
+const schemaLockID = 7170616
+
+// ApplySchema runs the statements of schema, e.g. database.sql, in a single transaction. The schema has to be
+// re-runnable: it's applied on every deploy to the databases created by any of its previous versions.
+func (r *Repository) ApplySchema(ctx context.Context, schema string) error {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", schemaLockID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,7 +1,11 @@
 // This file contains types that are used in the repository layer.
 package repository
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
 
 // Profile, representing profile object on repository
 type Profile struct {
@@ -13,8 +17,48 @@ type Profile struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   *time.Time `json:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at"`
+	// Status is one of the ProfileStatus values, the reason and the operator of its last change are kept with it
+	Status          string     `json:"status"`
+	StatusReason    *string    `json:"status_reason"`
+	StatusChangedBy *uint64    `json:"status_changed_by"`
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+	// Version is incremented by every update of the profile, see UpdateProfileByID
+	Version uint64 `json:"version"`
+	// The optional attributes are nil when not given, EmailVerifiedAt is cleared by every change of the email
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	DateOfBirth     *time.Time `json:"date_of_birth"`
+	Gender          *string    `json:"gender"`
+	Locale          *string    `json:"locale"`
+	Timezone        *string    `json:"timezone"`
+	// Attributes are the custom attributes of the profile, validated against the latest ProfileAttributeSchema
+	Attributes map[string]interface{} `json:"attributes"`
+	// AvatarKey is the prefix of the keys of the avatar thumbnails in the blob store, nil without an avatar
+	AvatarKey *string `json:"avatar_key"`
 }
 
+// Fields of the profile written by UpdateProfileByID, ProfileFieldPhoneNumber writes the country code too
+const (
+	ProfileFieldFullName    = "full_name"
+	ProfileFieldPhoneNumber = "phone_number"
+	ProfileFieldEmail       = "email"
+	ProfileFieldDateOfBirth = "date_of_birth"
+	ProfileFieldGender      = "gender"
+	ProfileFieldLocale      = "locale"
+	ProfileFieldTimezone    = "timezone"
+	ProfileFieldAttributes  = "attributes"
+	ProfileFieldAvatar      = "avatar"
+)
+
+// Statuses of Profile
+const (
+	ProfileStatusActive    = "active"
+	ProfileStatusSuspended = "suspended"
+)
+
+// ErrEmailTaken is returned by VerifyEmail for an email another profile has verified
+var ErrEmailTaken = errors.New("email verified by another profile")
+
 type ProfileMetaData struct {
 	ID           uint64     `json:"id"`
 	ProfileID    uint64     `json:"profile_id"`
@@ -22,3 +66,167 @@ type ProfileMetaData struct {
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    *time.Time `json:"updated_at"`
 }
+
+// ProfileMFA is the TOTP authenticator of a profile, it protects the login once ConfirmedAt is set.
+// Secret is encrypted, see totp.SecretCipher. The second factor is refused until LockedUntil once too many
+// wrong codes were entered.
+type ProfileMFA struct {
+	ProfileID      uint64     `json:"profile_id"`
+	Secret         string     `json:"secret"`
+	LastUsedStep   int64      `json:"last_used_step"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	ConfirmedAt    *time.Time `json:"confirmed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at"`
+}
+
+// MFAChallenge is a login waiting for its second factor, its challenge token carries the ID
+type MFAChallenge struct {
+	ID        uint64     `json:"id"`
+	ProfileID uint64     `json:"profile_id"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginOTP is a one-time code sent by SMS for a passwordless login, only its hash is stored
+type LoginOTP struct {
+	ID        uint64     `json:"id"`
+	ProfileID uint64     `json:"profile_id"`
+	CodeHash  string     `json:"code_hash"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is a login of a profile on a device, the access tokens carry its ID and stop working once it's revoked
+type Session struct {
+	ID         uint64     `json:"id"`
+	ProfileID  uint64     `json:"profile_id"`
+	DeviceName string     `json:"device_name"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Types of SecurityEvent
+const (
+	SecurityEventLoginSucceeded     = "login_succeeded"
+	SecurityEventLoginFailed        = "login_failed"
+	SecurityEventPasswordChanged    = "password_changed"
+	SecurityEventPhoneNumberChanged = "phone_number_changed"
+	SecurityEventEmailVerified      = "email_verified"
+	SecurityEventProfileSuspended   = "profile_suspended"
+	SecurityEventProfileUnsuspended = "profile_unsuspended"
+)
+
+// SecurityEvent is an entry of the append-only audit trail of a profile
+type SecurityEvent struct {
+	ID        uint64 `json:"id"`
+	ProfileID uint64 `json:"profile_id"`
+	Type      string `json:"type"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	// Details describe the event, e.g. the login method or the previous phone number
+	Details   map[string]string `json:"details"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Orders of ListProfiles
+const (
+	ProfileSortCreatedAtDesc = "created_at_desc"
+	ProfileSortCreatedAtAsc  = "created_at_asc"
+	ProfileSortFullNameAsc   = "full_name_asc"
+	ProfileSortFullNameDesc  = "full_name_desc"
+)
+
+// Deleted profile filters of ListProfiles
+const (
+	DeletedExclude = "exclude"
+	DeletedInclude = "include"
+	DeletedOnly    = "only"
+)
+
+// ProfileFilter selects the profiles returned by ListProfiles, the empty fields don't filter
+type ProfileFilter struct {
+	// NamePrefix matches the start of the full name, case insensitive
+	NamePrefix  string
+	CountryCode string
+	PhoneNumber string
+	CreatedFrom *time.Time
+	// CreatedTo is exclusive
+	CreatedTo *time.Time
+	// Deleted is one of DeletedExclude (default), DeletedInclude and DeletedOnly
+	Deleted string
+	// Status is one of the ProfileStatus values
+	Status string
+	// Sort is one of the ProfileSort orders, ProfileSortCreatedAtDesc by default
+	Sort string
+	// After is the last profile of the previous page
+	After *ProfileCursor
+	Limit int
+}
+
+// ProfileCursor is the position of a profile in the order of ListProfiles, it holds the sorted value of the profile
+// and its ID to break ties
+type ProfileCursor struct {
+	ID        uint64    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	FullName  string    `json:"full_name"`
+}
+
+// Statuses of DataExport
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is a copy of the personal data of a profile. It is generated in the background and can be
+// downloaded until ExpiresAt once ready.
+type DataExport struct {
+	ID          uint64     `json:"id"`
+	ProfileID   uint64     `json:"profile_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// IdempotencyKey is a request made with an Idempotency-Key header and its response, which is replayed to the
+// retries of the request. StatusCode is 0 while the request is in progress.
+type IdempotencyKey struct {
+	// Scope is the owner of the key, e.g. a profile, the keys of different scopes don't collide
+	Scope string `json:"scope"`
+	Key   string `json:"key"`
+	// Fingerprint identifies the request, a key can't be reused for another request
+	Fingerprint string              `json:"fingerprint"`
+	StatusCode  int                 `json:"status_code"`
+	Header      map[string][]string `json:"header"`
+	Body        []byte              `json:"body"`
+	CreatedAt   time.Time           `json:"created_at"`
+	ExpiresAt   time.Time           `json:"expires_at"`
+}
+
+// Visibilities of the custom attributes of the profiles for their users, the operators see and write every attribute
+const (
+	AttributeVisibilityReadWrite = "read_write"
+	AttributeVisibilityReadOnly  = "read_only"
+	AttributeVisibilityHidden    = "hidden"
+)
+
+// ProfileAttributeSchema is a version of the schema of the custom attributes of the profiles
+type ProfileAttributeSchema struct {
+	ID uint64 `json:"id"`
+	// Schema is the OpenAPI schema of the attributes, an object with an attribute per property
+	Schema json.RawMessage `json:"schema"`
+	// Visibility maps the attributes to one of the AttributeVisibility values, AttributeVisibilityReadWrite when absent
+	Visibility map[string]string `json:"visibility"`
+	CreatedBy  *uint64           `json:"created_by"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
@@ -0,0 +1,76 @@
+// Package sms sends text messages to phone numbers. The providers implement Sender,
+// LogSender and FileSender are meant for development and tests.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender delivers a text message to a phone number in E.164 format
+type Sender interface {
+	Send(ctx context.Context, phoneNumber string, message string) error
+}
+
+// LogSender writes the messages to the standard logger instead of sending them
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, phoneNumber string, message string) error {
+	log.Printf("sms to %s : %s", phoneNumber, message)
+	return nil
+}
+
+// Message is a message written by FileSender
+type Message struct {
+	PhoneNumber string    `json:"phone_number"`
+	Message     string    `json:"message"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// FileSender appends the messages to a file, one JSON object per line
+type FileSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileSender) Send(ctx context.Context, phoneNumber string, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(Message{
+		PhoneNumber: phoneNumber,
+		Message:     message,
+		SentAt:      time.Now(),
+	})
+}
+
+// ReadMessages returns the messages written by a FileSender to path
+func ReadMessages(path string) ([]Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := []Message{}
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var message Message
+		if err := decoder.Decode(&message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
@@ -0,0 +1,35 @@
+package sms
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.jsonl")
+	sender := &FileSender{Path: path}
+
+	assert.NoError(t, sender.Send(context.Background(), "+6289627117", "first"))
+	assert.NoError(t, sender.Send(context.Background(), "+60123456789", "second"))
+
+	messages, err := ReadMessages(path)
+	if assert.NoError(t, err) && assert.Len(t, messages, 2) {
+		assert.Equal(t, "+6289627117", messages[0].PhoneNumber)
+		assert.Equal(t, "first", messages[0].Message)
+		assert.Equal(t, "+60123456789", messages[1].PhoneNumber)
+		assert.False(t, messages[1].SentAt.IsZero())
+	}
+}
+
+func TestFileSenderUnwritablePath(t *testing.T) {
+	sender := &FileSender{Path: filepath.Join(t.TempDir(), "missing", "sms.jsonl")}
+	assert.Error(t, sender.Send(context.Background(), "+6289627117", "message"))
+}
+
+func TestLogSender(t *testing.T) {
+	var sender Sender = LogSender{}
+	assert.NoError(t, sender.Send(context.Background(), "+6289627117", "message"))
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store writes the blobs to a bucket of an S3-compatible object storage, e.g. Amazon S3 or MinIO. The requests
+// use the path-style addressing and are signed with AWS Signature Version 4.
+type S3Store struct {
+	// Endpoint is the URL of the service, e.g. https://s3.ap-southeast-1.amazonaws.com or http://minio:9000, a path
+	// in it, e.g. of a reverse proxy, prefixes the paths of the buckets
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL is the URL the clients download the blobs from, e.g. a CDN in front of the bucket, Endpoint/Bucket
+	// by default. The bucket has to allow their anonymous reads.
+	PublicURL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+
+	// now is the time of the signatures, replaced by the tests
+	now func() time.Time
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, contentType string, content []byte) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	return s.do(ctx, http.MethodPut, key, header, content)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	// deleting a missing object succeeds too
+	return s.do(ctx, http.MethodDelete, key, http.Header{}, nil)
+}
+
+func (s *S3Store) URL(key string) string {
+	base := s.PublicURL
+	if base == "" {
+		base = strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket
+	}
+	return strings.TrimSuffix(base, "/") + "/" + s3EscapePath(key)
+}
+
+// do sends a signed request on the object of key, the responses other than 2xx are errors
+func (s *S3Store) do(ctx context.Context, method string, key string, header http.Header, body []byte) error {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return fmt.Errorf("storage: invalid endpoint: %w", err)
+	}
+	// the signed path has to be the one the service receives, prefix included
+	objectPath := strings.TrimSuffix(endpoint.EscapedPath(), "/") + "/" + s3EscapePath(s.Bucket+"/"+key)
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.Scheme+"://"+endpoint.Host+objectPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, objectPath, body)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("storage: %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 of the request to its Authorization header, the signed headers are the host,
+// the hash of the body and the date
+func (s *S3Store) sign(req *http.Request, escapedPath string, body []byte) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signedAt := now().UTC()
+	amzDate := signedAt.Format("20060102T150405Z")
+	day := signedAt.Format("20060102")
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + req.Header.Get("X-Amz-Content-Sha256"),
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), day)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath percent-encodes the bytes of the path but the unreserved characters and the slashes, as required by
+// the canonical requests
+func s3EscapePath(p string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-_.~/", c) >= 0:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
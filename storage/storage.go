@@ -0,0 +1,91 @@
+// Package storage keeps the files uploaded by the users, e.g. the avatars. The stores implement BlobStore,
+// LocalStore writes to a directory served by the service and S3Store to any S3-compatible object storage.
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore keeps blobs under slash separated keys, e.g. avatars/12/9f3c-512.jpg
+type BlobStore interface {
+	// Put writes the content under key, replacing the blob already there
+	Put(ctx context.Context, key string, contentType string, content []byte) error
+	// Delete removes the blob of key, deleting a missing blob isn't an error
+	Delete(ctx context.Context, key string) error
+	// URL is the address the clients download the blob of key from
+	URL(key string) string
+}
+
+// ErrInvalidKey is returned for the keys which aren't clean relative paths, e.g. ../secret
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// validKey reports whether key is a clean relative path, so it can't escape the directory or bucket of the store
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// LocalStore writes the blobs to the files of a directory, the service serves them under BaseURL
+type LocalStore struct {
+	Dir string
+	// BaseURL is the URL of the directory, e.g. /blobs or https://cdn.example.com/blobs
+	BaseURL string
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, contentType string, content []byte) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	name := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	// the blob is written aside and renamed, the readers never see a partial file
+	file, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStore) URL(key string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key
+}
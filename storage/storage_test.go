@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store := &LocalStore{Dir: dir, BaseURL: "/blobs/"}
+	var _ BlobStore = store
+
+	assert.NoError(t, store.Put(context.Background(), "avatars/12/9f3c-512.jpg", "image/jpeg", []byte("first")))
+	assert.NoError(t, store.Put(context.Background(), "avatars/12/9f3c-512.jpg", "image/jpeg", []byte("second")))
+
+	content, err := os.ReadFile(filepath.Join(dir, "avatars", "12", "9f3c-512.jpg"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "second", string(content))
+	}
+	assert.Equal(t, "/blobs/avatars/12/9f3c-512.jpg", store.URL("avatars/12/9f3c-512.jpg"))
+
+	assert.NoError(t, store.Delete(context.Background(), "avatars/12/9f3c-512.jpg"))
+	assert.NoFileExists(t, filepath.Join(dir, "avatars", "12", "9f3c-512.jpg"))
+	assert.NoError(t, store.Delete(context.Background(), "avatars/12/9f3c-512.jpg"))
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "avatars/../../secret", "avatars//12"} {
+		assert.ErrorIs(t, store.Put(context.Background(), key, "text/plain", []byte("x")), ErrInvalidKey, key)
+		assert.ErrorIs(t, store.Delete(context.Background(), key), ErrInvalidKey, key)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store := &S3Store{
+		Endpoint:        server.URL,
+		Region:          "ap-southeast-1",
+		Bucket:          "profiles",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		now:             func() time.Time { return time.Date(2026, time.October, 19, 8, 30, 0, 0, time.UTC) },
+	}
+
+	assert.NoError(t, store.Put(context.Background(), "avatars/12/9f3c-512.jpg", "image/jpeg", []byte("picture")))
+	assert.NoError(t, store.Delete(context.Background(), "avatars/12/9f3c-512.jpg"))
+
+	if assert.Len(t, requests, 2) {
+		put := requests[0]
+		assert.Equal(t, http.MethodPut, put.Method)
+		assert.Equal(t, "/profiles/avatars/12/9f3c-512.jpg", put.URL.Path)
+		assert.Equal(t, "picture", bodies[0])
+		assert.Equal(t, "image/jpeg", put.Header.Get("Content-Type"))
+		assert.Equal(t, "20261019T083000Z", put.Header.Get("X-Amz-Date"))
+		assert.True(t, strings.HasPrefix(put.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261019/ap-southeast-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="))
+
+		assert.Equal(t, http.MethodDelete, requests[1].Method)
+		assert.NotEqual(t, put.Header.Get("Authorization"), requests[1].Header.Get("Authorization"))
+	}
+
+	assert.Equal(t, server.URL+"/profiles/avatars/12/9f3c-512.jpg", store.URL("avatars/12/9f3c-512.jpg"))
+	store.PublicURL = "https://cdn.example.com/"
+	assert.Equal(t, "https://cdn.example.com/avatars/12/a%20b.jpg", store.URL("avatars/12/a b.jpg"))
+}
+
+func TestS3StoreEndpointPath(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+	}))
+	defer server.Close()
+
+	store := &S3Store{Endpoint: server.URL + "/minio/", Region: "us-east-1", Bucket: "profiles"}
+	assert.NoError(t, store.Put(context.Background(), "avatars/12/a b.jpg", "image/jpeg", []byte("picture")))
+	assert.Equal(t, []string{"/minio/profiles/avatars/12/a%20b.jpg"}, paths)
+	assert.Equal(t, server.URL+"/minio/profiles/avatars/12/a%20b.jpg", store.URL("avatars/12/a b.jpg"))
+}
+
+func TestS3StoreError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+	}))
+	defer server.Close()
+
+	store := &S3Store{Endpoint: server.URL, Region: "us-east-1", Bucket: "profiles"}
+	err := store.Put(context.Background(), "avatars/12/9f3c-512.jpg", "image/jpeg", []byte("picture"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "SignatureDoesNotMatch")
+	}
+}
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// SecretKeyLength is the size of the key of a SecretCipher, AES-256
+const SecretKeyLength = 32
+
+// encryptedPrefix marks the encrypted secrets, the secrets stored before they were encrypted are plain base32
+const encryptedPrefix = "v1:"
+
+// ErrInvalidEncryptedSecret is returned for a stored secret which can't be decrypted with the key
+var ErrInvalidEncryptedSecret = errors.New("invalid encrypted secret")
+
+// SecretCipher encrypts the shared secrets at rest with AES-256-GCM. A secret is bound to its owner, e.g. the ID of
+// the profile, and can't be decrypted as the secret of another one.
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher creates a cipher with a key of SecretKeyLength bytes
+func NewSecretCipher(key []byte) (*SecretCipher, error) {
+	if len(key) != SecretKeyLength {
+		return nil, errors.New("the secret key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Encrypt returns the stored form of secret
+func (c *SecretCipher) Encrypt(secret string, owner string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), []byte(owner))
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the secret of its stored form, the secrets stored before the encryption are returned as they are
+func (c *SecretCipher) Decrypt(stored string, owner string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidEncryptedSecret
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, []byte(owner))
+	if err != nil {
+		return "", ErrInvalidEncryptedSecret
+	}
+	return string(secret), nil
+}
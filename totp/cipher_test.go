@@ -0,0 +1,51 @@
+package totp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretCipher(t *testing.T) {
+	cipher, err := NewSecretCipher(bytes.Repeat([]byte{7}, SecretKeyLength))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stored, err := cipher.Encrypt("JBSWY3DPEHPK3PXP", "12")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(stored, "v1:"))
+	assert.NotContains(t, stored, "JBSWY3DPEHPK3PXP")
+
+	t.Run("Decrypt", func(t *testing.T) {
+		secret, err := cipher.Decrypt(stored, "12")
+		assert.NoError(t, err)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+	})
+
+	t.Run("Another Owner", func(t *testing.T) {
+		_, err := cipher.Decrypt(stored, "13")
+		assert.ErrorIs(t, err, ErrInvalidEncryptedSecret)
+	})
+
+	t.Run("Another Key", func(t *testing.T) {
+		other, _ := NewSecretCipher(bytes.Repeat([]byte{8}, SecretKeyLength))
+		_, err := other.Decrypt(stored, "12")
+		assert.ErrorIs(t, err, ErrInvalidEncryptedSecret)
+	})
+
+	t.Run("Stored Before The Encryption", func(t *testing.T) {
+		secret, err := cipher.Decrypt("JBSWY3DPEHPK3PXP", "12")
+		assert.NoError(t, err)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+	})
+
+	t.Run("Short Key", func(t *testing.T) {
+		_, err := NewSecretCipher([]byte("short"))
+		assert.Error(t, err)
+	})
+}
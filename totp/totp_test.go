@@ -0,0 +1,120 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHOTP(t *testing.T) {
+	// Test vectors of RFC 6238 appendix B for SHA1
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "94287082"},
+		{unix: 1111111109, expected: "07081804"},
+		{unix: 1111111111, expected: "14050471"},
+		{unix: 1234567890, expected: "89005924"},
+		{unix: 2000000000, expected: "69279037"},
+		{unix: 20000000000, expected: "65353130"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, test.expected, hotp(key, uint64(test.unix/Period), 8))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		ok   bool
+	}{
+		{name: "Current Period", at: now, ok: true},
+		{name: "Previous Period", at: now.Add(-Period * time.Second), ok: true},
+		{name: "Next Period", at: now.Add(Period * time.Second), ok: true},
+		{name: "Expired", at: now.Add(-2 * Period * time.Second), ok: false},
+		{name: "Too Early", at: now.Add(2 * Period * time.Second), ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := Code(secret, test.at)
+			assert.NoError(t, err)
+
+			step, ok, err := Validate(secret, code, now)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ok, ok)
+			if test.ok {
+				assert.Equal(t, Step(test.at), step)
+			}
+		})
+	}
+
+	t.Run("Wrong Code", func(t *testing.T) {
+		_, ok, err := Validate(secret, "000000", now)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Invalid Secret", func(t *testing.T) {
+		_, _, err := Validate("not base32!", "000000", now)
+		assert.Error(t, err)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if assert.NoError(t, err) {
+		assert.Len(t, secret, 32)
+
+		code, err := Code(secret, time.Now())
+		assert.NoError(t, err)
+		assert.Len(t, code, Digits)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Simple Profile", "+6281234567890", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "otpauth", parsed.Scheme)
+		assert.Equal(t, "totp", parsed.Host)
+		assert.Equal(t, "/Simple Profile:+6281234567890", parsed.Path)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+		assert.Equal(t, "Simple Profile", parsed.Query().Get("issuer"))
+		assert.Equal(t, "6", parsed.Query().Get("digits"))
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, codes, RecoveryCodeCount)
+
+	unique := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		unique[code] = true
+	}
+	assert.Len(t, unique, RecoveryCodeCount)
+
+	// the codes are typed by hand, case and separators don't matter
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+	assert.NotEqual(t, HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1]))
+}